@@ -15,16 +15,59 @@
 package flags
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"mittens/pkg/grpc"
+	"time"
+
+	"google.golang.org/grpc/keepalive"
 )
 
+const bytesPerMB = 1024 * 1024
+
 // Grpc stores flags related to gRPC requests.
 type Grpc struct {
-	Headers  stringArray
-	Requests stringArray
+	Headers      stringArray
+	Requests     stringArray
+	RequestsFile string
+	RequestFile  string
+	Authority    string
+	LoadBalance  bool
+
+	KeepaliveTimeSeconds         int
+	KeepaliveTimeoutSeconds      int
+	KeepalivePermitWithoutStream bool
+
+	MaxRecvMsgSizeMB int
+	MaxSendMsgSizeMB int
+
+	MaxStreamMessages int
+	TimeoutMs         int
+	Verbose           bool
+
+	ServiceConfig string
+
+	WaitForHealthy bool
+
+	PoolSize    int
+	Protosets   stringArray
+	Protos      stringArray
+	ImportPaths stringArray
+
+	ReflectionRetries   int
+	ReflectionBackoffMs int
+
+	TLS           bool
+	TLSCACert     string
+	TLSClientCert string
+	TLSClientKey  string
+	TLSSkipVerify bool
+	TLSServerName string
 }
 
 func (g *Grpc) String() string {
@@ -33,16 +76,212 @@ func (g *Grpc) String() string {
 
 func (g *Grpc) initFlags() {
 	flag.Var(&g.Headers, "grpc-headers", "gRPC header to be sent with warm up requests.")
-	flag.Var(&g.Requests, "grpc-requests", `gRPC request to be sent. Request is in '<service>/<method>[:message]' format. E.g. health/ping:{"key": "value"}`)
+	flag.Var(&g.Requests, "grpc-requests", `gRPC request to be sent. Request is in '<service>/<method>[:message]' format. E.g. health/ping:{"key": "value"}. The method can be set to health as shorthand for grpc.health.v1.Health/Check`)
+	flag.StringVar(&g.RequestsFile, "grpc-requests-file", "", "JSON or YAML file with a list of gRPC requests to be sent in addition to the ones in grpc-requests")
+	flag.StringVar(&g.RequestFile, "grpc-request-file", "", "File with one gRPC request per line in the same format as grpc-requests, to be sent in addition to them. Empty lines and lines starting with # are ignored")
+	flag.BoolVar(&g.LoadBalance, "grpc-load-balance", false, "If set to true gRPC requests are spread across all the addresses the target host resolves to using round robin. The host is resolved using DNS unless it already includes a resolver scheme e.g. dns:///my-service")
+	flag.IntVar(&g.KeepaliveTimeSeconds, "grpc-keepalive-time-seconds", 0, "Interval in seconds after which a keepalive ping is sent on an idle gRPC connection. Values below 10 are raised to 10. 0 disables keepalive pings")
+	flag.IntVar(&g.KeepaliveTimeoutSeconds, "grpc-keepalive-timeout-seconds", 20, "Time in seconds to wait for a keepalive ping to be acknowledged before the gRPC connection is closed")
+	flag.BoolVar(&g.KeepalivePermitWithoutStream, "grpc-keepalive-permit-without-stream", false, "If set to true keepalive pings are sent even when there are no active gRPC calls e.g. while waiting for the target to be ready")
+	flag.IntVar(&g.MaxRecvMsgSizeMB, "grpc-max-recv-msg-size-mb", 0, "Max size in MB of the gRPC messages that can be received. 0 means the gRPC default of 4 MB")
+	flag.IntVar(&g.MaxSendMsgSizeMB, "grpc-max-send-msg-size-mb", 0, "Max size in MB of the gRPC messages that can be sent. 0 means no limit")
+	flag.IntVar(&g.MaxStreamMessages, "grpc-max-stream-messages", 0, "Max number of messages received from server-streaming gRPC methods, after which the call is cancelled and counted as successful, e.g. to warm up infinite streams. 0 means streams are consumed until they complete")
+	flag.IntVar(&g.TimeoutMs, "grpc-timeout-ms", 10000, "Time in milliseconds after which a gRPC warm up request times out, including receiving the whole response stream. Zero means no limit")
+	flag.BoolVar(&g.Verbose, "grpc-verbose", false, "If set to true the responses of the gRPC warm up requests are logged as JSON. By default only their status and number of messages are logged")
+	flag.StringVar(&g.ServiceConfig, "grpc-service-config", "", `JSON gRPC service config, e.g. to use the same retry and wait-for-ready settings as the production clients. E.g. {"methodConfig": [{"name": [{"service": "my.Service"}], "waitForReady": true}]}`)
+	flag.BoolVar(&g.WaitForHealthy, "grpc-wait-for-healthy", false, "If set to true the warm up only starts once the grpc.health.v1.Health/Check method of the gRPC target returns SERVING. It is polled every second for up to max-duration-seconds, after the readiness check passes")
+	flag.IntVar(&g.PoolSize, "grpc-pool-size", 1, "Number of connections opened to the gRPC target. Warm up requests are sent over them in round robin, so that a single connection does not become a bottleneck under high concurrency")
+	flag.Var(&g.Protosets, "grpc-protoset", "Compiled FileDescriptorSet file, e.g. the output of protoc --descriptor_set_out, from which the gRPC services and methods are resolved instead of server reflection. To set multiple files define this flag for each file")
+	flag.Var(&g.Protos, "grpc-proto", ".proto source file from which the gRPC services and methods are resolved instead of server reflection. To set multiple files define this flag for each file")
+	flag.Var(&g.ImportPaths, "grpc-import-path", "Directory in which the files imported by the grpc-proto files are looked up. To set multiple directories define this flag for each directory")
+	flag.IntVar(&g.ReflectionRetries, "grpc-reflection-retries", 5, "Number of times the reflection service of the gRPC target is checked again if it is not ready once connected, e.g. for slow-starting servers. Zero means it is not checked")
+	flag.IntVar(&g.ReflectionBackoffMs, "grpc-reflection-backoff-ms", 500, "Time in milliseconds to wait before checking the reflection service of the gRPC target again. The wait doubles with every retry")
+	flag.BoolVar(&g.TLS, "grpc-tls", true, "If set to false gRPC requests are sent in plaintext. For backwards compatibility, they are also sent in plaintext if target-insecure is set")
+	flag.StringVar(&g.TLSCACert, "grpc-tls-ca-cert", "", "PEM file with the CA certificates used to verify the gRPC target instead of the ones in tls-ca-cert or the system ones")
+	flag.StringVar(&g.TLSClientCert, "grpc-tls-client-cert", "", "PEM file with the client certificate sent to gRPC targets that require mTLS. Requires grpc-tls-client-key")
+	flag.StringVar(&g.TLSClientKey, "grpc-tls-client-key", "", "PEM file with the private key of the client certificate set in grpc-tls-client-cert")
+	flag.BoolVar(&g.TLSSkipVerify, "grpc-tls-skip-verify", false, "If set to true the certificate of the gRPC target is not verified")
+	flag.StringVar(&g.TLSServerName, "grpc-tls-server-name", "", "Server name sent in the TLS handshake (SNI) of gRPC requests and used to verify the certificate of the target instead of its host")
+	flag.StringVar(&g.Authority, "grpc-authority", "", "Value of the :authority pseudo-header sent with gRPC requests. Useful when the target is behind a proxy that routes based on the virtual host name")
+}
+
+func (g *Grpc) getClientOptions() []grpc.ClientOption {
+	var opts []grpc.ClientOption
+	if g.Authority != "" {
+		opts = append(opts, grpc.WithAuthority(g.Authority))
+	}
+	if g.LoadBalance {
+		opts = append(opts, grpc.WithLoadBalancing())
+	}
+	if g.KeepaliveTimeSeconds > 0 {
+		opts = append(opts, grpc.WithKeepalive(keepalive.ClientParameters{
+			Time:                time.Duration(g.KeepaliveTimeSeconds) * time.Second,
+			Timeout:             time.Duration(g.KeepaliveTimeoutSeconds) * time.Second,
+			PermitWithoutStream: g.KeepalivePermitWithoutStream,
+		}))
+	}
+	if g.MaxRecvMsgSizeMB > 0 {
+		opts = append(opts, grpc.WithMaxRecvMsgSize(g.MaxRecvMsgSizeMB*bytesPerMB))
+	}
+	if g.MaxSendMsgSizeMB > 0 {
+		opts = append(opts, grpc.WithMaxSendMsgSize(g.MaxSendMsgSizeMB*bytesPerMB))
+	}
+	if g.MaxStreamMessages > 0 {
+		opts = append(opts, grpc.WithMaxStreamMessages(g.MaxStreamMessages))
+	}
+	if g.ServiceConfig != "" {
+		opts = append(opts, grpc.WithServiceConfig(g.ServiceConfig))
+	}
+	// already validated on startup
+	if descriptors, _ := g.getDescriptors(); descriptors != nil {
+		opts = append(opts, grpc.WithDescriptors(descriptors))
+	}
+	return opts
+}
+
+// getDescriptors loads the descriptors of the gRPC services from the protoset or .proto files,
+// or returns nil if none is set, in which case server reflection is used.
+func (g *Grpc) getDescriptors() (*grpc.Descriptors, error) {
+	switch {
+	case len(g.Protosets) > 0 && len(g.Protos) > 0:
+		return nil, fmt.Errorf("grpc-protoset and grpc-proto cannot be set together")
+	case len(g.ImportPaths) > 0 && len(g.Protos) == 0:
+		return nil, fmt.Errorf("grpc-import-path requires grpc-proto")
+	case len(g.Protosets) > 0:
+		return grpc.LoadProtosets(g.Protosets...)
+	case len(g.Protos) > 0:
+		return grpc.LoadProtoFiles(g.ImportPaths, g.Protos...)
+	}
+	return nil, nil
+}
+
+// validateMaxMsgSizes returns an error if the max message sizes are negative or too large to be represented in bytes.
+func (g *Grpc) validateMaxMsgSizes() error {
+	const maxMsgSizeMB = math.MaxInt32 / bytesPerMB
+	if g.MaxRecvMsgSizeMB < 0 || g.MaxRecvMsgSizeMB > maxMsgSizeMB {
+		return fmt.Errorf("invalid grpc-max-recv-msg-size-mb %d, expected a value between 0 and %d", g.MaxRecvMsgSizeMB, maxMsgSizeMB)
+	}
+	if g.MaxSendMsgSizeMB < 0 || g.MaxSendMsgSizeMB > maxMsgSizeMB {
+		return fmt.Errorf("invalid grpc-max-send-msg-size-mb %d, expected a value between 0 and %d", g.MaxSendMsgSizeMB, maxMsgSizeMB)
+	}
+	return nil
+}
+
+// validateTimeout returns an error if the timeout of the requests is negative.
+func (g *Grpc) validateTimeout() error {
+	if g.TimeoutMs < 0 {
+		return fmt.Errorf("invalid grpc-timeout-ms %d, expected it to be zero or greater", g.TimeoutMs)
+	}
+	return nil
+}
+
+// validateMaxStreamMessages returns an error if the max number of stream messages is negative.
+func (g *Grpc) validateMaxStreamMessages() error {
+	if g.MaxStreamMessages < 0 {
+		return fmt.Errorf("invalid grpc-max-stream-messages %d, expected it to be 0 or greater", g.MaxStreamMessages)
+	}
+	return nil
+}
+
+// validateServiceConfig returns an error if the service config is set but it is not a JSON object.
+func (g *Grpc) validateServiceConfig() error {
+	if g.ServiceConfig == "" {
+		return nil
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal([]byte(g.ServiceConfig), &config); err != nil {
+		return fmt.Errorf("invalid grpc-service-config, expected a JSON object: %v", err)
+	}
+	return nil
+}
+
+// validateReflectionRetries returns an error if the reflection retries or backoff are negative.
+func (g *Grpc) validateReflectionRetries() error {
+	if g.ReflectionRetries < 0 {
+		return fmt.Errorf("invalid grpc-reflection-retries %d, expected it to be zero or greater", g.ReflectionRetries)
+	}
+	if g.ReflectionBackoffMs < 0 {
+		return fmt.Errorf("invalid grpc-reflection-backoff-ms %d, expected it to be zero or greater", g.ReflectionBackoffMs)
+	}
+	return nil
+}
+
+// validatePoolSize returns an error if the pool does not have at least one connection.
+func (g *Grpc) validatePoolSize() error {
+	if g.PoolSize < 1 {
+		return fmt.Errorf("invalid grpc-pool-size %d, expected it to be 1 or greater", g.PoolSize)
+	}
+	return nil
+}
+
+// getTLSConfig returns the TLS config of the gRPC clients built from the grpc-tls flags, or nil if none of them is set.
+// It returns an error if only one of the client certificate and key is set, if any file cannot be loaded
+// or if any of them is set while gRPC requests are sent in plaintext, so that a misconfiguration fails at startup.
+func (g *Grpc) getTLSConfig(insecure bool) (*tls.Config, error) {
+	if g.TLSCACert == "" && g.TLSClientCert == "" && g.TLSClientKey == "" && !g.TLSSkipVerify && g.TLSServerName == "" {
+		return nil, nil
+	}
+	if !g.TLS || insecure {
+		return nil, fmt.Errorf("grpc-tls flags cannot be set if gRPC requests are sent in plaintext, i.e. grpc-tls is false or target-insecure is set")
+	}
+
+	config := &tls.Config{ServerName: g.TLSServerName, InsecureSkipVerify: g.TLSSkipVerify}
+	if g.TLSCACert != "" {
+		pool := x509.NewCertPool()
+		if err := appendCertsFromPEMFile(pool, g.TLSCACert); err != nil {
+			return nil, fmt.Errorf("grpc-tls-ca-cert %s: %v", g.TLSCACert, err)
+		}
+		config.RootCAs = pool
+	}
+	if g.TLSClientCert != "" || g.TLSClientKey != "" {
+		if g.TLSClientCert == "" || g.TLSClientKey == "" {
+			return nil, fmt.Errorf("grpc-tls-client-cert and grpc-tls-client-key must be set together")
+		}
+		certificate, err := tls.LoadX509KeyPair(g.TLSClientCert, g.TLSClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("gRPC client certificate %s and key %s: %v", g.TLSClientCert, g.TLSClientKey, err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
 }
 
 func (g *Grpc) getWarmupGrpcHeaders() []string {
 	return g.Headers
 }
 
+// validateHeaders returns an error if any placeholder in the header values has invalid modifiers.
+// Placeholders are replaced every time a request is sent.
+func (g *Grpc) validateHeaders() error {
+	if _, err := grpc.InterpolateHeaders(g.Headers); err != nil {
+		return fmt.Errorf("grpc-headers: %v", err)
+	}
+	return nil
+}
+
 func (g *Grpc) getWarmupGrpcRequests() ([]grpc.Request, error) {
 	log.Print(g.Requests)
-	return toGrpcRequests(g.Requests)
+	requests, err := toGrpcRequests(g.Requests)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.RequestsFile != "" {
+		fileRequests, err := grpc.ToGrpcRequestsFromFile(g.RequestsFile)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, fileRequests...)
+	}
+
+	if g.RequestFile != "" {
+		fileRequests, err := grpc.ToGrpcRequestsFromLines(g.RequestFile)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, fileRequests...)
+	}
+	return requests, nil
 }
 
 func toGrpcRequests(requestsFlag []string) ([]grpc.Request, error) {
@@ -17,6 +17,9 @@ package flags
 import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -34,3 +37,137 @@ func TestGrpc_ToGrpcRequests(t *testing.T) {
 	assert.Equal(t, "svc1/ping", requests[0].ServiceMethod)
 	assert.Equal(t, "svc2/ping", requests[1].ServiceMethod)
 }
+
+func TestGrpc_ValidateMaxMsgSizes(t *testing.T) {
+	g := Grpc{MaxRecvMsgSizeMB: 64, MaxSendMsgSizeMB: 16}
+	assert.NoError(t, g.validateMaxMsgSizes())
+	assert.Len(t, g.getClientOptions(), 2)
+
+	g = Grpc{MaxRecvMsgSizeMB: -1}
+	assert.Error(t, g.validateMaxMsgSizes())
+
+	g = Grpc{MaxSendMsgSizeMB: 4096}
+	assert.Error(t, g.validateMaxMsgSizes())
+}
+
+func TestGrpc_ValidateTimeout(t *testing.T) {
+	assert.NoError(t, (&Grpc{TimeoutMs: 10000}).validateTimeout())
+	assert.NoError(t, (&Grpc{TimeoutMs: 0}).validateTimeout())
+	assert.Error(t, (&Grpc{TimeoutMs: -1}).validateTimeout())
+}
+
+func TestGrpc_ValidateMaxStreamMessages(t *testing.T) {
+	g := Grpc{}
+	assert.NoError(t, g.validateMaxStreamMessages())
+	assert.Len(t, g.getClientOptions(), 0)
+
+	g = Grpc{MaxStreamMessages: 100}
+	assert.NoError(t, g.validateMaxStreamMessages())
+	assert.Len(t, g.getClientOptions(), 1)
+
+	g = Grpc{MaxStreamMessages: -1}
+	assert.Error(t, g.validateMaxStreamMessages())
+}
+
+func TestGrpc_ValidateServiceConfig(t *testing.T) {
+	g := Grpc{}
+	assert.NoError(t, g.validateServiceConfig())
+
+	g = Grpc{ServiceConfig: `{"methodConfig": [{"name": [{"service": "grpc.health.v1.Health"}], "waitForReady": true}]}`}
+	assert.NoError(t, g.validateServiceConfig())
+	assert.Len(t, g.getClientOptions(), 1)
+
+	for _, invalid := range []string{`{"methodConfig": [}`, `[]`, `waitForReady`} {
+		g = Grpc{ServiceConfig: invalid}
+		assert.Error(t, g.validateServiceConfig(), invalid)
+	}
+}
+
+func TestGrpc_ValidatePoolSize(t *testing.T) {
+	g := Grpc{PoolSize: 1}
+	assert.NoError(t, g.validatePoolSize())
+
+	g = Grpc{PoolSize: 8}
+	assert.NoError(t, g.validatePoolSize())
+
+	g = Grpc{PoolSize: 0}
+	assert.Error(t, g.validatePoolSize())
+}
+
+func TestGrpc_TLSConfig(t *testing.T) {
+	g := Grpc{TLS: true}
+	config, err := g.getTLSConfig(false)
+	require.NoError(t, err)
+	assert.Nil(t, config)
+
+	dir, err := ioutil.TempDir("", "mittens")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	caCert := filepath.Join(dir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(caCert, newTestCACertificate(t, "ca"), 0644))
+
+	g = Grpc{TLS: true, TLSCACert: caCert, TLSServerName: "my-service.internal"}
+	config, err = g.getTLSConfig(false)
+	require.NoError(t, err)
+	assert.Len(t, config.RootCAs.Subjects(), 1)
+	assert.Equal(t, "my-service.internal", config.ServerName)
+	assert.False(t, config.InsecureSkipVerify)
+
+	g = Grpc{TLS: true, TLSSkipVerify: true}
+	config, err = g.getTLSConfig(false)
+	require.NoError(t, err)
+	assert.True(t, config.InsecureSkipVerify)
+	assert.Nil(t, config.RootCAs)
+
+	// plaintext, either explicitly or through target-insecure
+	_, err = g.getTLSConfig(true)
+	assert.Error(t, err)
+	g = Grpc{TLS: false, TLSSkipVerify: true}
+	_, err = g.getTLSConfig(false)
+	assert.Error(t, err)
+
+	g = Grpc{TLS: true, TLSClientCert: "cert.pem"}
+	_, err = g.getTLSConfig(false)
+	assert.Error(t, err)
+
+	g = Grpc{TLS: true, TLSClientCert: "does-not-exist.pem", TLSClientKey: "does-not-exist.key"}
+	_, err = g.getTLSConfig(false)
+	assert.Error(t, err)
+
+	g = Grpc{TLS: true, TLSCACert: filepath.Join(dir, "does-not-exist.pem")}
+	_, err = g.getTLSConfig(false)
+	assert.Error(t, err)
+}
+
+func TestGrpc_Descriptors(t *testing.T) {
+	g := Grpc{}
+	descriptors, err := g.getDescriptors()
+	require.NoError(t, err)
+	assert.Nil(t, descriptors)
+
+	g = Grpc{Protosets: stringArray{"does-not-exist.protoset"}}
+	_, err = g.getDescriptors()
+	assert.Error(t, err)
+
+	g = Grpc{Protosets: stringArray{"api.protoset"}, Protos: stringArray{"api.proto"}}
+	_, err = g.getDescriptors()
+	assert.Error(t, err)
+
+	g = Grpc{ImportPaths: stringArray{"protos"}}
+	_, err = g.getDescriptors()
+	assert.Error(t, err)
+}
+
+func TestGrpc_ValidateReflectionRetries(t *testing.T) {
+	g := Grpc{ReflectionRetries: 5, ReflectionBackoffMs: 500}
+	assert.NoError(t, g.validateReflectionRetries())
+
+	g = Grpc{}
+	assert.NoError(t, g.validateReflectionRetries())
+
+	g = Grpc{ReflectionRetries: -1}
+	assert.Error(t, g.validateReflectionRetries())
+
+	g = Grpc{ReflectionRetries: 5, ReflectionBackoffMs: -1}
+	assert.Error(t, g.validateReflectionRetries())
+}
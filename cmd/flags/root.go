@@ -15,23 +15,44 @@
 package flags
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
-	"math/rand"
 	"mittens/pkg/grpc"
 	"mittens/pkg/http"
+	"mittens/pkg/tracing"
 	"mittens/pkg/warmup"
+	"net/url"
 	"time"
 )
 
 // Root stores all the flags.
 type Root struct {
 	MaxDurationSeconds       int
+	MaxWarmupDurationSeconds int
 	Concurrency              int
 	RequestDelayMilliseconds int
+	RequestDelayMinMs        int
+	RequestDelayMaxMs        int
 	ExitAfterWarmup          bool
+	ReWarmupIntervalSeconds  int
 	FailReadiness            bool
+	FailOnError              bool
+	MinSuccessRate           float64
+	ShuffleRequests          bool
+	Repeat                   int
+	PrecheckTimeoutSeconds   int
+	WarnLatencyThresholdMs   int
+	SummaryFormat            string
+	RetryMaxAttempts         int
+	RetryBackoffMs           int
+	CompletionURL            string
+	HealthzPort              int
+	ConnectionsOnly          int
+	ConnectionsOnlyPath      string
+	OtelEndpoint             string
+	OtelServiceName          string
 	FileProbe
 	ServerProbe
 	Target
@@ -46,10 +67,29 @@ func (r *Root) String() string {
 // InitFlags initialises all the flags.
 func (r *Root) InitFlags() {
 	flag.IntVar(&r.MaxDurationSeconds, "max-duration-seconds", 60, "Max duration in seconds after which warm up will stop making requests")
+	flag.IntVar(&r.MaxWarmupDurationSeconds, "max-warmup-duration-seconds", 0, "Global deadline in seconds for the whole warm up, including waiting for the target to be ready. Once exceeded, requests in flight are cancelled and mittens exits with a non-zero code. Zero means no deadline")
 	flag.IntVar(&r.Concurrency, "concurrency", 2, "Number of concurrent requests for warm up")
 	flag.IntVar(&r.RequestDelayMilliseconds, "request-delay-milliseconds", 500, "Delay in milliseconds between requests")
+	flag.IntVar(&r.RequestDelayMinMs, "request-delay-min-ms", 0, "Minimum delay in milliseconds between requests. If this or request-delay-max-ms is set, a random delay in that range is used instead of request-delay-milliseconds. If request-delay-max-ms is not set, it defaults to this value")
+	flag.IntVar(&r.RequestDelayMaxMs, "request-delay-max-ms", 0, "Maximum delay in milliseconds between requests. If this or request-delay-min-ms is set, a random delay in that range is used instead of request-delay-milliseconds")
 	flag.BoolVar(&r.ExitAfterWarmup, "exit-after-warmup", false, "If warm up process should finish after completion. This is useful to prevent container restarts.")
+	flag.IntVar(&r.ReWarmupIntervalSeconds, "re-warmup-interval-seconds", 0, "If set and exit-after-warmup is false, the warm up is repeated every this many seconds after it finishes until mittens receives SIGTERM, so that the caches of the target are kept hot. Zero means the warm up runs only once")
 	flag.BoolVar(&r.FailReadiness, "fail-readiness", false, "If set to true readiness will fail if no requests were sent.")
+	flag.BoolVar(&r.FailOnError, "fail-on-error", false, "If set to true mittens exits with a non-zero code once the warm up finishes if any request failed or returned an unexpected status code")
+	flag.Float64Var(&r.MinSuccessRate, "min-success-rate", 0, "Minimum ratio (0.0-1.0) of requests that must succeed. If the success rate is lower once the warm up finishes, mittens exits with a non-zero code. Zero means no minimum")
+	flag.BoolVar(&r.ShuffleRequests, "shuffle-requests", false, "If set to true requests are sent in shuffled rounds, each one including every request as many times as its weight, instead of being picked at random independently")
+	flag.IntVar(&r.Repeat, "repeat", 0, "If set, each request is sent this many times in a row before moving to the next one, and the warm up finishes once all of them are sent, ignoring the weights. Cannot be combined with shuffle-requests. Zero means requests are sent until max-duration-seconds")
+	flag.IntVar(&r.PrecheckTimeoutSeconds, "precheck-timeout-seconds", 0, "If set, mittens waits for the HTTP and gRPC ports of the target to accept TCP connections for a max of this many seconds before checking its readiness and sending requests. Zero means no precheck")
+	flag.IntVar(&r.WarnLatencyThresholdMs, "warn-latency-threshold-ms", 0, "If set, responses that take longer than this many milliseconds are logged as a warning and the number of slow responses is logged once the warm up finishes. Zero means no threshold")
+	flag.StringVar(&r.SummaryFormat, "summary-format", "text", "Format of the summary printed once the warm up finishes. One of [text, json]. The json summary is printed to stdout and includes the latency percentiles")
+	flag.IntVar(&r.RetryMaxAttempts, "retry-max-attempts", 1, "Max number of times a request that fails without a response, e.g. because the connection is refused, is sent. Retries stop once max-duration-seconds is exceeded. 1 means no retries")
+	flag.IntVar(&r.RetryBackoffMs, "retry-backoff-ms", 100, "Time in milliseconds to wait before retrying a failed request. The wait doubles with every retry")
+	flag.IntVar(&r.HealthzPort, "readiness-port", 0, "If set, runs a web server on this port that exposes the warm up progress on /healthz. It returns 200 once the warm up is done and 503 until then")
+	flag.IntVar(&r.ConnectionsOnly, "connections-only", 0, "If set, instead of sending the warm up requests mittens opens this many connections to the HTTP target, completing the TLS handshake for HTTPS targets, and logs the connection latencies. Zero means requests are sent")
+	flag.StringVar(&r.ConnectionsOnlyPath, "connections-only-path", "", "If set together with connections-only, a HEAD request to this path is sent on each connection once connected")
+	flag.StringVar(&r.OtelEndpoint, "otel-endpoint", "", "If set, every warm up request is recorded as a span and exported to this OpenTelemetry collector endpoint using OTLP over HTTP with JSON encoding e.g. http://otel-collector:4318. The trace is propagated to the target with a traceparent header")
+	flag.StringVar(&r.OtelServiceName, "otel-service-name", "mittens", "Service name of the spans exported to otel-endpoint")
+	flag.StringVar(&r.CompletionURL, "completion-url", "", "URL to POST to once the warm up finishes. The body includes the status, the duration in milliseconds and the number of errors")
 
 	r.FileProbe.initFlags()
 	r.ServerProbe.initFlags()
@@ -63,6 +103,57 @@ func (r *Root) GetMaxDurationSeconds() int {
 	return r.MaxDurationSeconds
 }
 
+// GetHTTPFailOnStatus returns the status codes of the HTTP responses that are counted as failures.
+// It returns nil, meaning that any status code other than 2xx and 3xx is a failure, if http-fail-on-status is not set.
+func (r *Root) GetHTTPFailOnStatus() http.StatusCodes {
+	// already validated on startup
+	statusCodes, _ := r.HTTP.getFailOnStatus()
+	return statusCodes
+}
+
+// GetHTTPAuthorization returns the Authorization header sent with the HTTP requests, if set.
+func (r *Root) GetHTTPAuthorization() *http.Authorization {
+	// already validated on startup
+	authorization, _ := r.HTTP.getAuthorization()
+	return authorization
+}
+
+// GetHTTPCookieJarPerWorker returns true if each HTTP worker keeps its own cookies.
+func (r *Root) GetHTTPCookieJarPerWorker() bool {
+	return r.HTTP.getCookieJar() == cookieJarPerWorker
+}
+
+// GetRetry returns how failed requests are retried.
+func (r *Root) GetRetry() warmup.Retry {
+	// already validated on startup
+	statusCodes, _ := r.HTTP.getRetryOnStatus()
+	return warmup.Retry{
+		MaxAttempts:     r.RetryMaxAttempts,
+		Backoff:         time.Duration(r.RetryBackoffMs) * time.Millisecond,
+		HTTPStatusCodes: statusCodes,
+	}
+}
+
+// GetTracer returns the tracer that records the warm up requests, or nil if otel-endpoint is not set.
+func (r *Root) GetTracer() *tracing.Tracer {
+	if r.OtelEndpoint == "" {
+		return nil
+	}
+	return tracing.NewTracer(r.OtelEndpoint, r.OtelServiceName)
+}
+
+// validateOtelEndpoint returns an error if the OpenTelemetry endpoint is set and is not an HTTP URL.
+func (r *Root) validateOtelEndpoint() error {
+	if r.OtelEndpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(r.OtelEndpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid otel-endpoint %s, expected an http:// or https:// URL", r.OtelEndpoint)
+	}
+	return nil
+}
+
 // GetConcurrency returns the value of the concurrency parameter.
 func (r *Root) GetConcurrency() int {
 	return r.Concurrency
@@ -70,28 +161,77 @@ func (r *Root) GetConcurrency() int {
 
 // GetReadinessHTTPClient creates the HTTP client to be used for the readiness requests.
 func (r *Root) GetReadinessHTTPClient() http.Client {
-	return r.Target.getReadinessHTTPClient()
+	return r.Target.getReadinessHTTPClient(r.HTTP.getTransportConfig())
 }
 
 // GetReadinessGrpcClient creates the gRPC client to be used for the readiness requests.
 func (r *Root) GetReadinessGrpcClient() grpc.Client {
-	return r.Target.getReadinessGrpcClient()
+	return r.Target.getReadinessGrpcClient(r.grpcClientOptions()...)
 }
 
 // GetHTTPClient creates the HTTP client to be used for the actual requests.
 func (r *Root) GetHTTPClient() http.Client {
-	return r.Target.getHTTPClient()
+	return r.Target.getHTTPClient(r.HTTP.getTransportConfig())
 }
 
 // GetGrpcClient creates the gRPC client to be used for the actual requests.
+// Unlike the readiness client, it opens a pool of connections if grpc-pool-size is greater than 1,
+// waits for the reflection service of the target to be ready, as the readiness requests are already retried,
+// times out the requests after grpc-timeout-ms and, if grpc-verbose is set, captures the responses so that they are logged.
 func (r *Root) GetGrpcClient() grpc.Client {
-	return r.Target.getGrpcClient(r.MaxDurationSeconds)
+	opts := append(r.grpcClientOptions(), grpc.WithPoolSize(r.Grpc.PoolSize),
+		grpc.WithReflectionRetries(r.Grpc.ReflectionRetries, time.Duration(r.Grpc.ReflectionBackoffMs)*time.Millisecond),
+		grpc.WithRequestTimeout(time.Duration(r.Grpc.TimeoutMs)*time.Millisecond))
+	if r.Grpc.Verbose {
+		opts = append(opts, grpc.WithCaptureResponses())
+	}
+	return r.Target.getGrpcClient(r.MaxDurationSeconds, opts...)
+}
+
+// grpcClientOptions returns the options shared by the readiness and warm up gRPC clients, including their TLS settings.
+// If grpc-tls-ca-cert is not set, the certificates of the target are verified using the ones in tls-ca-cert, if any.
+func (r *Root) grpcClientOptions() []grpc.ClientOption {
+	opts := r.Grpc.getClientOptions()
+	if !r.Grpc.TLS {
+		return append(opts, grpc.WithInsecure())
+	}
+	// already validated on startup
+	if tlsConfig, _ := r.Grpc.getTLSConfig(r.Target.Insecure); tlsConfig != nil {
+		if tlsConfig.RootCAs == nil {
+			tlsConfig.RootCAs, _ = r.Target.caCertPool()
+		}
+		opts = append(opts, grpc.WithTLS(tlsConfig))
+	}
+	return opts
+}
+
+// GetUnixSocket returns the path of the Unix domain socket of the target, if any.
+func (r *Root) GetUnixSocket() string {
+	// already validated on startup
+	socket, _ := r.Target.unixSocket()
+	return socket
+}
+
+// GetPrecheckAddresses returns the addresses of the target that must be open before the warm up starts.
+// The HTTP port is only included if there are HTTP requests and the gRPC port if there are gRPC requests.
+func (r *Root) GetPrecheckAddresses() ([]warmup.Address, error) {
+	httpRequests, err := r.HTTP.getWarmupHTTPRequests(r.Target.httpAddress())
+	if err != nil {
+		return nil, err
+	}
+	grpcRequests, err := r.Grpc.getWarmupGrpcRequests()
+	if err != nil {
+		return nil, err
+	}
+	return r.Target.precheckAddresses(len(httpRequests) > 0 || r.HTTP.OpenAPIPath != "", len(grpcRequests) > 0)
 }
 
 // GetWarmupTargetOptions validates and returns any options that apply to the target.
 func (r *Root) GetWarmupTargetOptions() (warmup.TargetOptions, error) {
 	options := r.Target.getWarmupTargetOptions()
 	options.ReadinessTimeoutInSeconds = r.MaxDurationSeconds
+	options.GrpcWaitForHealthy = r.Grpc.WaitForHealthy
+	options.GrpcHeaders = r.Grpc.getWarmupGrpcHeaders()
 	if options.ReadinessProtocol != "http" && options.ReadinessProtocol != "grpc" {
 		err := fmt.Errorf("Readiness protocol %s not supported, please use http or grpc", r.ReadinessProtocol)
 		return options, err
@@ -99,16 +239,166 @@ func (r *Root) GetWarmupTargetOptions() (warmup.TargetOptions, error) {
 	return options, nil
 }
 
+// GetRequestDelay returns the delay between requests.
+// If neither request-delay-min-ms nor request-delay-max-ms are set, the fixed request-delay-milliseconds is used.
+// If only request-delay-min-ms is set, the max is the same so that every request waits the min delay.
+func (r *Root) GetRequestDelay() (warmup.Delay, error) {
+	if r.RequestDelayMinMs == 0 && r.RequestDelayMaxMs == 0 {
+		delay := time.Duration(r.RequestDelayMilliseconds) * time.Millisecond
+		return warmup.Delay{Min: delay, Max: delay}, nil
+	}
+	maxMs := r.RequestDelayMaxMs
+	if maxMs == 0 {
+		maxMs = r.RequestDelayMinMs
+	}
+	if r.RequestDelayMinMs < 0 || maxMs < r.RequestDelayMinMs {
+		return warmup.Delay{}, fmt.Errorf("invalid request delay range %d-%d ms, expected 0 <= request-delay-min-ms <= request-delay-max-ms", r.RequestDelayMinMs, maxMs)
+	}
+	return warmup.Delay{
+		Min: time.Duration(r.RequestDelayMinMs) * time.Millisecond,
+		Max: time.Duration(maxMs) * time.Millisecond,
+	}, nil
+}
+
+// ValidateWarmupRequests parses the HTTP and gRPC requests and returns an error if any of them is invalid
+// e.g. if a body or message file cannot be read. It also validates the request delay, the target socket and CA certificates,
+// the HTTP status codes to fail on and to retry, the HTTP client certificate, credentials and resolved addresses, the OpenTelemetry endpoint, the repeat count and the min success rate.
+func (r *Root) ValidateWarmupRequests() error {
+	if _, err := r.GetRequestDelay(); err != nil {
+		return err
+	}
+	if _, err := r.Target.unixSocket(); err != nil {
+		return err
+	}
+	if _, err := r.Target.caCertPool(); err != nil {
+		return err
+	}
+	if _, err := r.HTTP.getFailOnStatus(); err != nil {
+		return err
+	}
+	if _, err := r.HTTP.getClientCertificate(); err != nil {
+		return err
+	}
+	if err := r.HTTP.validateProxy(); err != nil {
+		return err
+	}
+	if err := r.HTTP.validateStreamingTimeout(); err != nil {
+		return err
+	}
+	if err := r.HTTP.validateCookieJar(); err != nil {
+		return err
+	}
+	if err := r.HTTP.validateAcceptEncoding(); err != nil {
+		return err
+	}
+	if _, err := r.HTTP.getAuthorization(); err != nil {
+		return err
+	}
+	if _, err := r.HTTP.getDigestAuth(); err != nil {
+		return err
+	}
+	if _, err := r.HTTP.getResolve(); err != nil {
+		return err
+	}
+	if r.ReWarmupIntervalSeconds < 0 {
+		return fmt.Errorf("invalid re-warmup-interval-seconds %d, expected it to be zero or greater", r.ReWarmupIntervalSeconds)
+	}
+	if r.ReWarmupIntervalSeconds > 0 && r.ExitAfterWarmup {
+		return fmt.Errorf("re-warmup-interval-seconds cannot be set if exit-after-warmup is true")
+	}
+	if r.ConnectionsOnly < 0 {
+		return fmt.Errorf("invalid connections-only %d, expected it to be zero or greater", r.ConnectionsOnly)
+	}
+	if r.Repeat < 0 {
+		return fmt.Errorf("invalid repeat %d, expected it to be zero or greater", r.Repeat)
+	}
+	if r.Repeat > 0 && r.ShuffleRequests {
+		return fmt.Errorf("repeat cannot be set together with shuffle-requests, as repeated requests are sent in order")
+	}
+	if r.SummaryFormat != "" && r.SummaryFormat != "text" && r.SummaryFormat != "json" {
+		return fmt.Errorf("invalid summary format %s, expected one of [text, json]", r.SummaryFormat)
+	}
+	if r.RetryMaxAttempts < 0 || r.RetryBackoffMs < 0 {
+		return fmt.Errorf("invalid retry max attempts %d or backoff %d, expected them to be zero or greater", r.RetryMaxAttempts, r.RetryBackoffMs)
+	}
+	if _, err := r.HTTP.getRetryOnStatus(); err != nil {
+		return err
+	}
+	if _, err := r.HTTP.getRedirectPolicy(); err != nil {
+		return err
+	}
+	if err := r.validateOtelEndpoint(); err != nil {
+		return err
+	}
+	if r.WarnLatencyThresholdMs < 0 {
+		return fmt.Errorf("invalid warn latency threshold %d, expected it to be zero or greater", r.WarnLatencyThresholdMs)
+	}
+	if r.MinSuccessRate < 0 || r.MinSuccessRate > 1 {
+		return fmt.Errorf("invalid min success rate %g, expected a value between 0.0 and 1.0", r.MinSuccessRate)
+	}
+	if _, err := r.HTTP.getWarmupHTTPRequests(r.Target.httpAddress()); err != nil {
+		return fmt.Errorf("HTTP options: %v", err)
+	}
+	if err := r.Grpc.validateHeaders(); err != nil {
+		return err
+	}
+	if err := r.Grpc.validateMaxMsgSizes(); err != nil {
+		return err
+	}
+	if err := r.Grpc.validateTimeout(); err != nil {
+		return err
+	}
+	if err := r.Grpc.validateMaxStreamMessages(); err != nil {
+		return err
+	}
+	if err := r.Grpc.validatePoolSize(); err != nil {
+		return err
+	}
+	if err := r.Grpc.validateServiceConfig(); err != nil {
+		return err
+	}
+	if err := r.Grpc.validateReflectionRetries(); err != nil {
+		return err
+	}
+	if _, err := r.Grpc.getTLSConfig(r.Target.Insecure); err != nil {
+		return err
+	}
+	if _, err := r.Grpc.getDescriptors(); err != nil {
+		return err
+	}
+	if _, err := r.Grpc.getWarmupGrpcRequests(); err != nil {
+		return fmt.Errorf("Grpc options: %v", err)
+	}
+	return nil
+}
+
 // GetWarmupHTTPHeaders returns the HTTP headers.
 func (r *Root) GetWarmupHTTPHeaders() map[string]string {
 	return r.HTTP.getWarmupHTTPHeaders()
 }
 
-// GetWarmupHTTPRequests returns a channel with HTTP requests.
-func (r *Root) GetWarmupHTTPRequests() (chan http.Request, error) {
-	requests, err := r.HTTP.getWarmupHTTPRequests()
+// GetWarmupHTTPRequests returns a channel with HTTP requests and the number of requests sent through it, or -1 if it is not known.
+// The channel is closed after MaxDurationSeconds, once the context is done or, if repeat is set, once every request has been sent repeat times.
+func (r *Root) GetWarmupHTTPRequests(ctx context.Context) (chan http.Request, int, error) {
+	requests, err := r.HTTP.getWarmupHTTPRequests(r.Target.httpAddress())
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	// the spec can only be fetched once the target is ready, so a failure should not prevent the rest of the requests from being sent
+	if r.HTTP.OpenAPIPath != "" {
+		openAPIRequests, err := r.HTTP.getOpenAPIRequestsFromTarget(r.GetHTTPClient())
+		if err != nil {
+			log.Printf("Skipping OpenAPI requests: %v", err)
+		}
+		requests = append(requests, openAPIRequests...)
+	}
+
+	// already validated on startup
+	if digestAuth, _ := r.HTTP.getDigestAuth(); digestAuth != nil {
+		for i := range requests {
+			requests[i].DigestAuth = digestAuth
+		}
 	}
 
 	requestsChan := make(chan http.Request)
@@ -120,27 +410,69 @@ func (r *Root) GetWarmupHTTPRequests() (chan http.Request, error) {
 			close(requestsChan)
 			return
 		}
+		weights := make([]int, len(requests))
+		for i, request := range requests {
+			weights[i] = request.Weight
+		}
+		pick := r.newPicker(weights)
 		timeout := time.After(time.Duration(r.MaxDurationSeconds) * time.Second)
 
-		for {
+		i, ok := pick()
+		for ok {
 			select {
 			case <-timeout:
 				close(requestsChan)
 				return
-			default:
-				number := rand.Intn(len(requests))
-				requestsChan <- requests[number]
+			case <-ctx.Done():
+				close(requestsChan)
+				return
+			case requestsChan <- requests[i]:
+				i, ok = pick()
 			}
 		}
+		close(requestsChan)
 	}()
-	return requestsChan, nil
+	return requestsChan, r.totalRequests(len(requests)), nil
+}
+
+// newPicker returns a function that picks the index of the next request to be sent based on the weights of the requests.
+// If repeat is set, each request is picked that many times in a row, in order, and the function returns false once all of them have been picked.
+// The weights are then ignored, which is logged as a warning if any request has a weight other than 1.
+func (r *Root) newPicker(weights []int) func() (int, bool) {
+	if r.Repeat > 0 {
+		for _, weight := range weights {
+			if weight != 1 {
+				log.Printf("⚠️ WARNING: repeat is set, so the weights of the requests are ignored and each request is sent %d times", r.Repeat)
+				break
+			}
+		}
+		return newRepeatPicker(len(weights), r.Repeat)
+	}
+	pick := newWeightedPicker(weights)
+	if r.ShuffleRequests {
+		pick = newShuffledPicker(weights)
+	}
+	return func() (int, bool) { return pick(), true }
+}
+
+// totalRequests returns the number of requests sent out of n request definitions.
+// It is only known if repeat is set, as otherwise requests are sent until MaxDurationSeconds, in which case it returns -1.
+func (r *Root) totalRequests(n int) int {
+	if r.Repeat > 0 {
+		return n * r.Repeat
+	}
+	if n == 0 {
+		return 0
+	}
+	return -1
 }
 
-// GetWarmupGrpcRequests returns a channel with gRPC requests.
-func (r *Root) GetWarmupGrpcRequests() (chan grpc.Request, error) {
+// GetWarmupGrpcRequests returns a channel with gRPC requests and the number of requests sent through it, or -1 if it is not known.
+// The channel is closed after MaxDurationSeconds, once the context is done or, if repeat is set, once every request has been sent repeat times.
+func (r *Root) GetWarmupGrpcRequests(ctx context.Context) (chan grpc.Request, int, error) {
 	requests, err := r.Grpc.getWarmupGrpcRequests()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	requestsChan := make(chan grpc.Request)
@@ -152,20 +484,29 @@ func (r *Root) GetWarmupGrpcRequests() (chan grpc.Request, error) {
 			close(requestsChan)
 			return
 		}
+		weights := make([]int, len(requests))
+		for i, request := range requests {
+			weights[i] = request.Weight
+		}
+		pick := r.newPicker(weights)
 		timeout := time.After(time.Duration(r.MaxDurationSeconds) * time.Second)
 
-		for {
+		i, ok := pick()
+		for ok {
 			select {
 			case <-timeout:
 				close(requestsChan)
 				return
-			default:
-				number := rand.Intn(len(requests))
-				requestsChan <- requests[number]
+			case <-ctx.Done():
+				close(requestsChan)
+				return
+			case requestsChan <- requests[i]:
+				i, ok = pick()
 			}
 		}
+		close(requestsChan)
 	}()
-	return requestsChan, nil
+	return requestsChan, r.totalRequests(len(requests)), nil
 }
 
 // GetWarmupGrpcHeaders returns the gRPC headers.
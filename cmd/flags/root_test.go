@@ -0,0 +1,100 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package flags
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RequestDelay(t *testing.T) {
+	root := Root{RequestDelayMilliseconds: 500}
+	delay, err := root.GetRequestDelay()
+	require.NoError(t, err)
+	assert.Equal(t, 500*time.Millisecond, delay.Min)
+	assert.Equal(t, 500*time.Millisecond, delay.Max)
+
+	root = Root{RequestDelayMilliseconds: 500, RequestDelayMinMs: 10, RequestDelayMaxMs: 50}
+	delay, err = root.GetRequestDelay()
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Millisecond, delay.Min)
+	assert.Equal(t, 50*time.Millisecond, delay.Max)
+
+	// the max defaults to the min
+	root = Root{RequestDelayMilliseconds: 500, RequestDelayMinMs: 100}
+	delay, err = root.GetRequestDelay()
+	require.NoError(t, err)
+	assert.Equal(t, 100*time.Millisecond, delay.Min)
+	assert.Equal(t, 100*time.Millisecond, delay.Max)
+
+	root = Root{RequestDelayMilliseconds: 500, RequestDelayMaxMs: 100}
+	delay, err = root.GetRequestDelay()
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), delay.Min)
+	assert.Equal(t, 100*time.Millisecond, delay.Max)
+}
+
+func Test_InvalidRequestDelay(t *testing.T) {
+	root := Root{RequestDelayMinMs: 50, RequestDelayMaxMs: 10}
+	_, err := root.GetRequestDelay()
+	assert.Error(t, err)
+}
+
+func Test_RepeatWithShuffle(t *testing.T) {
+	root := Root{Repeat: 3, ShuffleRequests: true}
+	err := root.ValidateWarmupRequests()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "shuffle-requests")
+}
+
+func Test_InvalidMinSuccessRate(t *testing.T) {
+	for _, rate := range []float64{-0.1, 1.1} {
+		root := Root{MinSuccessRate: rate}
+		assert.Error(t, root.ValidateWarmupRequests(), rate)
+	}
+}
+
+func Test_OtelEndpoint(t *testing.T) {
+	root := Root{}
+	assert.NoError(t, root.validateOtelEndpoint())
+	assert.Nil(t, root.GetTracer())
+
+	root = Root{OtelEndpoint: "http://otel-collector:4318", OtelServiceName: "mittens"}
+	assert.NoError(t, root.validateOtelEndpoint())
+	assert.NotNil(t, root.GetTracer())
+
+	root = Root{OtelEndpoint: "otel-collector:4317"}
+	assert.Error(t, root.validateOtelEndpoint())
+}
+
+func Test_ReWarmupInterval(t *testing.T) {
+	root := Root{ReWarmupIntervalSeconds: -1}
+	assert.EqualError(t, root.ValidateWarmupRequests(), "invalid re-warmup-interval-seconds -1, expected it to be zero or greater")
+
+	root = Root{ReWarmupIntervalSeconds: 60, ExitAfterWarmup: true}
+	assert.EqualError(t, root.ValidateWarmupRequests(), "re-warmup-interval-seconds cannot be set if exit-after-warmup is true")
+}
+
+func Test_TotalRequests(t *testing.T) {
+	root := Root{}
+	assert.Equal(t, -1, root.totalRequests(3))
+	assert.Equal(t, 0, root.totalRequests(0))
+
+	root = Root{Repeat: 5}
+	assert.Equal(t, 15, root.totalRequests(3))
+}
@@ -15,11 +15,21 @@
 package flags
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"mittens/pkg/grpc"
 	"mittens/pkg/http"
 	"mittens/pkg/warmup"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 // Target stores flags related to the target.
@@ -34,6 +44,10 @@ type Target struct {
 	ReadinessPort           int
 	ReadinessTimeoutSeconds int
 	Insecure                bool
+	Socket                  string
+	SocketWaitSeconds       int
+	CACerts                 stringArray
+	TLSServerName           string
 }
 
 func (t *Target) String() string {
@@ -50,6 +64,11 @@ func (t *Target) initFlags() {
 	flag.StringVar(&t.ReadinessGrpcMethod, "target-readiness-grpc-method", "grpc.health.v1.Health/Check", "The service method used for gRPC target readiness probe")
 	flag.IntVar(&t.ReadinessPort, "target-readiness-port", toIntOrDefaultIfNull(&t.HTTPPort, 8080), "The port used for target readiness probe")
 	flag.BoolVar(&t.Insecure, "target-insecure", false, "Whether to skip TLS validation")
+	flag.Var(&t.CACerts, "tls-ca-cert", "PEM file, or directory of PEM files, with the CA certificates used to verify the HTTPS and gRPC targets instead of the system ones. To set multiple files define this flag for each file")
+	flag.StringVar(&t.TLSServerName, "tls-server-name", "", "Server name sent in the TLS handshake (SNI) of HTTPS requests and used to verify the certificate of the target instead of its host, e.g. to connect to localhost while presenting the public name of the target")
+	flag.StringVar(&t.Socket, "target-socket", "", "Unix domain socket to connect to instead of the host and port of the target e.g. unix:///var/run/app.sock or /var/run/app.sock. The target host is still sent as the Host header or gRPC authority")
+	flag.StringVar(&t.Socket, "target-unix-socket", "", "Alias of target-socket")
+	flag.IntVar(&t.SocketWaitSeconds, "target-socket-wait-seconds", 10, "Max time in seconds to wait at startup for the target socket to be created, in case the target creates it after mittens starts")
 }
 
 func toIntOrDefaultIfNull(value *int, defaultValue int) int {
@@ -59,6 +78,110 @@ func toIntOrDefaultIfNull(value *int, defaultValue int) int {
 	return *value
 }
 
+// precheckAddresses returns the addresses of the HTTP and/or gRPC ports of the target, or its Unix socket if set.
+func (t *Target) precheckAddresses(http, grpc bool) ([]warmup.Address, error) {
+	// already validated on startup
+	if socket, _ := t.unixSocket(); socket != "" {
+		return []warmup.Address{{Network: "unix", Address: socket}}, nil
+	}
+
+	var addresses []warmup.Address
+	if http {
+		u, err := url.Parse(t.HTTPHost)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, warmup.Address{Network: "tcp", Address: net.JoinHostPort(u.Hostname(), strconv.Itoa(t.HTTPPort))})
+	}
+	if grpc {
+		address := t.grpcAddress(t.GrpcPort)
+		// drop the resolver scheme, if any e.g. dns:///my-service
+		if i := strings.Index(address, ":///"); i != -1 {
+			address = address[i+4:]
+		}
+		addresses = append(addresses, warmup.Address{Network: "tcp", Address: address})
+	}
+	return addresses, nil
+}
+
+// unixSocket returns the path of the Unix domain socket of the target, if any.
+// The socket can be set as unix:///path or /path.
+func (t *Target) unixSocket() (string, error) {
+	if t.Socket == "" {
+		return "", nil
+	}
+	if strings.HasPrefix(t.Socket, "unix://") {
+		return strings.TrimPrefix(t.Socket, "unix://"), nil
+	}
+	if strings.HasPrefix(t.Socket, "/") {
+		return t.Socket, nil
+	}
+	return "", fmt.Errorf("invalid target socket %s, expected format unix:///path or /path", t.Socket)
+}
+
+// caCertPool returns a pool with the CA certificates in the tls-ca-cert files, or nil if none is set.
+// Directories are expanded to the files they contain. An error is returned if any file has no certificates or cannot be parsed.
+func (t *Target) caCertPool() (*x509.CertPool, error) {
+	if len(t.CACerts) == 0 {
+		return nil, nil
+	}
+
+	var files []string
+	for _, path := range t.CACerts {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("tls-ca-cert: %v", err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("tls-ca-cert: %v", err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		if err := appendCertsFromPEMFile(pool, file); err != nil {
+			return nil, fmt.Errorf("tls-ca-cert %s: %v", file, err)
+		}
+	}
+	return pool, nil
+}
+
+// appendCertsFromPEMFile adds the certificates in the file to the pool.
+// Unlike x509.CertPool.AppendCertsFromPEM, it fails if any PEM block is not a valid certificate.
+func appendCertsFromPEMFile(pool *x509.CertPool, file string) error {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("unexpected PEM block of type %s", block.Type)
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return err
+		}
+		pool.AddCert(certificate)
+		count++
+	}
+	if count == 0 {
+		return fmt.Errorf("no PEM certificates found")
+	}
+	return nil
+}
+
 func (t *Target) getWarmupTargetOptions() warmup.TargetOptions {
 
 	return warmup.TargetOptions{
@@ -70,18 +193,57 @@ func (t *Target) getWarmupTargetOptions() warmup.TargetOptions {
 	}
 }
 
-func (t *Target) getReadinessHTTPClient() http.Client {
-	return http.NewClient(fmt.Sprintf("%s:%d", t.HTTPHost, t.ReadinessPort), t.Insecure)
+func (t *Target) getReadinessHTTPClient(transportConfig http.TransportConfig) http.Client {
+	// already validated on startup
+	transportConfig.UnixSocket, _ = t.unixSocket()
+	transportConfig.RootCAs, _ = t.caCertPool()
+	transportConfig.TLSServerName = t.TLSServerName
+	return http.NewClient(fmt.Sprintf("%s:%d", t.HTTPHost, t.ReadinessPort), t.Insecure, transportConfig)
+}
+
+func (t *Target) getReadinessGrpcClient(opts ...grpc.ClientOption) grpc.Client {
+	return grpc.NewClient(t.grpcAddress(t.ReadinessPort), t.grpcClientOptions(t.ReadinessTimeoutSeconds, opts)...)
 }
 
-func (t *Target) getReadinessGrpcClient() grpc.Client {
-	return grpc.NewClient(fmt.Sprintf("%s:%d", t.GrpcHost, t.ReadinessPort), t.Insecure, t.ReadinessTimeoutSeconds)
+func (t *Target) getHTTPClient(transportConfig http.TransportConfig) http.Client {
+	// already validated on startup
+	transportConfig.UnixSocket, _ = t.unixSocket()
+	transportConfig.RootCAs, _ = t.caCertPool()
+	transportConfig.TLSServerName = t.TLSServerName
+	return http.NewClient(t.httpAddress(), t.Insecure, transportConfig)
 }
 
-func (t *Target) getHTTPClient() http.Client {
-	return http.NewClient(fmt.Sprintf("%s:%d", t.HTTPHost, t.HTTPPort), t.Insecure)
+// httpAddress returns the scheme, host and port of the HTTP target.
+func (t *Target) httpAddress() string {
+	return fmt.Sprintf("%s:%d", t.HTTPHost, t.HTTPPort)
 }
 
-func (t *Target) getGrpcClient(timeoutSeconds int) grpc.Client {
-	return grpc.NewClient(fmt.Sprintf("%s:%d", t.GrpcHost, t.GrpcPort), t.Insecure, timeoutSeconds)
+func (t *Target) getGrpcClient(timeoutSeconds int, opts ...grpc.ClientOption) grpc.Client {
+	return grpc.NewClient(t.grpcAddress(t.GrpcPort), t.grpcClientOptions(timeoutSeconds, opts)...)
+}
+
+// grpcAddress returns the gRPC host with the given port, keeping its resolver scheme if any e.g. dns:///my-service:50051.
+// IPv6 hosts can be set with or without brackets, and are always bracketed in the address e.g. [::1]:50051.
+func (t *Target) grpcAddress(port int) string {
+	scheme, host := "", t.GrpcHost
+	if i := strings.Index(host, ":///"); i != -1 {
+		scheme, host = host[:i+4], host[i+4:]
+	}
+	return scheme + net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(port))
+}
+
+// grpcClientOptions returns the options that apply to all the gRPC clients of the target followed by the given ones.
+func (t *Target) grpcClientOptions(timeoutSeconds int, opts []grpc.ClientOption) []grpc.ClientOption {
+	clientOptions := []grpc.ClientOption{grpc.WithTimeout(timeoutSeconds)}
+	if t.Insecure {
+		clientOptions = append(clientOptions, grpc.WithInsecure())
+	}
+	// already validated on startup
+	if socket, _ := t.unixSocket(); socket != "" {
+		clientOptions = append(clientOptions, grpc.WithUnixSocket(socket))
+	}
+	if pool, _ := t.caCertPool(); pool != nil {
+		clientOptions = append(clientOptions, grpc.WithTLS(&tls.Config{RootCAs: pool}))
+	}
+	return append(clientOptions, opts...)
 }
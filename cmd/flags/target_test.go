@@ -0,0 +1,141 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package flags
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"mittens/pkg/warmup"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_UnixSocket(t *testing.T) {
+	for _, socket := range []string{"unix:///var/run/app.sock", "/var/run/app.sock"} {
+		target := Target{Socket: socket}
+		path, err := target.unixSocket()
+		require.NoError(t, err)
+		assert.Equal(t, "/var/run/app.sock", path)
+	}
+
+	target := Target{Socket: "var/run/app.sock"}
+	_, err := target.unixSocket()
+	assert.Error(t, err)
+}
+
+func Test_PrecheckAddresses(t *testing.T) {
+	target := Target{HTTPHost: "http://localhost", HTTPPort: 8080, GrpcHost: "dns:///my-service", GrpcPort: 50051}
+	addresses, err := target.precheckAddresses(true, true)
+	require.NoError(t, err)
+	assert.Equal(t, []warmup.Address{{Network: "tcp", Address: "localhost:8080"}, {Network: "tcp", Address: "my-service:50051"}}, addresses)
+
+	addresses, err = target.precheckAddresses(false, true)
+	require.NoError(t, err)
+	assert.Equal(t, []warmup.Address{{Network: "tcp", Address: "my-service:50051"}}, addresses)
+
+	target.Socket = "unix:///var/run/app.sock"
+	addresses, err = target.precheckAddresses(true, true)
+	require.NoError(t, err)
+	assert.Equal(t, []warmup.Address{{Network: "unix", Address: "/var/run/app.sock"}}, addresses)
+}
+
+func Test_GrpcAddress(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"localhost", "localhost:50051"},
+		{"127.0.0.1", "127.0.0.1:50051"},
+		{"::1", "[::1]:50051"},
+		{"[::1]", "[::1]:50051"},
+		{"fe80::1", "[fe80::1]:50051"},
+		{"dns:///my-service", "dns:///my-service:50051"},
+		{"dns:///::1", "dns:///[::1]:50051"},
+	}
+
+	for _, tt := range tests {
+		target := Target{GrpcHost: tt.host}
+		assert.Equal(t, tt.want, target.grpcAddress(50051), tt.host)
+	}
+}
+
+func Test_CACertPool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mittens")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certs := filepath.Join(dir, "certs")
+	require.NoError(t, os.Mkdir(certs, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(certs, "a.pem"), newTestCACertificate(t, "a"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(certs, "b.pem"), append(newTestCACertificate(t, "b"), newTestCACertificate(t, "c")...), 0644))
+	single := filepath.Join(dir, "d.pem")
+	require.NoError(t, ioutil.WriteFile(single, newTestCACertificate(t, "d"), 0644))
+
+	target := Target{CACerts: stringArray{certs, single}}
+	pool, err := target.caCertPool()
+	require.NoError(t, err)
+	assert.Len(t, pool.Subjects(), 4)
+
+	target = Target{}
+	pool, err = target.caCertPool()
+	require.NoError(t, err)
+	assert.Nil(t, pool)
+}
+
+func Test_InvalidCACertPool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mittens")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	invalid := filepath.Join(dir, "invalid.pem")
+	require.NoError(t, ioutil.WriteFile(invalid, []byte("-----BEGIN CERTIFICATE-----\nbm90IGEgY2VydA==\n-----END CERTIFICATE-----\n"), 0644))
+	empty := filepath.Join(dir, "empty.pem")
+	require.NoError(t, ioutil.WriteFile(empty, []byte("not a PEM file"), 0644))
+
+	for _, file := range []string{invalid, empty, filepath.Join(dir, "missing.pem")} {
+		target := Target{CACerts: stringArray{file}}
+		_, err := target.caCertPool()
+		assert.Error(t, err, file)
+	}
+}
+
+// newTestCACertificate returns a self-signed CA certificate in PEM format.
+func newTestCACertificate(t *testing.T, name string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
@@ -1,6 +1,9 @@
 package flags
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+)
 
 type stringArray []string
 
@@ -12,3 +15,27 @@ func (s *stringArray) Set(value string) error {
 	*s = append(*s, value)
 	return nil
 }
+
+// urlValue is a flag that only accepts absolute URLs.
+type urlValue struct {
+	url *url.URL
+}
+
+func (u *urlValue) String() string {
+	if u.url == nil {
+		return ""
+	}
+	return u.url.String()
+}
+
+func (u *urlValue) Set(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("invalid URL %s, expected format <scheme>://<host>[:port]", value)
+	}
+	u.url = parsed
+	return nil
+}
@@ -16,6 +16,8 @@ package flags
 
 import (
 	"log"
+	"math/rand"
+	"sort"
 	"strings"
 )
 
@@ -34,3 +36,58 @@ func toHeaders(headersFlag []string) map[string]string {
 	}
 	return headers
 }
+
+// newWeightedPicker returns a function that picks a random index with a probability proportional to its weight.
+// Weights lower than 1 are treated as 1.
+func newWeightedPicker(weights []int) func() int {
+	cumulative := make([]int, len(weights))
+	total := 0
+	for i, weight := range weights {
+		if weight < 1 {
+			weight = 1
+		}
+		total += weight
+		cumulative[i] = total
+	}
+	return func() int {
+		// index of the first cumulative weight greater than the random number
+		return sort.SearchInts(cumulative, rand.Intn(total)+1)
+	}
+}
+
+// newShuffledPicker returns a function that picks every index as many times as its weight per round, in a random order that changes every round.
+// Weights lower than 1 are treated as 1.
+func newShuffledPicker(weights []int) func() int {
+	var round []int
+	for i, weight := range weights {
+		if weight < 1 {
+			weight = 1
+		}
+		for j := 0; j < weight; j++ {
+			round = append(round, i)
+		}
+	}
+	next := len(round)
+	return func() int {
+		if next == len(round) {
+			rand.Shuffle(len(round), func(i, j int) { round[i], round[j] = round[j], round[i] })
+			next = 0
+		}
+		next++
+		return round[next-1]
+	}
+}
+
+// newRepeatPicker returns a function that picks each of the n requests repeat times in a row, in order.
+// It returns false once all the requests have been picked.
+func newRepeatPicker(n, repeat int) func() (int, bool) {
+	picked := 0
+	return func() (int, bool) {
+		if picked >= n*repeat {
+			return 0, false
+		}
+		i := picked / repeat
+		picked++
+		return i, true
+	}
+}
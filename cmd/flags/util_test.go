@@ -58,3 +58,39 @@ func Test_HeadersWithMultipleSeparatorsToHeaders(t *testing.T) {
 	assert.Equal(t, 1, len(headers))
 	assert.Equal(t, "some:strange:cookie", headers["Cookie"])
 }
+
+func Test_WeightedPicker(t *testing.T) {
+	pick := newWeightedPicker([]int{8, 0, 2})
+
+	counts := make([]int, 3)
+	for i := 0; i < 10000; i++ {
+		counts[pick()]++
+	}
+
+	// weights lower than 1 are treated as 1, so the expected mix is 8/11, 1/11, 2/11
+	assert.InDelta(t, 7273, counts[0], 300)
+	assert.InDelta(t, 909, counts[1], 200)
+	assert.InDelta(t, 1818, counts[2], 250)
+}
+
+func Test_ShuffledPicker(t *testing.T) {
+	pick := newShuffledPicker([]int{1, 2, 0})
+
+	// every round includes each index as many times as its weight
+	for round := 0; round < 10; round++ {
+		counts := make([]int, 3)
+		for i := 0; i < 4; i++ {
+			counts[pick()]++
+		}
+		assert.Equal(t, []int{1, 2, 1}, counts)
+	}
+}
+
+func Test_RepeatPicker(t *testing.T) {
+	pick := newRepeatPicker(2, 3)
+	var picked []int
+	for i, ok := pick(); ok; i, ok = pick() {
+		picked = append(picked, i)
+	}
+	assert.Equal(t, []int{0, 0, 0, 1, 1, 1}, picked)
+}
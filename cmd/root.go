@@ -15,17 +15,24 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
 	"math/rand"
 	"mittens/cmd/flags"
+	"mittens/pkg/grpc"
+	whttp "mittens/pkg/http"
 	"mittens/pkg/probe"
 	"mittens/pkg/warmup"
+	"net/http"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
+
+	"google.golang.org/grpc/codes"
 )
 
 var opts *flags.Root
@@ -41,6 +48,15 @@ func CreateConfig() {
 // RunCmdRoot runs the main logic.
 func RunCmdRoot() {
 	var probeServer *probe.Server
+	var reWarmup func()
+
+	rand.Seed(time.Now().UnixNano()) // initialize seed only once to prevent deterministic/repeated calls every time we run
+
+	if err := opts.ValidateWarmupRequests(); err != nil {
+		log.Fatalf("Invalid warm up requests: %v", err)
+	}
+
+	shutdown := notifyShutdown()
 
 	if opts.ServerProbe.Enabled {
 		probeServer = startServerProbe(
@@ -54,29 +70,155 @@ func RunCmdRoot() {
 		probe.WriteFile(opts.FileProbe.LivenessPath)
 	}
 
+	stats := &warmup.Stats{}
+	if opts.HealthzPort > 0 {
+		startProgressServer(opts.HealthzPort, stats)
+	}
+
 	if targetOptions, err := opts.GetWarmupTargetOptions(); err == nil {
-		requestsSentCounter := 0
-		target := createTarget(targetOptions)
-		if err := target.WaitForReadinessProbe(); err == nil {
-			wp := warmup.Warmup{Target: target, MaxDurationSeconds: opts.GetMaxDurationSeconds(), Concurrency: opts.GetConcurrency()}
-			runWarmup(wp, &requestsSentCounter)
-		} else {
-			log.Print("Target still not ready. Giving up!")
+		cfg := warmupConfig(createTarget(targetOptions), shutdown, stats)
+		result, err := warmup.Run(context.Background(), cfg)
+
+		select {
+		case <-shutdown:
+			// the workers have stopped, so all the requests in flight have been recorded
+			exitAfterShutdown(stats.RequestsFailedAtShutdown())
+		default:
+		}
+
+		if err == warmup.ErrMaxWarmupDurationExceeded {
+			reportCompletion(stats, result.Duration)
+			if opts.SummaryFormat != "json" && result.RequestsSent > 0 {
+				logRequestStats(stats)
+			}
+			log.Fatalf("🛑 Warm up aborted after exceeding the max warm up duration of %d seconds. %d requests completed, %d failed",
+				opts.MaxWarmupDurationSeconds, result.RequestsSent, result.RequestsFailed)
+		}
+
+		postProcess(stats, result.Duration, probeServer)
+		if result.Ready && opts.ReWarmupIntervalSeconds > 0 {
+			reWarmup = func() { reWarmupPeriodically(cfg, time.Duration(opts.ReWarmupIntervalSeconds)*time.Second) }
 		}
 
-		postProcess(requestsSentCounter, probeServer)
+		if opts.FailOnError && result.RequestsFailed > 0 {
+			log.Fatalf("🛑 %d warm up requests failed", result.RequestsFailed)
+		}
+		if opts.MinSuccessRate > 0 && result.SuccessRate < opts.MinSuccessRate {
+			log.Fatalf("🛑 Warm up success rate %.3f is below the min success rate of %.3f", result.SuccessRate, opts.MinSuccessRate)
+		}
 	}
 
-	// Block forever if we don't want to wait after the warmup finishes
+	// Keep running until shutdown if we don't want to exit after the warmup finishes
 	if !opts.ExitAfterWarmup {
-		select {}
+		if reWarmup != nil {
+			reWarmup()
+		} else {
+			<-shutdown
+		}
+		log.Print("Shut down")
+	}
+}
+
+// notifyShutdown returns a channel that is closed once SIGINT or SIGTERM is received, so that mittens can shut down gracefully.
+// If the signal is received a second time, mittens exits straight away without waiting for the requests in flight.
+func notifyShutdown() <-chan struct{} {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	shutdown := make(chan struct{})
+	go func() {
+		sig := <-sigs
+		log.Printf("Received %s signal, shutting down once the requests in flight complete", sig)
+		close(shutdown)
+		sig = <-sigs
+		log.Fatalf("🛑 Received %s signal again, exiting without waiting for the requests in flight", sig)
+	}()
+	return shutdown
+}
+
+// exitAfterShutdown exits once the requests in flight when SIGINT or SIGTERM was received have completed.
+// The exit code is non-zero if any of them failed, e.g. because it was cancelled once its deadline was exceeded.
+func exitAfterShutdown(failed int) {
+	if failed > 0 {
+		log.Fatalf("🛑 Shut down: %d requests in flight failed", failed)
+	}
+	log.Print("Shut down: all the requests in flight completed")
+	os.Exit(0)
+}
+
+// warmupConfig returns the config of the warm up of the target as set in the flags.
+// The requests are read from the flags once the target is ready, e.g. so that the OpenAPI spec can be fetched from it.
+func warmupConfig(target warmup.Target, shutdown <-chan struct{}, stats *warmup.Stats) warmup.Config {
+	// already validated on startup
+	delay, _ := opts.GetRequestDelay()
+
+	return warmup.Config{
+		Warmup: warmup.Warmup{Target: target, MaxDurationSeconds: opts.GetMaxDurationSeconds(), Concurrency: opts.GetConcurrency(), HTTPFailOnStatus: opts.GetHTTPFailOnStatus(),
+			WarnLatencyThreshold: time.Duration(opts.WarnLatencyThresholdMs) * time.Millisecond, Retry: opts.GetRetry(),
+			HTTPCookieJarPerWorker: opts.GetHTTPCookieJarPerWorker(), HTTPAuthorization: opts.GetHTTPAuthorization(), Tracer: opts.GetTracer(), Shutdown: shutdown},
+		HTTPRequests: func(ctx context.Context) (<-chan whttp.Request, int, error) {
+			return opts.GetWarmupHTTPRequests(ctx)
+		},
+		HTTPHeaders: opts.GetWarmupHTTPHeaders(),
+		GrpcRequests: func(ctx context.Context) (<-chan grpc.Request, int, error) {
+			return opts.GetWarmupGrpcRequests(ctx)
+		},
+		GrpcHeaders:         opts.GetWarmupGrpcHeaders(),
+		Delay:               delay,
+		Precheck:            precheck,
+		ConnectionsOnly:     opts.ConnectionsOnly,
+		ConnectionsOnlyPath: opts.ConnectionsOnlyPath,
+		MaxWarmupDuration:   time.Duration(opts.MaxWarmupDurationSeconds) * time.Second,
+		Stats:               stats,
+	}
+}
+
+// reWarmupPeriodically repeats the warm up every interval after the previous one finishes, so that the caches of the target are kept hot.
+// Each warm up has its own stats and, if max-warmup-duration-seconds is set, its own deadline. The target is not checked again before them.
+// It returns on shutdown, once the requests in flight of the warm up in progress, if any, complete.
+func reWarmupPeriodically(cfg warmup.Config, interval time.Duration) {
+	cfg.Precheck = nil
+	cfg.SkipReadinessProbe = true
+	cfg.Stats = nil
+	for round := 1; ; round++ {
+		log.Printf("Next warm up in %v", interval)
+		select {
+		case <-cfg.Warmup.Shutdown:
+			return
+		case <-time.After(interval):
+		}
+
+		log.Printf("Starting re-warm up #%d", round)
+		result, _ := warmup.Run(context.Background(), cfg)
+		log.Printf("Re-warm up #%d finished. Approximately %d reqs were sent, %d failed", round, result.RequestsSent, result.RequestsFailed)
 	}
 }
 
+// precheck waits for the Unix socket of the target to be created, if set, and for the ports of the target to be open, if precheck-timeout-seconds is set.
+func precheck(ctx context.Context) error {
+	if socket := opts.GetUnixSocket(); socket != "" {
+		if err := warmup.WaitForSocket(ctx, socket, time.Duration(opts.SocketWaitSeconds)*time.Second); err != nil {
+			return err
+		}
+	}
+	if opts.PrecheckTimeoutSeconds <= 0 {
+		return nil
+	}
+	addresses, err := opts.GetPrecheckAddresses()
+	if err != nil {
+		return err
+	}
+	return warmup.WaitForPorts(ctx, addresses, time.Duration(opts.PrecheckTimeoutSeconds)*time.Second)
+}
+
 // postProcess includes steps that run once the warmup finishes.
 // For now this either announces that the app is ready or fails the readiness probe.
 // The latter only happens if mittens did not send any requests and the user allows the readiness to fail.
-func postProcess(requestsSentCounter int, probeServer *probe.Server) {
+// If a completion URL is set, it is notified regardless of the outcome.
+// If the summary format is json, the summary is printed to stdout instead of logging the stats per request.
+func postProcess(stats *warmup.Stats, duration time.Duration, probeServer *probe.Server) {
+	requestsSentCounter := stats.RequestsSent()
+	reportCompletion(stats, duration)
+
 	if opts.FailReadiness && requestsSentCounter == 0 {
 		log.Print("🛑 Warmup did not run. Mittens readiness probe will fail 🙁")
 	} else {
@@ -84,6 +226,9 @@ func postProcess(requestsSentCounter int, probeServer *probe.Server) {
 			log.Print("🛑 Warm up finished but no requests were sent 🙁")
 		} else {
 			log.Printf("Warm up finished 😊 Approximately %d reqs were sent", requestsSentCounter)
+			if opts.SummaryFormat != "json" {
+				logRequestStats(stats)
+			}
 		}
 
 		if opts.ServerProbe.Enabled {
@@ -95,33 +240,62 @@ func postProcess(requestsSentCounter int, probeServer *probe.Server) {
 	}
 }
 
-// runWarmup sends requests to the target using goroutines.
-func runWarmup(wp warmup.Warmup, requestsSentCounter *int) {
-	rand.Seed(time.Now().UnixNano()) // initialize seed only once to prevent deterministic/repeated calls every time we run
+// reportCompletion notifies the completion URL, if set, and prints the JSON summary, if enabled.
+// It is called both when the warm up finishes and when it is aborted after exceeding the max warm up duration.
+func reportCompletion(stats *warmup.Stats, duration time.Duration) {
+	if opts.CompletionURL != "" {
+		if err := warmup.NotifyCompletion(opts.CompletionURL, duration, stats.RequestsFailed()); err != nil {
+			log.Printf("Completion URL: %v", err)
+		}
+	}
 
-	httpRequests, err := opts.GetWarmupHTTPRequests()
-	if err != nil {
-		log.Printf("HTTP options: %v", err)
+	if opts.SummaryFormat == "json" {
+		printSummary(stats.Summary(duration))
 	}
-	grpcRequests, err := opts.GetWarmupGrpcRequests()
+}
+
+// printSummary prints the summary of the warm up to stdout as JSON, so that it can be parsed separately from the logs.
+func printSummary(summary warmup.Summary) {
+	output, err := json.Marshal(summary)
 	if err != nil {
-		log.Printf("Grpc options: %v", err)
+		log.Printf("Summary: %v", err)
+		return
 	}
+	fmt.Println(string(output))
+}
 
-	var wg sync.WaitGroup
-	for i := 1; i <= opts.Concurrency; i++ {
-		log.Printf("Spawning new go routine for HTTP requests")
-		wg.Add(1)
-		go wp.HTTPWarmupWorker(&wg, httpRequests, opts.GetWarmupHTTPHeaders(), opts.RequestDelayMilliseconds, requestsSentCounter)
+// logRequestStats logs the number of requests sent and their latency per request name,
+// so that the achieved mix can be compared with the weights and slow requests can be spotted.
+// It also logs the number of responses per status code, so that e.g. a fast 404 is not mistaken for a warm endpoint,
+// and the number of requests that failed without a response per cause e.g. dial timeout or connection refused.
+// If the timing breakdown is enabled, it also logs the latency percentiles of each phase of the HTTP requests, e.g. DNS or TLS handshake.
+// If a latency threshold is set, it also logs the number of responses slower than that.
+func logRequestStats(stats *warmup.Stats) {
+	total := stats.RequestsSent() + stats.RequestsFailed()
+	for _, requestStats := range stats.PerName() {
+		count := requestStats.Sent + requestStats.Failed
+		log.Printf("%s: %d reqs (%.1f%%), %d failed, latency min/mean/max %d/%d/%d ms, %d bytes sent, %d bytes read (%d decompressed), %d redirects", requestStats.Name, count, float64(count)*100/float64(total), requestStats.Failed,
+			requestStats.MinLatency/time.Millisecond, requestStats.MeanLatency/time.Millisecond, requestStats.MaxLatency/time.Millisecond, requestStats.BytesSent, requestStats.BytesRead, requestStats.BytesDecompressed, requestStats.Redirects)
 	}
-
-	for i := 1; i <= opts.Concurrency; i++ {
-		log.Printf("Spawning new go routine for gRPC requests")
-		wg.Add(1)
-		go wp.GrpcWarmupWorker(&wg, grpcRequests, opts.GetWarmupGrpcHeaders(), opts.RequestDelayMilliseconds, requestsSentCounter)
+	for _, statusCode := range stats.StatusCodes() {
+		if statusCode.Type == "grpc" {
+			log.Printf("grpc status %s: %d reqs", codes.Code(statusCode.StatusCode), statusCode.Count)
+			continue
+		}
+		log.Printf("%s status %d: %d reqs", statusCode.Type, statusCode.StatusCode, statusCode.Count)
+	}
+	for _, errorCause := range stats.ErrorCauses() {
+		log.Printf("%s: %d reqs", errorCause.Cause, errorCause.Count)
+	}
+	for _, phase := range stats.Phases() {
+		log.Printf("HTTP %s latency p50/p95/p99 %.1f/%.1f/%.1f ms", phase.Phase, phase.P50LatencyMs, phase.P95LatencyMs, phase.P99LatencyMs)
+	}
+	if retries := stats.Retries(); retries > 0 {
+		log.Printf("Retries: %d", retries)
+	}
+	if opts.WarnLatencyThresholdMs > 0 {
+		log.Printf("Slow requests over %d ms: %d reqs", opts.WarnLatencyThresholdMs, stats.SlowRequests())
 	}
-
-	wg.Wait()
 }
 
 // createTarget creates the target versus which mittens will run.
@@ -162,3 +336,20 @@ func startServerProbe(port int, livenessPath, readinessPath string) *probe.Serve
 	}()
 	return probeServer
 }
+
+// startProgressServer starts a web server that reports the warm up progress on /healthz.
+func startProgressServer(port int, stats *warmup.Stats) *probe.ProgressServer {
+	progressServer := probe.NewProgressServer(port, func() probe.Progress {
+		progress := probe.Progress{Done: stats.Done(), RequestsSent: stats.RequestsSent() + stats.RequestsFailed()}
+		if total, ok := stats.TotalRequests(); ok {
+			progress.TotalRequests = &total
+		}
+		return progress
+	})
+	go func() {
+		if err := progressServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Progress server: %v", err)
+		}
+	}()
+	return progressServer
+}
@@ -1,15 +1,28 @@
 module mittens
 
 require (
-	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fullstorydev/grpcurl v1.6.0
+	github.com/golang/protobuf v1.3.5
 	github.com/jhump/protoreflect v1.7.0
 	github.com/stretchr/testify v1.6.1
-	golang.org/x/lint v0.0.0-20200302205851-738671d3881b // indirect
-	golang.org/x/net v0.0.0-20200625001655-4c5254603344
-	golang.org/x/text v0.3.2 // indirect
-	golang.org/x/tools v0.0.0-20200709181711-e327e1019dfe // indirect
+	golang.org/x/net v0.1.0
 	google.golang.org/grpc v1.30.0
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
+)
+
+require (
+	cloud.google.com/go v0.26.0 // indirect
+	github.com/cncf/udpa/go v0.0.0-20191209042840-269d4d468f6f // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/envoyproxy/go-control-plane v0.9.4 // indirect
+	github.com/envoyproxy/protoc-gen-validate v0.1.0 // indirect
+	github.com/google/go-cmp v0.4.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/oauth2 v0.0.0-20180821212333-d2e6202438be // indirect
+	golang.org/x/sys v0.1.0 // indirect
+	golang.org/x/text v0.4.0 // indirect
+	google.golang.org/appengine v1.4.0 // indirect
+	google.golang.org/genproto v0.0.0-20190819201941-24fa4b261c55 // indirect
 )
 
-go 1.14
+go 1.17
@@ -15,40 +15,191 @@
 package grpc
 
 import (
-	"bytes"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"log"
+	"mittens/pkg/placeholders"
 	"mittens/pkg/response"
-	"os"
+	"net"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fullstorydev/grpcurl"
 	"github.com/jhump/protoreflect/grpcreflect"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
 	reflectpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
 )
 
+const roundRobinPolicy = "round_robin"
+
 // Client represents a gRPC client.
 type Client struct {
-	host             string
-	timeoutSeconds   int
-	insecure         bool
-	grpcConnectOnce  *sync.Once
-	connClose        func() error
-	conn             *grpc.ClientConn
+	host           string
+	hostErr        error
+	timeoutSeconds int
+	requestTimeout time.Duration
+	insecure       bool
+	tlsConfig      *tls.Config
+	authority      string
+	loadBalancing  bool
+	unixSocket     string
+	serviceConfig  string
+	keepalive      *keepalive.ClientParameters
+	maxRecvMsgSize int
+	maxSendMsgSize int
+	poolSize       int
+	// maxStreamMessages is the number of responses after which server-streaming calls are cancelled, if set
+	maxStreamMessages int
+	// captureResponses records the responses in the body of the results, formatted as JSON
+	captureResponses bool
+	descriptors      *Descriptors
+	reflection       reflectionRetry
+	pool             *connPool
+}
+
+// reflectionRetry controls how many times the reflection service is checked once connected, waiting backoff before the first retry
+// and doubling it before each of the next ones. Zero retries means that it is not checked.
+type reflectionRetry struct {
+	retries int
+	backoff time.Duration
+}
+
+// connPool holds the connections of a client. It is shared by all the copies of the client,
+// so that the workers connect only once and requests are spread across the same connections.
+// If the connections are lost, e.g. because the server restarted, they are closed and dialed again by the next request.
+// Failed dials are retried with a backoff that doubles up to maxReconnectBackoff, failing the requests in between.
+type connPool struct {
+	next             uint64 // first field so that it is 64-bit aligned for atomic operations on 32-bit platforms
+	mu               sync.Mutex
+	connected        bool
+	connErr          error
+	retryAt          time.Time
+	backoff          time.Duration
+	reconnects       int
+	conns            []*grpc.ClientConn
+	cancel           context.CancelFunc
 	descriptorSource grpcurl.DescriptorSource
 }
 
-// NewClient returns a gRPC client.
-func NewClient(host string, insecure bool, timeoutSeconds int) Client {
-	return Client{host: host, timeoutSeconds: timeoutSeconds, grpcConnectOnce: new(sync.Once), insecure: insecure, connClose: func() error { return nil }}
+const (
+	initialReconnectBackoff = 500 * time.Millisecond
+	maxReconnectBackoff     = 10 * time.Second
+	lostConnTimeout         = 100 * time.Millisecond
+)
+
+// nextConn returns the connections of the pool in round robin. It must be called holding the lock.
+func (p *connPool) nextConn() *grpc.ClientConn {
+	if len(p.conns) == 1 {
+		return p.conns[0]
+	}
+	return p.conns[(atomic.AddUint64(&p.next, 1)-1)%uint64(len(p.conns))]
+}
+
+// close closes the connections of the pool. It must be called holding the lock.
+func (p *connPool) close() error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	var closeErr error
+	for _, conn := range p.conns {
+		if err := conn.Close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+	p.conns = nil
+	p.connected = false
+	return closeErr
+}
+
+// conn returns the next connection of the pool and the source of the descriptors of the services,
+// connecting first if the pool is not connected yet or was disconnected.
+func (c *Client) conn(headers []string) (*grpc.ClientConn, grpcurl.DescriptorSource, error) {
+	c.pool.mu.Lock()
+	defer c.pool.mu.Unlock()
+	if !c.pool.connected {
+		if c.pool.connErr != nil && time.Now().Before(c.pool.retryAt) {
+			return nil, nil, c.pool.connErr
+		}
+		if c.pool.connErr = c.connect(headers); c.pool.connErr != nil {
+			c.pool.backoff = nextReconnectBackoff(c.pool.backoff)
+			c.pool.retryAt = time.Now().Add(c.pool.backoff)
+			return nil, nil, c.pool.connErr
+		}
+		c.pool.connected = true
+		c.pool.backoff = 0
+	}
+	return c.pool.nextConn(), c.pool.descriptorSource, nil
+}
+
+// nextReconnectBackoff doubles the backoff up to maxReconnectBackoff.
+func nextReconnectBackoff(backoff time.Duration) time.Duration {
+	if backoff == 0 {
+		return initialReconnectBackoff
+	}
+	if backoff *= 2; backoff > maxReconnectBackoff {
+		return maxReconnectBackoff
+	}
+	return backoff
+}
+
+// disconnectIfLost closes the connections of the pool if conn, over which a request just failed with UNAVAILABLE or without a status,
+// is no longer connected, so that the next request dials them again. As the state of conn may not have changed yet when the request fails,
+// it waits up to lostConnTimeout for conn to stop being ready. Requests that fail while conn stays connected, e.g. because the server
+// responded with UNAVAILABLE, do not close them. Neither do the requests sent over the connections of a pool that has already been dialed again.
+// It is meant to be run in its own goroutine, so that requests do not wait for it.
+func (c *Client) disconnectIfLost(conn *grpc.ClientConn, resp response.Response) {
+	if resp.StatusCode != int(codes.Unavailable) && resp.StatusCode != 0 {
+		return
+	}
+	if state := conn.GetState(); state == connectivity.Ready {
+		ctx, cancel := context.WithTimeout(context.Background(), lostConnTimeout)
+		changed := conn.WaitForStateChange(ctx, state)
+		cancel()
+		if !changed {
+			return
+		}
+	}
+
+	c.pool.mu.Lock()
+	defer c.pool.mu.Unlock()
+	for _, current := range c.pool.conns {
+		if current == conn {
+			c.pool.reconnects++
+			log.Printf("gRPC client: connection to %s lost (%v), reconnecting (reconnect %d)", c.host, resp.Err, c.pool.reconnects)
+			c.pool.close()
+			return
+		}
+	}
+}
+
+// NewClient returns a gRPC client configured with the given options.
+// By default the client uses TLS with the system CA certificates and a timeout of 10 seconds.
+// IPv6 addresses with a port must be in brackets e.g. [::1]:50051. If the host is ambiguous e.g. ::1:50051, connecting fails with an error.
+func NewClient(host string, opts ...ClientOption) Client {
+	normalized, err := normalizeHost(host)
+	client := Client{host: normalized, hostErr: err, timeoutSeconds: defaultTimeoutSeconds, poolSize: 1, pool: &connPool{}}
+	for _, opt := range opts {
+		opt(&client)
+	}
+	return client
 }
 
-// connect attempts to establish a connection with a gRPC server.
+// connect attempts to establish the connections of the pool with a gRPC server.
+// If the pool has more than one connection, they are established concurrently and all of them must succeed.
 func (c *Client) connect(headers []string) error {
+	if c.hostErr != nil {
+		return c.hostErr
+	}
 
 	dialTime := 10 * time.Second
 
@@ -62,59 +213,259 @@ func (c *Client) connect(headers []string) error {
 	if c.insecure {
 		log.Print("gRPC client: insecure")
 		dialOptions = append(dialOptions, grpc.WithInsecure())
+	} else {
+		tlsConfig := c.tlsConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName != "" {
+			log.Printf("gRPC client: TLS server name %s", tlsConfig.ServerName)
+		}
+		dialOptions = append(dialOptions, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	}
+	if c.authority != "" {
+		log.Printf("gRPC client: authority %s", c.authority)
+		dialOptions = append(dialOptions, grpc.WithAuthority(c.authority))
+	}
+
+	if c.unixSocket != "" {
+		log.Printf("gRPC client: using Unix socket %s", c.unixSocket)
+		dialOptions = append(dialOptions, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", c.unixSocket)
+		}))
+	}
+
+	if c.keepalive != nil {
+		log.Printf("gRPC client: keepalive every %v, timeout %v", c.keepalive.Time, c.keepalive.Timeout)
+		dialOptions = append(dialOptions, grpc.WithKeepaliveParams(*c.keepalive))
+	}
+
+	var callOptions []grpc.CallOption
+	if c.maxRecvMsgSize > 0 {
+		log.Printf("gRPC client: max receive message size %d bytes", c.maxRecvMsgSize)
+		callOptions = append(callOptions, grpc.MaxCallRecvMsgSize(c.maxRecvMsgSize))
+	}
+	if c.maxSendMsgSize > 0 {
+		log.Printf("gRPC client: max send message size %d bytes", c.maxSendMsgSize)
+		callOptions = append(callOptions, grpc.MaxCallSendMsgSize(c.maxSendMsgSize))
+	}
+	if len(callOptions) > 0 {
+		dialOptions = append(dialOptions, grpc.WithDefaultCallOptions(callOptions...))
 	}
 
-	log.Printf("gRPC client connecting to %s", c.host)
-	conn, err := grpc.DialContext(connCtx, c.host, dialOptions...)
+	target := c.host
+	if c.loadBalancing {
+		if !strings.Contains(target, ":///") {
+			target = "dns:///" + target
+		}
+		log.Print("gRPC client: round robin load balancing")
+	}
+	if c.serviceConfig != "" {
+		log.Printf("gRPC client: service config %s", c.serviceConfig)
+	}
+	serviceConfig, err := c.defaultServiceConfig()
 	if err != nil {
-		return fmt.Errorf("gRPC dial: %v", err)
+		cancel()
+		return err
+	}
+	if serviceConfig != "" {
+		dialOptions = append(dialOptions, grpc.WithDefaultServiceConfig(serviceConfig))
 	}
 
-	reflectionClient := grpcreflect.NewClient(contextWithMetadata, reflectpb.NewServerReflectionClient(conn))
-	descriptorSource := grpcurl.DescriptorSourceFromServer(contextWithMetadata, reflectionClient)
+	log.Printf("gRPC client connecting to %s with %d connection(s)", target, c.poolSize)
+	conns := make([]*grpc.ClientConn, c.poolSize)
+	errs := make([]error, c.poolSize)
+	var wg sync.WaitGroup
+	for i := range conns {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conns[i], errs[i] = grpc.DialContext(connCtx, target, dialOptions...)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			for _, conn := range conns {
+				if conn != nil {
+					conn.Close()
+				}
+			}
+			cancel()
+			return fmt.Errorf("gRPC dial: %v", err)
+		}
+	}
+
+	var descriptorSource grpcurl.DescriptorSource
+	if c.descriptors != nil {
+		log.Printf("gRPC client: using %s instead of server reflection", c.descriptors.origin)
+		descriptorSource = c.descriptors.source
+	} else {
+		reflectionClient := grpcreflect.NewClient(contextWithMetadata, reflectpb.NewServerReflectionClient(conns[0]))
+		if c.reflection.retries > 0 {
+			if err := c.reflection.wait(contextWithMetadata, reflectionClient); err != nil {
+				for _, conn := range conns {
+					conn.Close()
+				}
+				cancel()
+				return err
+			}
+		}
+		descriptorSource = grpcurl.DescriptorSourceFromServer(contextWithMetadata, reflectionClient)
+	}
 
 	log.Print("gRPC client connected")
-	c.conn = conn
-	c.connClose = func() error { cancel(); return conn.Close() }
-	c.descriptorSource = descriptorSource
+	c.pool.conns = conns
+	c.pool.cancel = cancel
+	c.pool.descriptorSource = descriptorSource
 	return nil
 }
 
+// defaultServiceConfig returns the service config used unless the name resolver provides one, if any.
+// If load balancing is enabled, round robin is added to it unless it already sets a load balancing policy.
+func (c *Client) defaultServiceConfig() (string, error) {
+	if !c.loadBalancing {
+		return c.serviceConfig, nil
+	}
+	config := make(map[string]interface{})
+	if c.serviceConfig != "" {
+		if err := json.Unmarshal([]byte(c.serviceConfig), &config); err != nil {
+			return "", fmt.Errorf("gRPC service config: %v", err)
+		}
+	}
+	_, hasPolicy := config["loadBalancingPolicy"]
+	_, hasConfig := config["loadBalancingConfig"]
+	if !hasPolicy && !hasConfig {
+		config["loadBalancingPolicy"] = roundRobinPolicy
+	}
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("gRPC service config: %v", err)
+	}
+	return string(encoded), nil
+}
+
+// wait lists the services of the server until it succeeds, as the reflection service of slow-starting servers, e.g. JVM ones,
+// may not be ready as soon as the connection is. It returns an error once the retries are exhausted or the context is done.
+// Servers that do not support reflection are not retried.
+func (r reflectionRetry) wait(ctx context.Context, reflectionClient *grpcreflect.Client) error {
+	backoff := r.backoff
+	for attempt := 0; ; attempt++ {
+		services, err := reflectionClient.ListServices()
+		if err == nil {
+			log.Printf("gRPC client: reflection ready, %d services", len(services))
+			return nil
+		}
+		if status.Code(err) == codes.Unimplemented {
+			return fmt.Errorf("gRPC reflection: the server does not support reflection, set grpc-protoset or grpc-proto instead: %v", err)
+		}
+		if attempt >= r.retries {
+			return fmt.Errorf("gRPC reflection: not ready after %d retries: %v", r.retries, err)
+		}
+
+		log.Printf("gRPC client: reflection not ready, retrying in %d ms (retry %d of %d): %v", backoff/time.Millisecond, attempt+1, r.retries, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gRPC reflection: not ready: %v", ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
 // SendRequest sends a request to the gRPC server and wraps useful information into a Response object.
 // Note that the message cannot be null. Even if there is no message to be sent this needs to be set to an empty string.
-func (c *Client) SendRequest(serviceMethod string, message string, headers []string) response.Response {
+// The message is sent as is, so messages read from files e.g. with ToGrpcRequest must be loaded before calling it.
+// Client-streaming methods can be sent several messages in the same call, either concatenated e.g. one per line or as a JSON array.
+// Server-streaming responses are consumed until the stream completes, or until WithMaxStreamMessages responses have been received.
+// The responses are not printed. With WithCaptureResponses they are returned in the body of the result instead.
+// The duration of the response is the time until the call completed.
+// The request is cancelled if the context is done before the response is received, or once the WithRequestTimeout elapses,
+// in which case the error cause of the response is cancelled or timeout respectively.
+// The status code of the response is the gRPC status code e.g. 0 for OK or 14 for UNAVAILABLE. Responses with a status other than OK,
+// and invocations that fail without a status e.g. because the method does not exist, have an error.
+// Placeholders in the message and the header values are replaced every time a request is sent, so that e.g. each request has its own correlation ID.
+// Their values are escaped in the message, so that it remains valid JSON.
+func (c *Client) SendRequest(ctx context.Context, serviceMethod string, message string, headers []string) response.Response {
 	const respType = "grpc"
-	var connErr error
-	c.grpcConnectOnce.Do(func() {
-		connErr = c.connect(headers)
-	})
-
-	if connErr != nil {
-		log.Printf("gRPC client connect: %v", connErr)
-		return response.Response{Duration: time.Duration(0), Err: connErr, Type: respType}
+	message, err := placeholders.InterpolateJSON(message)
+	if err != nil {
+		log.Printf("gRPC client: %v", err)
+		return response.Response{Duration: time.Duration(0), Err: err, Type: respType}
+	}
+	headers, err = InterpolateHeaders(headers)
+	if err != nil {
+		log.Printf("gRPC client: %v", err)
+		return response.Response{Duration: time.Duration(0), Err: err, Type: respType}
 	}
 
-	in := bytes.NewBufferString(message)
-
-	// TODO - create generic parser and formatter for any request, can we use text parser/formatter?
-	requestParser, formatter, err := grpcurl.RequestParserAndFormatterFor("json", c.descriptorSource, false, false, in)
+	conn, descriptorSource, err := c.conn(headers)
 	if err != nil {
-		log.Printf("Cannot construct request parser and formatter for json")
-		// FIXME FATAL
+		log.Printf("gRPC client connect: %v", err)
 		return response.Response{Duration: time.Duration(0), Err: err, Type: respType}
 	}
-	loggingEventHandler := grpcurl.NewDefaultEventHandler(os.Stdout, c.descriptorSource, formatter, false)
+
+	if serviceMethod == HealthCheckMethod {
+		// health checks do not depend on server reflection or the descriptors set, so that servers without the health service respond with UNIMPLEMENTED
+		if descriptorSource, err = healthDescriptorSource(); err != nil {
+			return response.Response{Duration: time.Duration(0), Err: err, Type: respType}
+		}
+	} else if c.descriptors != nil {
+		if err := c.descriptors.checkMethod(serviceMethod); err != nil {
+			log.Printf("gRPC client: %v", err)
+			return response.Response{Duration: time.Duration(0), Err: err, Type: respType}
+		}
+	}
+
+	parser := newMessageParser(message, grpcurl.AnyResolverFromDescriptorSource(descriptorSource))
+	callCtx, cancelCall := context.WithCancel(ctx)
+	if c.requestTimeout > 0 {
+		callCtx, cancelCall = context.WithTimeout(ctx, c.requestTimeout)
+	}
+	defer cancelCall()
 	startTime := time.Now()
-	err = grpcurl.InvokeRPC(context.Background(), c.descriptorSource, c.conn, serviceMethod, headers, loggingEventHandler, requestParser.Next)
+	handler := &eventHandler{start: startTime, maxResponses: c.maxStreamMessages, cancel: cancelCall}
+	if c.captureResponses {
+		handler.formatter = grpcurl.NewJSONFormatter(false, grpcurl.AnyResolverFromDescriptorSource(descriptorSource))
+	}
+	err = grpcurl.InvokeRPC(callCtx, descriptorSource, conn, serviceMethod, headers, handler, parser.next)
 	endTime := time.Now()
+	messagesSent := handler.messagesSent(parser.count)
 	if err != nil {
-		return response.Response{Duration: endTime.Sub(startTime), Err: nil, Type: respType}
+		resp := response.Response{Duration: endTime.Sub(startTime), Err: fmt.Errorf("gRPC invoke %s: %v", serviceMethod, err), ErrCause: errorCause(callCtx, codes.OK), Type: respType,
+			Body: handler.body.String(), MessagesSent: messagesSent, MessagesReceived: handler.responses, TimeToFirstMessage: handler.timeToFirstResponse}
+		go c.disconnectIfLost(conn, resp)
+		return resp
+	}
+	statusCode := handler.statusCode()
+	if statusCode != codes.OK {
+		resp := response.Response{Duration: endTime.Sub(startTime), Err: fmt.Errorf("gRPC status %s: %s", statusCode, handler.status.Message()), ErrCause: errorCause(callCtx, statusCode), Type: respType,
+			StatusCode: int(statusCode), Body: handler.body.String(), MessagesSent: messagesSent, MessagesReceived: handler.responses, TimeToFirstMessage: handler.timeToFirstResponse}
+		go c.disconnectIfLost(conn, resp)
+		return resp
+	}
+	return response.Response{Duration: endTime.Sub(startTime), Err: nil, Type: respType, StatusCode: int(statusCode), Body: handler.body.String(), MessagesSent: messagesSent,
+		MessagesReceived: handler.responses, TimeToFirstMessage: handler.timeToFirstResponse}
+}
+
+// errorCause returns why a request failed if it timed out, either because of its deadline or that of the server, or if its context was cancelled.
+// The causes are the same as those of HTTP requests, so that they are aggregated together. It returns an empty string otherwise.
+func errorCause(ctx context.Context, statusCode codes.Code) string {
+	switch {
+	case statusCode == codes.DeadlineExceeded || ctx.Err() == context.DeadlineExceeded:
+		return "timeout"
+	case ctx.Err() == context.Canceled:
+		return "cancelled"
 	}
-	return response.Response{Duration: endTime.Sub(startTime), Err: nil, Type: respType}
+	return ""
 }
 
-// Close calling close on a client that has not established connection does not return an error.
+// Close closes all the connections of the pool.
+// Calling close on a client that has not established connection does not return an error.
 func (c Client) Close() error {
 	log.Print("Closing gRPC client connection")
-	return c.connClose()
+	c.pool.mu.Lock()
+	defer c.pool.mu.Unlock()
+	return c.pool.close()
 }
@@ -0,0 +1,682 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package grpc
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	descpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
+)
+
+// startServer starts a gRPC server with the health and test services and reflection enabled.
+// The interceptor, if any, is called for every unary request.
+func startServer(t *testing.T, interceptor grpc.UnaryServerInterceptor) (string, func()) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	return serve(listener, interceptor)
+}
+
+// serve serves the health and test services and reflection on the listener.
+func serve(listener net.Listener, interceptor grpc.UnaryServerInterceptor) (string, func()) {
+	var opts []grpc.ServerOption
+	if interceptor != nil {
+		opts = append(opts, grpc.UnaryInterceptor(interceptor))
+	}
+	server := grpc.NewServer(opts...)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	testpb.RegisterTestServiceServer(server, &testService{})
+	reflection.Register(server)
+	go server.Serve(listener)
+
+	return listener.Addr().String(), server.Stop
+}
+
+// testService implements the streaming methods of the gRPC test service.
+type testService struct {
+	testpb.UnimplementedTestServiceServer
+}
+
+// StreamingInputCall responds with the total size of the payloads received, failing if any of them is "fail".
+func (s *testService) StreamingInputCall(stream testpb.TestService_StreamingInputCallServer) error {
+	var size int32
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&testpb.StreamingInputCallResponse{AggregatedPayloadSize: size})
+		}
+		if err != nil {
+			return err
+		}
+		if string(req.GetPayload().GetBody()) == "fail" {
+			return status.Error(codes.InvalidArgument, "invalid payload")
+		}
+		size += int32(len(req.GetPayload().GetBody()))
+	}
+}
+
+// StreamingOutputCall responds with a message for each of the response parameters, or with messages until the call is cancelled if there are none.
+func (s *testService) StreamingOutputCall(req *testpb.StreamingOutputCallRequest, stream testpb.TestService_StreamingOutputCallServer) error {
+	if len(req.GetResponseParameters()) == 0 {
+		for {
+			if err := stream.Send(&testpb.StreamingOutputCallResponse{}); err != nil {
+				return err
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for _, params := range req.GetResponseParameters() {
+		time.Sleep(time.Duration(params.GetIntervalUs()) * time.Microsecond)
+		if err := stream.Send(&testpb.StreamingOutputCallResponse{Payload: &testpb.Payload{Body: make([]byte, params.GetSize())}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestGrpc_Authority(t *testing.T) {
+	authorities := make(chan string, 1)
+	address, stop := startServer(t, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		authorities <- md.Get(":authority")[0]
+		return handler(ctx, req)
+	})
+	defer stop()
+
+	client := NewClient(address, WithInsecure(), WithTimeout(5), WithAuthority("my-service.internal"))
+	defer client.Close()
+
+	resp := client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+	require.NoError(t, resp.Err)
+	assert.Equal(t, "my-service.internal", <-authorities)
+}
+
+func TestGrpc_StatusCode(t *testing.T) {
+	address, stop := startServer(t, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if len(md.Get("fail")) > 0 {
+			return nil, status.Error(codes.Unavailable, "not ready")
+		}
+		return handler(ctx, req)
+	})
+	defer stop()
+
+	client := NewClient(address, WithInsecure(), WithTimeout(5))
+	defer client.Close()
+
+	resp := client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+	require.NoError(t, resp.Err)
+	assert.Equal(t, int(codes.OK), resp.StatusCode)
+
+	resp = client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", []string{"fail: true"})
+	require.Error(t, resp.Err)
+	assert.Contains(t, resp.Err.Error(), "Unavailable")
+	assert.Contains(t, resp.Err.Error(), "not ready")
+	assert.Equal(t, int(codes.Unavailable), resp.StatusCode)
+}
+
+func TestGrpc_InvokeError(t *testing.T) {
+	address, stop := startServer(t, nil)
+	defer stop()
+
+	client := NewClient(address, WithInsecure(), WithTimeout(5))
+	defer client.Close()
+
+	resp := client.SendRequest(context.Background(), "grpc.health.v1.Health/Missing", "", nil)
+	require.Error(t, resp.Err)
+	assert.Contains(t, resp.Err.Error(), "grpc.health.v1.Health/Missing")
+	assert.Equal(t, 0, resp.StatusCode)
+}
+
+func TestGrpc_UnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mittens")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "app.sock")
+
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	_, stop := serve(listener, nil)
+	defer stop()
+
+	client := NewClient("my-service:50051", WithInsecure(), WithTimeout(5), WithUnixSocket(socket))
+	defer client.Close()
+
+	resp := client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+	require.NoError(t, resp.Err)
+	assert.Equal(t, 0, resp.StatusCode)
+}
+
+func TestGrpc_LoadBalancing(t *testing.T) {
+	counts := make(chan string, 20)
+	countingInterceptor := func(address *string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			counts <- *address
+			return handler(ctx, req)
+		}
+	}
+	var address1, address2 string
+	address1, stop1 := startServer(t, countingInterceptor(&address1))
+	defer stop1()
+	address2, stop2 := startServer(t, countingInterceptor(&address2))
+	defer stop2()
+
+	r := manual.NewBuilderWithScheme("lbtest")
+	r.InitialState(resolver.State{Addresses: []resolver.Address{{Addr: address1}, {Addr: address2}}})
+	resolver.Register(r)
+
+	client := NewClient("lbtest:///my-service", WithInsecure(), WithTimeout(5), WithLoadBalancing())
+	defer client.Close()
+
+	for i := 0; i < 10; i++ {
+		resp := client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+		require.NoError(t, resp.Err)
+	}
+	close(counts)
+
+	requestsPerServer := make(map[string]int)
+	for address := range counts {
+		requestsPerServer[address]++
+	}
+	assert.Len(t, requestsPerServer, 2)
+	assert.Equal(t, 10, requestsPerServer[address1]+requestsPerServer[address2])
+}
+
+func TestGrpc_ClientOptions(t *testing.T) {
+	client := NewClient("localhost:50051")
+	assert.False(t, client.insecure)
+	assert.Equal(t, defaultTimeoutSeconds, client.timeoutSeconds)
+
+	client = NewClient("localhost:50051", WithInsecure(), WithTimeout(30), WithAuthority("svc"))
+	assert.True(t, client.insecure)
+	assert.Equal(t, 30, client.timeoutSeconds)
+	assert.Equal(t, "svc", client.authority)
+	assert.Nil(t, client.keepalive)
+
+	client = NewClient("localhost:50051", WithKeepalive(keepalive.ClientParameters{Time: time.Minute, Timeout: 5 * time.Second, PermitWithoutStream: true}))
+	assert.Equal(t, &keepalive.ClientParameters{Time: time.Minute, Timeout: 5 * time.Second, PermitWithoutStream: true}, client.keepalive)
+}
+
+func TestGrpc_MaxSendMsgSize(t *testing.T) {
+	address, stop := startServer(t, nil)
+	defer stop()
+
+	client := NewClient(address, WithInsecure(), WithTimeout(5), WithMaxSendMsgSize(1024), WithMaxRecvMsgSize(1024*1024))
+	defer client.Close()
+
+	resp := client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+	assert.Equal(t, int(codes.OK), resp.StatusCode)
+
+	message := fmt.Sprintf(`{"service": "%s"}`, strings.Repeat("a", 2048))
+	resp = client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", message, nil)
+	assert.Equal(t, int(codes.ResourceExhausted), resp.StatusCode)
+}
+
+func TestGrpc_MessageFileIsReadOnce(t *testing.T) {
+	address, stop := startServer(t, nil)
+	defer stop()
+
+	client := NewClient(address, WithInsecure(), WithTimeout(5))
+	defer client.Close()
+
+	file := writeTempFile(t, `{"service": ""}`)
+	defer os.Remove(file)
+	request, err := ToGrpcRequest("grpc.health.v1.Health/Check:@" + file)
+	require.NoError(t, err)
+
+	// the file is read when the request is built, so changes to it afterwards are not sent
+	require.NoError(t, ioutil.WriteFile(file, []byte("not json"), 0644))
+	resp := client.SendRequest(context.Background(), request.ServiceMethod, request.Message, nil)
+	assert.NoError(t, resp.Err)
+	assert.Equal(t, int(codes.OK), resp.StatusCode)
+
+	// and messages are sent as is rather than read from a file
+	resp = client.SendRequest(context.Background(), request.ServiceMethod, "@"+file, nil)
+	assert.Error(t, resp.Err)
+}
+
+func TestGrpc_PoolSize(t *testing.T) {
+	peers := make(chan string, 6)
+	address, stop := startServer(t, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		p, _ := peer.FromContext(ctx)
+		peers <- p.Addr.String()
+		return handler(ctx, req)
+	})
+	defer stop()
+
+	client := NewClient(address, WithInsecure(), WithTimeout(5), WithPoolSize(3))
+	defer client.Close()
+
+	for i := 0; i < 6; i++ {
+		// copies of the client, as used by the workers, share the same connections
+		worker := client
+		resp := worker.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+		require.NoError(t, resp.Err)
+		assert.Equal(t, int(codes.OK), resp.StatusCode)
+	}
+	close(peers)
+
+	requestsPerConn := make(map[string]int)
+	for addr := range peers {
+		requestsPerConn[addr]++
+	}
+	assert.Len(t, requestsPerConn, 3)
+	for _, count := range requestsPerConn {
+		assert.Equal(t, 2, count)
+	}
+	assert.Len(t, client.pool.conns, 3)
+}
+
+func TestGrpc_Protoset(t *testing.T) {
+	// the server does not support reflection, so the methods are resolved from the protoset
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	defer server.Stop()
+
+	dir, err := ioutil.TempDir("", "mittens")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	healthDescriptor, err := desc.LoadFileDescriptor("grpc/health/v1/health.proto")
+	require.NoError(t, err)
+	content, err := proto.Marshal(&descpb.FileDescriptorSet{File: []*descpb.FileDescriptorProto{healthDescriptor.AsFileDescriptorProto()}})
+	require.NoError(t, err)
+	protoset := filepath.Join(dir, "health.protoset")
+	require.NoError(t, ioutil.WriteFile(protoset, content, 0644))
+
+	descriptors, err := LoadProtosets(protoset)
+	require.NoError(t, err)
+	client := NewClient(listener.Addr().String(), WithInsecure(), WithTimeout(5), WithDescriptors(descriptors))
+	defer client.Close()
+
+	resp := client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+	require.NoError(t, resp.Err)
+	assert.Equal(t, int(codes.OK), resp.StatusCode)
+
+	resp = client.SendRequest(context.Background(), "grpc.health.v1.Health/Ping", "", nil)
+	require.Error(t, resp.Err)
+	assert.Contains(t, resp.Err.Error(), "method grpc.health.v1.Health/Ping not found in protoset")
+
+	resp = client.SendRequest(context.Background(), "my.Service/Check", "", nil)
+	require.Error(t, resp.Err)
+	assert.Contains(t, resp.Err.Error(), "service my.Service is not defined")
+
+	_, err = LoadProtosets(filepath.Join(dir, "does-not-exist.protoset"))
+	assert.Error(t, err)
+}
+
+func TestGrpc_ProtoFiles(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	defer server.Stop()
+
+	dir, err := ioutil.TempDir("", "mittens")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	imports := filepath.Join(dir, "imports")
+	require.NoError(t, os.Mkdir(imports, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(imports, "messages.proto"), []byte(`syntax = "proto3";
+package grpc.health.v1;
+message HealthCheckRequest { string service = 1; }
+message HealthCheckResponse {
+  enum ServingStatus { UNKNOWN = 0; SERVING = 1; NOT_SERVING = 2; SERVICE_UNKNOWN = 3; }
+  ServingStatus status = 1;
+}
+`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "health.proto"), []byte(`syntax = "proto3";
+package grpc.health.v1;
+import "messages.proto";
+service Health { rpc Check(HealthCheckRequest) returns (HealthCheckResponse); }
+`), 0644))
+
+	descriptors, err := LoadProtoFiles([]string{dir, imports}, "health.proto")
+	require.NoError(t, err)
+	client := NewClient(listener.Addr().String(), WithInsecure(), WithTimeout(5), WithDescriptors(descriptors))
+	defer client.Close()
+
+	resp := client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", `{"service": ""}`, nil)
+	require.NoError(t, resp.Err)
+	assert.Equal(t, int(codes.OK), resp.StatusCode)
+
+	resp = client.SendRequest(context.Background(), "grpc.health.v1.Health/Watch", "", nil)
+	require.Error(t, resp.Err)
+	assert.Contains(t, resp.Err.Error(), "method grpc.health.v1.Health/Watch not found in proto files health.proto")
+
+	// parse errors point at the file and line
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "broken.proto"), []byte("syntax = \"proto3\";\n\nservice Broken {\n"), 0644))
+	_, err = LoadProtoFiles([]string{dir}, "broken.proto")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken.proto:3:")
+}
+
+func TestGrpc_ReflectionRetries(t *testing.T) {
+	// the reflection service is not ready for the first calls, like in a slow-starting server.
+	// Note that the reflection client already retries once straight away if the stream fails
+	var reflectionCalls int32
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	server := grpc.NewServer(grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if strings.Contains(info.FullMethod, "ServerReflection") && atomic.AddInt32(&reflectionCalls, 1) <= 4 {
+			return status.Error(codes.Unavailable, "starting")
+		}
+		return handler(srv, ss)
+	}))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	reflection.Register(server)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	client := NewClient(listener.Addr().String(), WithInsecure(), WithTimeout(5), WithReflectionRetries(1, 10*time.Millisecond))
+	resp := client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+	require.Error(t, resp.Err)
+	assert.Contains(t, resp.Err.Error(), "not ready after 1 retries")
+	client.Close()
+
+	atomic.StoreInt32(&reflectionCalls, 0)
+	client = NewClient(listener.Addr().String(), WithInsecure(), WithTimeout(5), WithReflectionRetries(5, 10*time.Millisecond))
+	defer client.Close()
+	resp = client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+	require.NoError(t, resp.Err)
+	assert.Equal(t, int(codes.OK), resp.StatusCode)
+}
+
+func TestGrpc_ReflectionUnsupported(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	defer server.Stop()
+
+	client := NewClient(listener.Addr().String(), WithInsecure(), WithTimeout(5), WithReflectionRetries(5, time.Second))
+	defer client.Close()
+
+	start := time.Now()
+	resp := client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+	require.Error(t, resp.Err)
+	assert.Contains(t, resp.Err.Error(), "does not support reflection")
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestGrpc_ClientStreaming(t *testing.T) {
+	address, stop := startServer(t, nil)
+	defer stop()
+
+	client := NewClient(address, WithInsecure(), WithTimeout(5))
+	defer client.Close()
+
+	// "aGVsbG8=" is "hello" in base64
+	newlineDelimited := `{"payload": {"body": "aGVsbG8="}}
+{"payload": {"body": "aGVsbG8="}}
+{"payload": {"body": "aGVsbG8="}}`
+	resp := client.SendRequest(context.Background(), "grpc.testing.TestService/StreamingInputCall", newlineDelimited, nil)
+	require.NoError(t, resp.Err)
+	assert.Equal(t, int(codes.OK), resp.StatusCode)
+	assert.Equal(t, 3, resp.MessagesSent)
+
+	array := `[{"payload": {"body": "aGVsbG8="}}, {"payload": {"body": "aGVsbG8="}}]`
+	resp = client.SendRequest(context.Background(), "grpc.testing.TestService/StreamingInputCall", array, nil)
+	require.NoError(t, resp.Err)
+	assert.Equal(t, 2, resp.MessagesSent)
+
+	// "ZmFpbA==" is "fail" in base64
+	resp = client.SendRequest(context.Background(), "grpc.testing.TestService/StreamingInputCall", `[{}, {"payload": {"body": "ZmFpbA=="}}]`, nil)
+	require.Error(t, resp.Err)
+	assert.Equal(t, int(codes.InvalidArgument), resp.StatusCode)
+
+	resp = client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+	require.NoError(t, resp.Err)
+	assert.Equal(t, 1, resp.MessagesSent)
+}
+
+func TestGrpc_ClientStreamingMalformedMessage(t *testing.T) {
+	address, stop := startServer(t, nil)
+	defer stop()
+
+	client := NewClient(address, WithInsecure(), WithTimeout(5))
+	defer client.Close()
+
+	tests := map[string]string{
+		"newline delimited": "{}\n{}\n{\"payload\": }",
+		"array":             `[{}, {}, {"payload": }]`,
+		"unknown field":     `[{}, {}, {"missing": 1}]`,
+	}
+	for name, message := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp := client.SendRequest(context.Background(), "grpc.testing.TestService/StreamingInputCall", message, nil)
+			require.Error(t, resp.Err)
+			assert.Contains(t, resp.Err.Error(), "message 3")
+			assert.Equal(t, 2, resp.MessagesSent)
+		})
+	}
+
+	resp := client.SendRequest(context.Background(), "grpc.testing.TestService/StreamingInputCall", `[{}, {}`, nil)
+	require.Error(t, resp.Err)
+	assert.Regexp(t, "message 3|missing closing ]", resp.Err.Error())
+}
+
+func TestGrpc_ServerStreaming(t *testing.T) {
+	address, stop := startServer(t, nil)
+	defer stop()
+
+	client := NewClient(address, WithInsecure(), WithTimeout(5))
+	defer client.Close()
+
+	message := `{"responseParameters": [{"size": 1, "intervalUs": 50000}, {"size": 1}, {"size": 1, "intervalUs": 50000}]}`
+	resp := client.SendRequest(context.Background(), "grpc.testing.TestService/StreamingOutputCall", message, nil)
+	require.NoError(t, resp.Err)
+	assert.Equal(t, int(codes.OK), resp.StatusCode)
+	assert.Equal(t, 1, resp.MessagesSent)
+	assert.Equal(t, 3, resp.MessagesReceived)
+	assert.True(t, resp.TimeToFirstMessage >= 50*time.Millisecond)
+	assert.True(t, resp.Duration >= resp.TimeToFirstMessage+50*time.Millisecond)
+
+	resp = client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+	require.NoError(t, resp.Err)
+	assert.Equal(t, 1, resp.MessagesReceived)
+}
+
+func TestGrpc_MessagePlaceholders(t *testing.T) {
+	os.Setenv("MY_POD_NAME", `pod"1`)
+	defer os.Unsetenv("MY_POD_NAME")
+	services := make(chan string, 2)
+	requestIDs := make(chan string, 2)
+	address, stop := startServer(t, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		requestIDs <- md.Get("x-request-id")[0]
+		services <- req.(*healthpb.HealthCheckRequest).GetService()
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+	})
+	defer stop()
+
+	client := NewClient(address, WithInsecure(), WithTimeout(5))
+	defer client.Close()
+
+	// the placeholders are replaced every time the request is sent, escaping the quote in the value of the environment variable
+	for i := 0; i < 2; i++ {
+		resp := client.SendRequest(context.Background(), HealthCheckMethod, `{"service": "{$k8s|pod_name}-{$uuid}"}`, []string{"x-request-id: {$uuid}"})
+		require.NoError(t, resp.Err)
+	}
+	first, second := <-services, <-services
+	assert.True(t, strings.HasPrefix(first, `pod"1-`), first)
+	assert.NotEqual(t, first, second)
+	assert.NotEqual(t, <-requestIDs, <-requestIDs)
+}
+
+func TestGrpc_CaptureResponses(t *testing.T) {
+	address, stop := startServer(t, nil)
+	defer stop()
+
+	message := `{"responseParameters": [{"size": 1}, {"size": 2}]}`
+	client := NewClient(address, WithInsecure(), WithTimeout(5))
+	defer client.Close()
+	resp := client.SendRequest(context.Background(), "grpc.testing.TestService/StreamingOutputCall", message, nil)
+	require.NoError(t, resp.Err)
+	assert.Empty(t, resp.Body)
+
+	capturingClient := NewClient(address, WithInsecure(), WithTimeout(5), WithCaptureResponses())
+	defer capturingClient.Close()
+	resp = capturingClient.SendRequest(context.Background(), "grpc.testing.TestService/StreamingOutputCall", message, nil)
+	require.NoError(t, resp.Err)
+	assert.Contains(t, resp.Body, `"body": "AA=="`)
+	assert.Contains(t, resp.Body, `"body": "AAA="`)
+}
+
+func TestGrpc_MaxStreamMessages(t *testing.T) {
+	address, stop := startServer(t, nil)
+	defer stop()
+
+	client := NewClient(address, WithInsecure(), WithTimeout(5), WithMaxStreamMessages(100))
+	defer client.Close()
+
+	// without response parameters, the server streams until the call is cancelled
+	resp := client.SendRequest(context.Background(), "grpc.testing.TestService/StreamingOutputCall", "", nil)
+	require.NoError(t, resp.Err)
+	assert.Equal(t, int(codes.OK), resp.StatusCode)
+	assert.Equal(t, 100, resp.MessagesReceived)
+
+	message := `{"responseParameters": [{"size": 1}, {"size": 1}]}`
+	resp = client.SendRequest(context.Background(), "grpc.testing.TestService/StreamingOutputCall", message, nil)
+	require.NoError(t, resp.Err)
+	assert.Equal(t, 2, resp.MessagesReceived)
+}
+
+func TestGrpc_Reconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	address, stop := serve(listener, nil)
+
+	client := NewClient(address, WithInsecure(), WithTimeout(30))
+	defer client.Close()
+
+	resp := client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+	require.NoError(t, resp.Err)
+	lost := client.pool.conns[0]
+
+	// the server restarts, dropping the connection
+	stop()
+	resp = client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+	require.Error(t, resp.Err)
+
+	listener, err = net.Listen("tcp", address)
+	require.NoError(t, err)
+	_, stop = serve(listener, nil)
+	defer stop()
+
+	require.Eventually(t, func() bool {
+		return client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil).Err == nil
+	}, 5*time.Second, 100*time.Millisecond)
+	assert.Equal(t, 1, client.pool.reconnects)
+	assert.NotSame(t, lost, client.pool.conns[0])
+	assert.Equal(t, connectivity.Shutdown, lost.GetState())
+}
+
+func TestGrpc_ServiceConfig(t *testing.T) {
+	address, stop := startServer(t, nil)
+	defer stop()
+
+	// the timeout of the method is too short for any call to succeed
+	serviceConfig := `{"methodConfig": [{"name": [{"service": "grpc.health.v1.Health"}], "timeout": "0.000001s"}]}`
+	client := NewClient(address, WithInsecure(), WithTimeout(5), WithServiceConfig(serviceConfig))
+	defer client.Close()
+
+	resp := client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+	require.Error(t, resp.Err)
+	assert.Equal(t, int(codes.DeadlineExceeded), resp.StatusCode)
+
+	resp = client.SendRequest(context.Background(), "grpc.testing.TestService/StreamingOutputCall", `{"responseParameters": [{"size": 1}]}`, nil)
+	require.NoError(t, resp.Err)
+}
+
+func TestGrpc_DefaultServiceConfig(t *testing.T) {
+	client := NewClient("localhost:50051", WithServiceConfig(`{"waitForReady": true}`))
+	serviceConfig, err := client.defaultServiceConfig()
+	require.NoError(t, err)
+	assert.Equal(t, `{"waitForReady": true}`, serviceConfig)
+
+	client = NewClient("localhost:50051", WithLoadBalancing())
+	serviceConfig, err = client.defaultServiceConfig()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"loadBalancingPolicy": "round_robin"}`, serviceConfig)
+
+	client = NewClient("localhost:50051", WithLoadBalancing(), WithServiceConfig(`{"methodConfig": [{"name": [{"service": "my.Service"}], "waitForReady": true}]}`))
+	serviceConfig, err = client.defaultServiceConfig()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"loadBalancingPolicy": "round_robin", "methodConfig": [{"name": [{"service": "my.Service"}], "waitForReady": true}]}`, serviceConfig)
+
+	client = NewClient("localhost:50051", WithLoadBalancing(), WithServiceConfig(`{"loadBalancingPolicy": "pick_first"}`))
+	serviceConfig, err = client.defaultServiceConfig()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"loadBalancingPolicy": "pick_first"}`, serviceConfig)
+}
+
+func TestGrpc_RequestTimeout(t *testing.T) {
+	address, stop := startServer(t, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		// the handler hangs until the request is cancelled
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	defer stop()
+
+	client := NewClient(address, WithInsecure(), WithTimeout(5), WithRequestTimeout(100*time.Millisecond))
+	defer client.Close()
+
+	resp := client.SendRequest(context.Background(), "grpc.health.v1.Health/Check", "", nil)
+	require.Error(t, resp.Err)
+	assert.Equal(t, int(codes.DeadlineExceeded), resp.StatusCode)
+	assert.Equal(t, "timeout", resp.ErrCause)
+	assert.True(t, resp.Duration < time.Second)
+
+	// the request is also cancelled once its context is done, before the timeout
+	client = NewClient(address, WithInsecure(), WithTimeout(5), WithRequestTimeout(time.Minute))
+	defer client.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	resp = client.SendRequest(ctx, "grpc.health.v1.Health/Check", "", nil)
+	require.Error(t, resp.Err)
+	assert.Equal(t, int(codes.Canceled), resp.StatusCode)
+	assert.Equal(t, "cancelled", resp.ErrCause)
+}
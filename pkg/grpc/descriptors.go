@@ -0,0 +1,76 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package grpc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fullstorydev/grpcurl"
+	"github.com/jhump/protoreflect/desc"
+)
+
+// Descriptors resolve the services and methods of the requests from local files instead of server reflection,
+// e.g. for servers that have reflection disabled.
+type Descriptors struct {
+	source grpcurl.DescriptorSource
+	// origin describes the files the descriptors were loaded from, so that errors can point at them
+	origin string
+}
+
+// LoadProtosets loads the descriptors from compiled FileDescriptorSet files e.g. the output of protoc --descriptor_set_out.
+func LoadProtosets(files ...string) (*Descriptors, error) {
+	source, err := grpcurl.DescriptorSourceFromProtoSets(files...)
+	if err != nil {
+		return nil, fmt.Errorf("protoset: %v", err)
+	}
+	return &Descriptors{source: source, origin: "protoset " + strings.Join(files, ", ")}, nil
+}
+
+// LoadProtoFiles parses the descriptors from .proto source files. Imported files are looked up in the import paths,
+// or relative to the files if none is set. Parse errors include the file and line e.g. api.proto:12:3: syntax error.
+func LoadProtoFiles(importPaths []string, files ...string) (*Descriptors, error) {
+	source, err := grpcurl.DescriptorSourceFromProtoFiles(importPaths, files...)
+	if err != nil {
+		return nil, fmt.Errorf("proto files: %v", err)
+	}
+	return &Descriptors{source: source, origin: "proto files " + strings.Join(files, ", ")}, nil
+}
+
+// checkMethod returns an error naming the method if it is not defined in the descriptors.
+// The method is in service/method or service.method format, like in grpcurl.
+func (d *Descriptors) checkMethod(serviceMethod string) error {
+	pos := strings.LastIndex(serviceMethod, "/")
+	if pos < 0 {
+		pos = strings.LastIndex(serviceMethod, ".")
+	}
+	if pos <= 0 || pos == len(serviceMethod)-1 {
+		return fmt.Errorf("invalid method %s, expected '<service>/<method>'", serviceMethod)
+	}
+	service, method := serviceMethod[:pos], serviceMethod[pos+1:]
+
+	symbol, err := d.source.FindSymbol(service)
+	if err != nil {
+		return fmt.Errorf("method %s not found in %s: service %s is not defined", serviceMethod, d.origin, service)
+	}
+	serviceDescriptor, ok := symbol.(*desc.ServiceDescriptor)
+	if !ok {
+		return fmt.Errorf("method %s not found in %s: %s is not a service", serviceMethod, d.origin, service)
+	}
+	if serviceDescriptor.FindMethodByName(method) == nil {
+		return fmt.Errorf("method %s not found in %s: service %s has no method %s", serviceMethod, d.origin, service, method)
+	}
+	return nil
+}
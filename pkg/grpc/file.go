@@ -0,0 +1,141 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package grpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// requestDefinition represents a gRPC request as defined in a requests file.
+// The message can either be a string or a structured object in which case it is sent as JSON.
+// The weight defaults to 1 if not set.
+type requestDefinition struct {
+	Name    string            `yaml:"name"`
+	Method  string            `yaml:"method"`
+	Message interface{}       `yaml:"message"`
+	Headers map[string]string `yaml:"headers"`
+	Weight  *int              `yaml:"weight"`
+}
+
+// ToGrpcRequestsFromFile parses a JSON or YAML file containing a list of gRPC requests.
+// An empty file or list is not an error and returns no requests.
+func ToGrpcRequestsFromFile(file string) ([]Request, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("requests file %s: %v", file, err)
+	}
+	defer f.Close()
+
+	var definitions []requestDefinition
+	if err := yaml.NewDecoder(f).Decode(&definitions); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("requests file %s: %v", file, err)
+	}
+
+	var requests []Request
+	for i, definition := range definitions {
+		request, err := definition.toRequest()
+		if err != nil {
+			return nil, fmt.Errorf("requests file %s: request %d: %v", file, i, err)
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+// ToGrpcRequestsFromLines parses a file with one gRPC request per line in the same format as the grpc-requests flag.
+// Empty lines and lines starting with # are ignored.
+func ToGrpcRequestsFromLines(file string) ([]Request, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("request file %s: %v", file, err)
+	}
+	defer f.Close()
+
+	var requests []Request
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		request, err := ToGrpcRequest(line)
+		if err != nil {
+			return nil, fmt.Errorf("request file %s: line %d: %v", file, number, err)
+		}
+		requests = append(requests, request)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("request file %s: %v", file, err)
+	}
+	return requests, nil
+}
+
+// toRequest validates the definition and converts it to a Request.
+func (d requestDefinition) toRequest() (Request, error) {
+	if d.Weight != nil && *d.Weight <= 0 {
+		return Request{}, fmt.Errorf("invalid weight %d, expected it to be greater than zero", *d.Weight)
+	}
+
+	var message string
+	switch m := d.Message.(type) {
+	case nil:
+	case string:
+		message = m
+	default:
+		encoded, err := json.Marshal(m)
+		if err != nil {
+			return Request{}, fmt.Errorf("invalid message: %v", err)
+		}
+		message = string(encoded)
+	}
+
+	request, err := newRequest(d.Method, message)
+	if err != nil {
+		return Request{}, err
+	}
+
+	// sort the headers so that they are always sent in the same order
+	for k, v := range d.Headers {
+		request.Headers = append(request.Headers, fmt.Sprintf("%s: %s", k, v))
+	}
+	sort.Strings(request.Headers)
+	// placeholders are replaced when the request is sent, so only check that they are valid
+	if _, err := InterpolateHeaders(request.Headers); err != nil {
+		return Request{}, err
+	}
+	request.Name = d.Name
+	if d.Weight != nil {
+		request.Weight = *d.Weight
+	}
+	return request, nil
+}
+
+// toGrpcRequestFromObject parses a single request defined as a JSON or YAML object.
+func toGrpcRequestFromObject(object string) (Request, error) {
+	var definition requestDefinition
+	if err := yaml.Unmarshal([]byte(object), &definition); err != nil {
+		return Request{}, err
+	}
+	return definition.toRequest()
+}
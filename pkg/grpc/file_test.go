@@ -0,0 +1,131 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package grpc
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+)
+
+func TestGrpc_FileToGrpcRequests(t *testing.T) {
+	file := writeTempFile(t, `
+- method: health/ping
+- method: svc/method
+  headers:
+    tenant: foo
+  message:
+    key: value
+`)
+	defer os.Remove(file)
+
+	requests, err := ToGrpcRequestsFromFile(file)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(requests))
+
+	assert.Equal(t, "health/ping", requests[0].ServiceMethod)
+	assert.Equal(t, "", requests[0].Message)
+
+	assert.Equal(t, "svc/method", requests[1].ServiceMethod)
+	assert.Equal(t, `{"key":"value"}`, requests[1].Message)
+	assert.Equal(t, []string{"tenant: foo"}, requests[1].Headers)
+}
+
+func TestGrpc_LinesFileToGrpcRequests(t *testing.T) {
+	file := writeTempFile(t, `# health checks
+health
+
+my.Service/Search:{"query": "{$random|foo}"}
+`)
+	defer os.Remove(file)
+
+	requests, err := ToGrpcRequestsFromLines(file)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(requests))
+	assert.Equal(t, HealthCheckMethod, requests[0].ServiceMethod)
+	assert.Equal(t, "my.Service/Search", requests[1].ServiceMethod)
+	assert.Equal(t, `{"query": "{$random|foo}"}`, requests[1].Message)
+}
+
+func TestGrpc_InvalidLinesFileToGrpcRequests(t *testing.T) {
+	file := writeTempFile(t, "health\nmy.Service\n")
+	defer os.Remove(file)
+
+	_, err := ToGrpcRequestsFromLines(file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), file)
+	assert.Contains(t, err.Error(), "line 2")
+}
+
+func TestGrpc_InvalidFileToGrpcRequests(t *testing.T) {
+	file := writeTempFile(t, `[{"method": "health/ping"}, {"method": "ping"}]`)
+	defer os.Remove(file)
+
+	_, err := ToGrpcRequestsFromFile(file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), file)
+	assert.Contains(t, err.Error(), "request 1")
+}
+
+func TestGrpc_ObjectFlagWithWeight(t *testing.T) {
+	request, err := ToGrpcRequest(`{"method": "svc/method", "message": {"key": "value"}, "weight": 5}`)
+	require.NoError(t, err)
+	assert.Equal(t, "svc/method", request.ServiceMethod)
+	assert.Equal(t, `{"key":"value"}`, request.Message)
+	assert.Equal(t, 5, request.Weight)
+
+	_, err = ToGrpcRequest(`{"method": "svc/method", "weight": 0}`)
+	assert.Error(t, err)
+}
+
+func writeTempFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "mittens")
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	return f.Name()
+}
+
+func TestGrpc_FileMultiLineMessage(t *testing.T) {
+	file := writeTempFile(t, `
+- method: grpc.health.v1.Health/Check
+  message: |
+    {
+      "service": ""
+    }
+`)
+	defer os.Remove(file)
+
+	requests, err := ToGrpcRequestsFromFile(file)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(requests))
+	assert.Equal(t, "{\n  \"service\": \"\"\n}\n", requests[0].Message)
+
+	address, stop := startServer(t, nil)
+	defer stop()
+	client := NewClient(address, WithInsecure(), WithTimeout(5))
+	defer client.Close()
+
+	resp := client.SendRequest(context.Background(), requests[0].ServiceMethod, requests[0].Message, nil)
+	require.NoError(t, resp.Err)
+	assert.Equal(t, int(codes.OK), resp.StatusCode)
+}
@@ -0,0 +1,112 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package grpc
+
+import (
+	"strings"
+	"time"
+
+	"github.com/fullstorydev/grpcurl"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// eventHandler records the outcome of a gRPC invocation. Unlike the default grpcurl handler, it does not print the responses to stdout,
+// which would mix them with the output of mittens.
+// The status is nil if the call was OK.
+// If maxResponses is set, server-streaming calls are cancelled once that many responses have been received, so that infinite streams terminate.
+// If formatter is set, the responses are formatted and recorded in body, separated by newlines.
+type eventHandler struct {
+	method    *desc.MethodDescriptor
+	status    *status.Status
+	responses int
+
+	start               time.Time
+	timeToFirstResponse time.Duration
+
+	maxResponses int
+	cancel       context.CancelFunc
+	capped       bool
+
+	formatter grpcurl.Formatter
+	body      strings.Builder
+}
+
+func (h *eventHandler) OnResolveMethod(method *desc.MethodDescriptor) {
+	h.method = method
+}
+
+func (h *eventHandler) OnSendHeaders(metadata.MD) {}
+
+func (h *eventHandler) OnReceiveHeaders(metadata.MD) {}
+
+func (h *eventHandler) OnReceiveResponse(message proto.Message) {
+	// responses already in flight when the call was cancelled are not counted
+	if h.capped {
+		return
+	}
+	h.responses++
+	h.record(message)
+	if h.responses == 1 {
+		h.timeToFirstResponse = time.Since(h.start)
+	}
+	if h.maxResponses > 0 && h.responses >= h.maxResponses && h.method.IsServerStreaming() {
+		h.capped = true
+		h.cancel()
+	}
+}
+
+func (h *eventHandler) OnReceiveTrailers(stat *status.Status, _ metadata.MD) {
+	h.status = stat
+}
+
+// record formats the response and appends it to the body, if a formatter is set. Responses that cannot be formatted are recorded as the error.
+func (h *eventHandler) record(message proto.Message) {
+	if h.formatter == nil {
+		return
+	}
+	formatted, err := h.formatter(message)
+	if err != nil {
+		formatted = err.Error()
+	}
+	if h.body.Len() > 0 {
+		h.body.WriteString("\n")
+	}
+	h.body.WriteString(formatted)
+}
+
+// statusCode returns the status code of the call. Calls cancelled because they received maxResponses are OK.
+func (h *eventHandler) statusCode() codes.Code {
+	if h.capped && h.status.Code() == codes.Canceled {
+		return codes.OK
+	}
+	return h.status.Code()
+}
+
+// messagesSent returns the number of request messages sent given the number parsed. Only client-streaming methods are sent
+// all the messages parsed, the other ones are sent exactly one, which may be empty. No messages are sent if the method was not resolved.
+func (h *eventHandler) messagesSent(parsed int) int {
+	if h.method == nil {
+		return 0
+	}
+	if h.method.IsClientStreaming() {
+		return parsed
+	}
+	return 1
+}
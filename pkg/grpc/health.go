@@ -0,0 +1,72 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package grpc
+
+import (
+	"fmt"
+
+	"github.com/fullstorydev/grpcurl"
+	"github.com/jhump/protoreflect/desc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// HealthCheckMethod is the method of the gRPC health checking protocol that checks whether the server is serving.
+const HealthCheckMethod = "grpc.health.v1.Health/Check"
+
+// healthShorthand can be set as the method of requests instead of HealthCheckMethod.
+const healthShorthand = "health"
+
+const healthProtoFile = "grpc/health/v1/health.proto"
+
+// healthDescriptorSource returns the descriptors of the health service compiled into mittens,
+// so that health checks do not depend on server reflection.
+func healthDescriptorSource() (grpcurl.DescriptorSource, error) {
+	file, err := desc.LoadFileDescriptor(healthProtoFile)
+	if err != nil {
+		return nil, fmt.Errorf("gRPC health service descriptors: %v", err)
+	}
+	return grpcurl.DescriptorSourceFromFileDescriptors(file)
+}
+
+// CheckHealth checks whether the server is serving using the gRPC health checking protocol.
+// It returns an error if the server is not SERVING, or if it does not implement the health service.
+// Placeholders in the header values are replaced as in SendRequest.
+func (c *Client) CheckHealth(ctx context.Context, headers []string) error {
+	headers, err := InterpolateHeaders(headers)
+	if err != nil {
+		return err
+	}
+	conn, _, err := c.conn(headers)
+	if err != nil {
+		return err
+	}
+
+	ctx = metadata.NewOutgoingContext(ctx, grpcurl.MetadataFromHeaders(headers))
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		return fmt.Errorf("gRPC health check: the server does not implement the grpc.health.v1.Health service (UNIMPLEMENTED)")
+	}
+	if err != nil {
+		return fmt.Errorf("gRPC health check: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("gRPC health check: status %s", resp.GetStatus())
+	}
+	return nil
+}
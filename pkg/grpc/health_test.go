@@ -0,0 +1,69 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package grpc
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestGrpc_CheckHealth(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	client := NewClient(listener.Addr().String(), WithInsecure(), WithTimeout(5))
+	defer client.Close()
+
+	err = client.CheckHealth(context.Background(), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "NOT_SERVING")
+
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	assert.NoError(t, client.CheckHealth(context.Background(), nil))
+}
+
+func TestGrpc_HealthNotImplemented(t *testing.T) {
+	// the server implements neither the health service nor reflection
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	go server.Serve(listener)
+	defer server.Stop()
+
+	client := NewClient(listener.Addr().String(), WithInsecure(), WithTimeout(5))
+	defer client.Close()
+
+	err = client.CheckHealth(context.Background(), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not implement the grpc.health.v1.Health service")
+
+	resp := client.SendRequest(context.Background(), HealthCheckMethod, "", nil)
+	require.Error(t, resp.Err)
+	assert.Equal(t, int(codes.Unimplemented), resp.StatusCode)
+}
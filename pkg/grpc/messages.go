@@ -0,0 +1,94 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package grpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// messageParser parses the JSON messages of a request one by one, so that client-streaming methods can be sent several messages in a single call.
+// The messages are either concatenated e.g. one per line, or the elements of a JSON array. A single message is parsed in the same way.
+type messageParser struct {
+	dec         *json.Decoder
+	unmarshaler jsonpb.Unmarshaler
+	array       bool
+	// started is set once the opening [ of an array has been read
+	started bool
+	// count is the number of messages parsed so far
+	count int
+}
+
+func newMessageParser(message string, resolver jsonpb.AnyResolver) *messageParser {
+	return &messageParser{
+		dec:         json.NewDecoder(strings.NewReader(message)),
+		unmarshaler: jsonpb.Unmarshaler{AnyResolver: resolver},
+		array:       strings.HasPrefix(strings.TrimSpace(message), "["),
+	}
+}
+
+// next parses the next message into m. It returns io.EOF once there are no more messages,
+// and an error with the position of the message if a message is malformed.
+func (p *messageParser) next(m proto.Message) error {
+	if p.array {
+		if !p.started {
+			p.started = true
+			if _, err := p.dec.Token(); err != nil {
+				return fmt.Errorf("message array: %v", err)
+			}
+		}
+		if !p.dec.More() {
+			if _, err := p.dec.Token(); err != nil {
+				if err == io.EOF {
+					return fmt.Errorf("message array: missing closing ]")
+				}
+				return fmt.Errorf("message array: %v", err)
+			}
+			return io.EOF
+		}
+	}
+
+	var msg json.RawMessage
+	if err := p.dec.Decode(&msg); err != nil {
+		if err == io.EOF && !p.array {
+			return io.EOF
+		}
+		return fmt.Errorf("message %d: %v", p.count+1, err)
+	}
+	if err := p.unmarshaler.Unmarshal(bytes.NewReader(msg), m); err != nil {
+		return fmt.Errorf("message %d: %v", p.count+1, err)
+	}
+	p.count++
+	return nil
+}
+
+// validateJSON returns an error if the messages, either concatenated e.g. one per line or a JSON array, are not valid JSON.
+func validateJSON(messages string) error {
+	dec := json.NewDecoder(strings.NewReader(messages))
+	for i := 1; ; i++ {
+		var message json.RawMessage
+		if err := dec.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("message %d: invalid JSON: %v", i, err)
+		}
+	}
+}
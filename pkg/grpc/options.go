@@ -0,0 +1,153 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package grpc
+
+import (
+	"crypto/tls"
+	"time"
+
+	"google.golang.org/grpc/keepalive"
+)
+
+const defaultTimeoutSeconds = 10
+
+// ClientOption sets an optional setting of a gRPC client.
+type ClientOption func(*Client)
+
+// WithInsecure disables transport security, so requests are sent in plaintext.
+func WithInsecure() ClientOption {
+	return func(c *Client) {
+		c.insecure = true
+	}
+}
+
+// WithTimeout sets the time in seconds after which the reflection requests used to resolve the services time out.
+func WithTimeout(timeoutSeconds int) ClientOption {
+	return func(c *Client) {
+		c.timeoutSeconds = timeoutSeconds
+	}
+}
+
+// WithTLS sets the TLS config used to connect to the server e.g. to trust a custom CA.
+// It has no effect if WithInsecure is set.
+func WithTLS(config *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.tlsConfig = config
+	}
+}
+
+// WithLoadBalancing spreads the requests across all the addresses the host resolves to using round robin.
+// The host is resolved using DNS unless it already includes a resolver scheme e.g. dns:///my-service:50051.
+func WithLoadBalancing() ClientOption {
+	return func(c *Client) {
+		c.loadBalancing = true
+	}
+}
+
+// WithAuthority sets the :authority pseudo-header sent with every request instead of the dial target.
+// This is needed when the server is behind a proxy, such as Envoy, that routes requests based on the virtual host name.
+func WithAuthority(authority string) ClientOption {
+	return func(c *Client) {
+		c.authority = authority
+	}
+}
+
+// WithUnixSocket dials the Unix domain socket at the given path instead of the host, which is still used as the authority.
+func WithUnixSocket(path string) ClientOption {
+	return func(c *Client) {
+		c.unixSocket = path
+	}
+}
+
+// WithMaxRecvMsgSize sets the max size in bytes of the messages the client can receive, instead of the gRPC default of 4 MB.
+func WithMaxRecvMsgSize(bytes int) ClientOption {
+	return func(c *Client) {
+		c.maxRecvMsgSize = bytes
+	}
+}
+
+// WithMaxSendMsgSize sets the max size in bytes of the messages the client can send. gRPC does not limit it by default.
+func WithMaxSendMsgSize(bytes int) ClientOption {
+	return func(c *Client) {
+		c.maxSendMsgSize = bytes
+	}
+}
+
+// WithKeepalive sends keepalive pings to the server so that idle connections are not dropped by firewalls or load balancers.
+// Note that gRPC does not send pings more often than every 10 seconds.
+func WithKeepalive(params keepalive.ClientParameters) ClientOption {
+	return func(c *Client) {
+		c.keepalive = &params
+	}
+}
+
+// WithDescriptors resolves the services and methods of the requests from the given descriptors instead of server reflection.
+// If the server also supports reflection, it is not used.
+func WithDescriptors(descriptors *Descriptors) ClientOption {
+	return func(c *Client) {
+		c.descriptors = descriptors
+	}
+}
+
+// WithReflectionRetries checks that the reflection service of the server is ready once connected, retrying up to the given times
+// and waiting backoff before the first retry, doubling it before each of the next ones. If it is not ready by then, requests fail.
+// It has no effect if WithDescriptors is set, as reflection is not used.
+func WithReflectionRetries(retries int, backoff time.Duration) ClientOption {
+	return func(c *Client) {
+		c.reflection = reflectionRetry{retries: retries, backoff: backoff}
+	}
+}
+
+// WithPoolSize sets the number of connections opened to the server. Requests are sent over them in round robin,
+// so that a single connection does not become a bottleneck under high concurrency. Sizes below 1 are ignored.
+func WithPoolSize(size int) ClientOption {
+	return func(c *Client) {
+		if size > 0 {
+			c.poolSize = size
+		}
+	}
+}
+
+// WithMaxStreamMessages cancels server-streaming calls once the given number of responses have been received,
+// so that warming up infinite streams terminates. Such calls are OK. Zero means that streams are consumed until they complete.
+func WithMaxStreamMessages(messages int) ClientOption {
+	return func(c *Client) {
+		c.maxStreamMessages = messages
+	}
+}
+
+// WithCaptureResponses records the responses of every request, formatted as JSON one after the other, in the body of its result,
+// e.g. to log them. By default only their number is recorded.
+func WithCaptureResponses() ClientOption {
+	return func(c *Client) {
+		c.captureResponses = true
+	}
+}
+
+// WithServiceConfig sets the JSON service config used unless the name resolver provides one, e.g. to configure retries or wait-for-ready
+// per method. If WithLoadBalancing is set too, round robin is added to it unless it sets a load balancing policy.
+func WithServiceConfig(serviceConfig string) ClientOption {
+	return func(c *Client) {
+		c.serviceConfig = serviceConfig
+	}
+}
+
+// WithRequestTimeout sets the time after which requests time out, including receiving the whole response stream.
+// Zero means no limit other than that of the context of the request.
+func WithRequestTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.requestTimeout = timeout
+	}
+}
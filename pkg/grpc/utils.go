@@ -16,29 +16,163 @@ package grpc
 
 import (
 	"fmt"
+	"io/ioutil"
+	"mittens/pkg/placeholders"
+	"net"
+	"strconv"
 	"strings"
 )
 
 // Request represents a gRPC request.
+// Headers are sent in addition to the global ones.
+// Name identifies the request in the stats and defaults to the service method.
 type Request struct {
+	Name          string
 	ServiceMethod string
 	Message       string
+	Headers       []string
+	Weight        int
+}
+
+// GetName returns the name of the request, or its service method if it does not have one.
+func (r Request) GetName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.ServiceMethod
 }
 
 // ToGrpcRequest parses a gRPC request which is in a string format and stores it in a struct.
+// Requests can also be defined as a JSON or YAML object with the same fields as in a requests file.
 func ToGrpcRequest(requestFlag string) (Request, error) {
+	if strings.HasPrefix(strings.TrimSpace(requestFlag), "{") {
+		request, err := toGrpcRequestFromObject(requestFlag)
+		if err != nil {
+			return Request{}, fmt.Errorf("invalid request flag: %s, %v", requestFlag, err)
+		}
+		return request, nil
+	}
 
-	// service/method[:message]
+	// service/method[:message], where the method can be health
 	parts := strings.SplitN(requestFlag, ":", 2)
+	if parts[0] == healthShorthand {
+		parts[0] = HealthCheckMethod
+	}
 	if len(strings.Split(parts[0], "/")) != 2 {
 		return Request{}, fmt.Errorf("invalid request flag: %s, expected format <service>/<method>[:body]", requestFlag)
 	}
 
-	request := Request{ServiceMethod: parts[0]}
+	message := ""
 	if len(parts) == 2 {
-		request.Message = parts[1]
-	} else {
-		request.Message = ""
+		message = parts[1]
+	}
+
+	request, err := newRequest(parts[0], message)
+	if err != nil {
+		return Request{}, fmt.Errorf("invalid request flag: %s, %v", requestFlag, err)
 	}
 	return request, nil
 }
+
+// newRequest validates the service method and creates a Request.
+// Messages starting with @ are read from the file that follows. Messages must be valid JSON once their placeholders are replaced,
+// so that invalid messages are reported at startup rather than when the requests are sent.
+func newRequest(serviceMethod, message string) (Request, error) {
+	if serviceMethod == healthShorthand {
+		serviceMethod = HealthCheckMethod
+	}
+	if len(strings.Split(serviceMethod, "/")) != 2 {
+		return Request{}, fmt.Errorf("invalid method %s, expected format <service>/<method>", serviceMethod)
+	}
+
+	content, err := loadMessageBody(message)
+	if err != nil {
+		return Request{}, err
+	}
+	// placeholders are replaced when the request is sent, so only check that they are valid
+	interpolated, err := placeholders.InterpolateJSON(content)
+	if err == nil {
+		err = validateJSON(interpolated)
+	}
+	if err != nil && isMessageFile(message) {
+		return Request{}, fmt.Errorf("message file %s: %v", strings.TrimPrefix(message, "@"), err)
+	}
+	if err != nil {
+		return Request{}, err
+	}
+	return Request{ServiceMethod: serviceMethod, Message: content, Weight: 1}, nil
+}
+
+// isMessageFile returns true if the message is read from a file i.e. it starts with a single @.
+func isMessageFile(msg string) bool {
+	return strings.HasPrefix(msg, "@") && !strings.HasPrefix(msg, "@@")
+}
+
+// InterpolateHeaders returns the headers, in 'name: value' format, replacing any placeholders in their values e.g. x-request-id: {$uuid}.
+// It returns an error if any placeholder has invalid modifiers.
+func InterpolateHeaders(headers []string) ([]string, error) {
+	if len(headers) == 0 {
+		return headers, nil
+	}
+
+	interpolated := make([]string, len(headers))
+	for i, header := range headers {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 {
+			interpolated[i] = header
+			continue
+		}
+		value, err := placeholders.Interpolate(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %v", parts[0], err)
+		}
+		interpolated[i] = parts[0] + ":" + value
+	}
+	return interpolated, nil
+}
+
+// normalizeHost wraps bare IPv6 addresses without a port in brackets so that they can be dialled by gRPC.
+// Hostnames, IPv4 addresses and bracketed IPv6 addresses, with or without a port, are returned unchanged.
+// IPv6 addresses with a port must be in brackets e.g. [::1]:50051. Bare IPv6 addresses whose last segment could be a port
+// e.g. ::1:50051 or fe80::1:50051 are ambiguous and return an error.
+// A resolver scheme such as dns:/// is kept and only the address that follows is normalised.
+func normalizeHost(host string) (string, error) {
+	if i := strings.Index(host, ":///"); i != -1 {
+		address, err := normalizeHost(host[i+4:])
+		return host[:i+4] + address, err
+	}
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host, nil
+	}
+
+	if i := strings.LastIndex(host, ":"); i != -1 {
+		if _, err := strconv.ParseUint(host[i+1:], 10, 16); err == nil && isIPv6(host[:i]) {
+			return "", fmt.Errorf("ambiguous host %s, IPv6 addresses must be in brackets e.g. [::1] or [::1]:50051", host)
+		}
+	}
+
+	if isIPv6(host) {
+		return fmt.Sprintf("[%s]", host), nil
+	}
+	return host, nil
+}
+
+// isIPv6 returns true if the address is a valid IPv6 address.
+func isIPv6(address string) bool {
+	ip := net.ParseIP(address)
+	return ip != nil && ip.To4() == nil
+}
+
+// loadMessageBody returns the message as is unless it starts with @, in which case the message is read from the file that follows e.g. @request.json.
+// A message that really starts with @ can be escaped as @@ e.g. @@handle is sent as @handle.
+func loadMessageBody(msg string) (string, error) {
+	if !isMessageFile(msg) {
+		return strings.TrimPrefix(msg, "@"), nil
+	}
+
+	content, err := ioutil.ReadFile(strings.TrimPrefix(msg, "@"))
+	if err != nil {
+		return "", fmt.Errorf("cannot read message body: %v", err)
+	}
+	return string(content), nil
+}
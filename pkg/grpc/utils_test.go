@@ -15,8 +15,11 @@
 package grpc
 
 import (
+	"context"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"os"
 	"testing"
 )
 
@@ -40,9 +43,148 @@ func TestGrpc_FlagWithoutBodyToGrpcRequest(t *testing.T) {
 	assert.Equal(t, "", string(request.Message))
 }
 
+func TestGrpc_HealthShorthandToGrpcRequest(t *testing.T) {
+
+	request, err := ToGrpcRequest("health")
+	require.NoError(t, err)
+	assert.Equal(t, HealthCheckMethod, request.ServiceMethod)
+
+	request, err = ToGrpcRequest(`health:{"service": "my.Service"}`)
+	require.NoError(t, err)
+	assert.Equal(t, HealthCheckMethod, request.ServiceMethod)
+	assert.Equal(t, `{"service": "my.Service"}`, string(request.Message))
+}
+
+func TestGrpc_FlagWithMessageFromFileToGrpcRequest(t *testing.T) {
+	file := writeTempFile(t, `{"from": "file"}`)
+	defer os.Remove(file)
+
+	request, err := ToGrpcRequest("health/ping:@" + file)
+	require.NoError(t, err)
+	assert.Equal(t, `{"from": "file"}`, request.Message)
+
+	_, err = ToGrpcRequest("health/ping:@/non/existent.json")
+	require.Error(t, err)
+}
+
+func TestGrpc_FlagWithInvalidPlaceholderToGrpcRequest(t *testing.T) {
+	request, err := ToGrpcRequest(`health/ping:{"id": "{$uuid}"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id": "{$uuid}"}`, request.Message)
+
+	_, err = ToGrpcRequest(`health/ping:{"at": {$currentTimestamp|weeks+1}}`)
+	assert.Error(t, err)
+}
+
 func TestGrpc_InvalidFlagToGrpcRequest(t *testing.T) {
 
 	requestFlag := `health:ping`
 	_, err := ToGrpcRequest(requestFlag)
 	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid JSON")
+
+	requestFlag = `grpc.health.v1.Health:ping`
+	_, err = ToGrpcRequest(requestFlag)
+	require.Error(t, err)
+}
+
+func TestGrpc_NormalizeHost(t *testing.T) {
+	tests := []struct {
+		name string
+		host string
+		want string
+	}{
+		{"ipv4 with port", "127.0.0.1:50051", "127.0.0.1:50051"},
+		{"ipv4 without port", "127.0.0.1", "127.0.0.1"},
+		{"hostname with port", "localhost:50051", "localhost:50051"},
+		{"hostname without port", "localhost", "localhost"},
+		{"ipv6 loopback without port", "::1", "[::1]"},
+		{"ipv6 without port", "fe80::1ff:fe23:4567:890a:abcd", "[fe80::1ff:fe23:4567:890a:abcd]"},
+		{"full ipv6 without port", "2001:db8:0:0:0:ff00:42:8329", "[2001:db8:0:0:0:ff00:42:8329]"},
+		{"bracketed ipv6 with port", "[::1]:50051", "[::1]:50051"},
+		{"bracketed ipv6 without port", "[::1]", "[::1]"},
+		{"dns scheme with hostname", "dns:///my-service:50051", "dns:///my-service:50051"},
+		{"dns scheme with bracketed ipv6", "dns:///[::1]:50051", "dns:///[::1]:50051"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, err := normalizeHost(tt.host)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, host)
+		})
+	}
+}
+
+func TestGrpc_NormalizeAmbiguousHost(t *testing.T) {
+	// the last segment of these could be either part of the address or the port
+	for _, host := range []string{"::1:50051", "fe80::1:50051", "fe80::1ff:fe23:4567:890a:50051", "dns:///::1:50051"} {
+		_, err := normalizeHost(host)
+		assert.Error(t, err, host)
+	}
+
+	client := NewClient("fe80::1:50051", WithInsecure())
+	resp := client.SendRequest(context.Background(), HealthCheckMethod, "", nil)
+	require.Error(t, resp.Err)
+	assert.Contains(t, resp.Err.Error(), "ambiguous host fe80::1:50051")
+}
+
+func TestGrpc_LoadMessageBody(t *testing.T) {
+	message, err := loadMessageBody(`{"db": "true"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"db": "true"}`, message)
+
+	file := writeTempFile(t, `{"from": "file"}`)
+	defer os.Remove(file)
+
+	message, err = loadMessageBody("@" + file)
+	require.NoError(t, err)
+	assert.Equal(t, `{"from": "file"}`, message)
+
+	_, err = loadMessageBody("@/non/existent.json")
+	require.Error(t, err)
+
+	// a message that really starts with @ is escaped as @@
+	message, err = loadMessageBody(`@@"handle"`)
+	require.NoError(t, err)
+	assert.Equal(t, `@"handle"`, message)
+}
+
+func TestGrpc_MessageFileValidation(t *testing.T) {
+	file := writeTempFile(t, `{"id": "{$random|foo}", "nested": {"values": [1, 2]}}`)
+	defer os.Remove(file)
+
+	// the same file can be used by several requests, and its placeholders are replaced when the requests are sent
+	for _, requestFlag := range []string{"health/ping:@" + file, "health/pong:@" + file} {
+		request, err := ToGrpcRequest(requestFlag)
+		require.NoError(t, err)
+		assert.Equal(t, `{"id": "{$random|foo}", "nested": {"values": [1, 2]}}`, request.Message)
+	}
+
+	invalidFile := writeTempFile(t, `{"id": 1}
+{"id": 2`)
+	defer os.Remove(invalidFile)
+	_, err := ToGrpcRequest("health/ping:@" + invalidFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "message 2: invalid JSON")
+
+	invalidPlaceholderFile := writeTempFile(t, `{"at": "{$currentTimestamp|weeks+1}"}`)
+	defer os.Remove(invalidPlaceholderFile)
+	_, err = ToGrpcRequest("health/ping:@" + invalidPlaceholderFile)
+	assert.Error(t, err)
+}
+
+func TestGrpc_InterpolateHeaders(t *testing.T) {
+	headers := []string{"x-request-id: {$uuid}", "x-tenant: {$random|foo}", "x-static: value"}
+	first, err := InterpolateHeaders(headers)
+	require.NoError(t, err)
+	second, err := InterpolateHeaders(headers)
+	require.NoError(t, err)
+
+	assert.Regexp(t, "^x-request-id: [0-9a-f-]{36}$", first[0])
+	assert.NotEqual(t, first[0], second[0])
+	assert.Equal(t, []string{"x-tenant: foo", "x-static: value"}, first[1:])
+
+	_, err = InterpolateHeaders([]string{"x-expires-at: {$currentTimestamp|weeks+1}"})
+	assert.Error(t, err)
 }
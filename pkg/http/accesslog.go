@@ -0,0 +1,111 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// host ident user [time] "method path protocol" status size, optionally followed by "referer" "user-agent" in the combined format
+var accessLogRegex = regexp.MustCompile(`^\S+ \S+ \S+ \[[^\]]+\] "(\S+) (/\S*)(?: [^"]*)?" \d{3} \S+`)
+
+// AccessLogOptions controls which lines of an access log are converted to requests.
+// Methods defaults to GET. Only every SampleEvery line is read, if greater than 1.
+// Once MaxRequests distinct requests are loaded, lines for new requests are skipped so that large logs do not exhaust memory.
+type AccessLogOptions struct {
+	Methods     []string
+	SampleEvery int
+	MaxRequests int
+}
+
+// ToHTTPRequestsFromAccessLog reads an access log and converts its lines to requests using the zero Parser.
+func ToHTTPRequestsFromAccessLog(file string, options AccessLogOptions) ([]Request, error) {
+	return Parser{}.ToHTTPRequestsFromAccessLog(file, options)
+}
+
+// ToHTTPRequestsFromAccessLog reads an access log in common or combined log format and converts its lines to requests.
+// Lines for the same method and path are de-duplicated into a single request whose weight is the number of lines, so that the distribution of the log is kept.
+// Lines that cannot be parsed are skipped and their count is logged.
+func (p Parser) ToHTTPRequestsFromAccessLog(file string, options AccessLogOptions) ([]Request, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("access log %s: %v", file, err)
+	}
+	defer f.Close()
+
+	methods := make(map[string]bool)
+	for _, method := range options.Methods {
+		methods[strings.ToUpper(method)] = true
+	}
+	if len(methods) == 0 {
+		methods["GET"] = true
+	}
+
+	var requests []Request
+	indexes := make(map[string]int)
+	var lineNumber, invalidLines, skippedLines int
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lineNumber++
+		if options.SampleEvery > 1 && (lineNumber-1)%options.SampleEvery != 0 {
+			continue
+		}
+
+		matches := accessLogRegex.FindStringSubmatch(scanner.Text())
+		if matches == nil {
+			invalidLines++
+			continue
+		}
+		method, path := strings.ToUpper(matches[1]), matches[2]
+		if err := p.validateMethod(method); err != nil {
+			invalidLines++
+			continue
+		}
+		if !methods[method] {
+			continue
+		}
+
+		key := method + " " + path
+		if i, ok := indexes[key]; ok {
+			requests[i].Weight++
+			continue
+		}
+		if options.MaxRequests > 0 && len(requests) >= options.MaxRequests {
+			skippedLines++
+			continue
+		}
+		indexes[key] = len(requests)
+		requests = append(requests, Request{Method: method, Path: path, Weight: 1})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("access log %s: line %d: %v", file, lineNumber+1, err)
+	}
+
+	if invalidLines > 0 {
+		log.Printf("Access log %s: skipped %d lines that could not be parsed", file, invalidLines)
+	}
+	if skippedLines > 0 {
+		log.Printf("Access log %s: skipped %d lines after loading the maximum of %d distinct requests", file, skippedLines, options.MaxRequests)
+	}
+	log.Printf("Access log %s: loaded %d distinct requests", file, len(requests))
+	return requests, nil
+}
@@ -0,0 +1,69 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const accessLog = `127.0.0.1 - - [10/Oct/2020:13:55:36 -0700] "GET /search?q=foo HTTP/1.1" 200 2326
+127.0.0.1 - frank [10/Oct/2020:13:55:37 -0700] "GET /search?q=foo HTTP/1.1" 200 2326 "http://example.com/" "Mozilla/5.0"
+127.0.0.1 - - [10/Oct/2020:13:55:38 -0700] "POST /cart HTTP/1.1" 201 12
+not an access log line
+127.0.0.1 - - [10/Oct/2020:13:55:39 -0700] "GET /products/1 HTTP/1.1" 200 512 "-" "curl/7.64.1"
+127.0.0.1 - - [10/Oct/2020:13:55:40 -0700] "GET /search?q=foo HTTP/1.1" 200 2326
+`
+
+func TestHttp_AccessLogToHttpRequests(t *testing.T) {
+	file := writeTempFile(t, accessLog)
+	defer os.Remove(file)
+
+	requests, err := ToHTTPRequestsFromAccessLog(file, AccessLogOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []Request{
+		{Method: "GET", Path: "/search?q=foo", Weight: 3},
+		{Method: "GET", Path: "/products/1", Weight: 1},
+	}, requests)
+}
+
+func TestHttp_AccessLogMethods(t *testing.T) {
+	file := writeTempFile(t, accessLog)
+	defer os.Remove(file)
+
+	requests, err := ToHTTPRequestsFromAccessLog(file, AccessLogOptions{Methods: []string{"post"}})
+	require.NoError(t, err)
+	assert.Equal(t, []Request{{Method: "POST", Path: "/cart", Weight: 1}}, requests)
+}
+
+func TestHttp_AccessLogSamplingAndLimit(t *testing.T) {
+	file := writeTempFile(t, accessLog)
+	defer os.Remove(file)
+
+	// lines 1, 3 and 5
+	requests, err := ToHTTPRequestsFromAccessLog(file, AccessLogOptions{SampleEvery: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []Request{
+		{Method: "GET", Path: "/search?q=foo", Weight: 1},
+		{Method: "GET", Path: "/products/1", Weight: 1},
+	}, requests)
+
+	requests, err = ToHTTPRequestsFromAccessLog(file, AccessLogOptions{MaxRequests: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []Request{{Method: "GET", Path: "/search?q=foo", Weight: 3}}, requests)
+}
@@ -0,0 +1,166 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maximum number of bytes of the response body read to check the body assertions
+const maxAssertedBody = 1 << 20
+
+// ErrBodyAssertion is returned when the body of a response does not satisfy the body assertions of the request.
+var ErrBodyAssertion = errors.New("body assertion failed")
+
+var jsonPathStepRegex = regexp.MustCompile(`^(?:\.(\w+)|\[(\d+)\])`)
+
+// BodyAssertions are the checks that the body of a response must pass to count as successful.
+// Contains is a substring of the body, Matches a regular expression and JSONPath maps paths e.g. $.status or $.items[0].id
+// to the value expected at that path. Only the first MB of the body is checked.
+type BodyAssertions struct {
+	Contains string
+	Matches  *regexp.Regexp
+	JSONPath map[string]string
+}
+
+// NewBodyAssertions validates the regular expression and JSON paths and returns the body assertions.
+func NewBodyAssertions(contains, matches string, jsonPaths map[string]string) (BodyAssertions, error) {
+	assertions := BodyAssertions{Contains: contains, JSONPath: jsonPaths}
+	if matches != "" {
+		regex, err := regexp.Compile(matches)
+		if err != nil {
+			return BodyAssertions{}, fmt.Errorf("invalid bodyMatches: %v", err)
+		}
+		assertions.Matches = regex
+	}
+	for path := range jsonPaths {
+		if _, err := parseJSONPath(path); err != nil {
+			return BodyAssertions{}, err
+		}
+	}
+	return assertions, nil
+}
+
+// isEmpty returns true if there are no assertions, in which case the body does not need to be read.
+func (a BodyAssertions) isEmpty() bool {
+	return a.Contains == "" && a.Matches == nil && len(a.JSONPath) == 0
+}
+
+// check returns an error wrapping ErrBodyAssertion listing the assertions that the body does not pass.
+// If truncated is set, the body is only the beginning of the response body.
+func (a BodyAssertions) check(body []byte, truncated bool) error {
+	var failed []string
+	if a.Contains != "" && !strings.Contains(string(body), a.Contains) {
+		failed = append(failed, fmt.Sprintf("body does not contain %q", a.Contains))
+	}
+	if a.Matches != nil && !a.Matches.Match(body) {
+		failed = append(failed, fmt.Sprintf("body does not match %q", a.Matches.String()))
+	}
+	if len(a.JSONPath) > 0 {
+		failed = append(failed, a.checkJSONPath(body, truncated)...)
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrBodyAssertion, strings.Join(failed, ", "))
+}
+
+// checkJSONPath returns the JSON path assertions that the body does not pass, sorted by path.
+func (a BodyAssertions) checkJSONPath(body []byte, truncated bool) []string {
+	if truncated {
+		return []string{fmt.Sprintf("body is larger than %d bytes and cannot be parsed as JSON", maxAssertedBody)}
+	}
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return []string{fmt.Sprintf("body is not valid JSON: %v", err)}
+	}
+
+	var failed []string
+	for path, expected := range a.JSONPath {
+		// already validated when the request was created
+		steps, _ := parseJSONPath(path)
+		value, ok := lookupJSONPath(document, steps)
+		if !ok {
+			failed = append(failed, fmt.Sprintf("%s not found", path))
+		} else if actual := jsonValueString(value); actual != expected {
+			failed = append(failed, fmt.Sprintf("%s is %q, expected %q", path, actual, expected))
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// parseJSONPath parses a path made of keys and array indexes e.g. $.items[0].id into its steps.
+// Keys are strings and indexes ints.
+func parseJSONPath(path string) ([]interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid JSON path %s, expected it to start with $", path)
+	}
+	var steps []interface{}
+	for rest := path[1:]; rest != ""; {
+		m := jsonPathStepRegex.FindStringSubmatch(rest)
+		if m == nil {
+			return nil, fmt.Errorf("invalid JSON path %s at %s, only .key and [index] are supported", path, rest)
+		}
+		if m[1] != "" {
+			steps = append(steps, m[1])
+		} else {
+			index, _ := strconv.Atoi(m[2])
+			steps = append(steps, index)
+		}
+		rest = rest[len(m[0]):]
+	}
+	return steps, nil
+}
+
+// lookupJSONPath returns the value at the steps of the path in the decoded JSON document.
+func lookupJSONPath(document interface{}, steps []interface{}) (interface{}, bool) {
+	value := document
+	for _, step := range steps {
+		switch s := step.(type) {
+		case string:
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[s]; !ok {
+				return nil, false
+			}
+		case int:
+			array, ok := value.([]interface{})
+			if !ok || s >= len(array) {
+				return nil, false
+			}
+			value = array[s]
+		}
+	}
+	return value, true
+}
+
+// jsonValueString returns strings as they are and any other value encoded as JSON e.g. 3, true or null.
+func jsonValueString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	// cannot fail as the value was decoded from JSON
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
@@ -0,0 +1,47 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyAssertionsJSONPath(t *testing.T) {
+	body := []byte(`{"status":"UP","count":3,"ratio":0.5,"items":[{"id":"a"},{"id":"b"}],"cache":null}`)
+
+	assertions, err := NewBodyAssertions("", "", map[string]string{"$.status": "UP", "$.count": "3", "$.ratio": "0.5", "$.items[1].id": "b", "$.cache": "null"})
+	require.NoError(t, err)
+	assert.NoError(t, assertions.check(body, false))
+
+	assertions, err = NewBodyAssertions("", "", map[string]string{"$.items[2].id": "c", "$.status.code": "200", "$.count": "4"})
+	require.NoError(t, err)
+	assert.EqualError(t, assertions.check(body, false), `body assertion failed: $.count is "3", expected "4", $.items[2].id not found, $.status.code not found`)
+
+	assert.EqualError(t, assertions.check([]byte("<html>"), false), "body assertion failed: body is not valid JSON: invalid character '<' looking for beginning of value")
+	assert.Error(t, assertions.check(body, true))
+}
+
+func TestBodyAssertionsContainsAndMatches(t *testing.T) {
+	assertions, err := NewBodyAssertions("UP", "^\\{.*\\}$", nil)
+	require.NoError(t, err)
+	assert.NoError(t, assertions.check([]byte(`{"status":"UP"}`), false))
+	assert.EqualError(t, assertions.check([]byte(`status: DOWN`), false), `body assertion failed: body does not contain "UP", body does not match "^\\{.*\\}$"`)
+
+	assert.True(t, BodyAssertions{}.isEmpty())
+	assert.False(t, assertions.isEmpty())
+}
@@ -0,0 +1,112 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authorization is the value of the Authorization header built from a credentials file, e.g. a bearer token or a basic auth password.
+// The file is read again if it changes, so that credentials refreshed by e.g. a sidecar during a long warm up are picked up.
+// If the file cannot be read again, the last credentials are kept.
+// The value is never logged: printing an Authorization only shows its scheme and file.
+type Authorization struct {
+	scheme string
+	path   string
+	format func(secret string) string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	value   string
+}
+
+// NewBearerAuthorization returns the bearer Authorization with the token in the file.
+func NewBearerAuthorization(tokenFile string) (*Authorization, error) {
+	return newAuthorization("Bearer", tokenFile, func(token string) string {
+		return "Bearer " + strings.TrimSpace(token)
+	})
+}
+
+// NewBasicAuthorization returns the basic Authorization of the user with the password in the file.
+func NewBasicAuthorization(user, passwordFile string) (*Authorization, error) {
+	if user == "" {
+		return nil, fmt.Errorf("basic auth user cannot be empty")
+	}
+	return newAuthorization("Basic", passwordFile, func(password string) string {
+		password = strings.TrimRight(password, "\r\n")
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	})
+}
+
+func newAuthorization(scheme, path string, format func(string) string) (*Authorization, error) {
+	a := &Authorization{scheme: scheme, path: path, format: format}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s credentials file: %v", scheme, err)
+	}
+	if err := a.read(info); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Value returns the value of the Authorization header, reading the credentials file again if it changed since it was last read.
+func (a *Authorization) Value() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	info, err := os.Stat(a.path)
+	if err != nil {
+		log.Printf("%s credentials file: %v, using the last credentials", a.scheme, err)
+		return a.value
+	}
+	if info.ModTime().Equal(a.modTime) && info.Size() == a.size {
+		return a.value
+	}
+	if err := a.read(info); err != nil {
+		log.Printf("%v, using the last credentials", err)
+	} else {
+		log.Printf("%s credentials file %s changed, using the new credentials", a.scheme, a.path)
+	}
+	return a.value
+}
+
+// String returns the scheme and the credentials file, so that the credentials are redacted when logged.
+func (a *Authorization) String() string {
+	return fmt.Sprintf("%s [REDACTED] from %s", a.scheme, a.path)
+}
+
+// read reads the credentials file. It must be called with the lock held, except when the Authorization is created.
+func (a *Authorization) read(info os.FileInfo) error {
+	secret, err := ioutil.ReadFile(a.path)
+	if err != nil {
+		return fmt.Errorf("%s credentials file: %v", a.scheme, err)
+	}
+	if strings.TrimSpace(string(secret)) == "" {
+		return fmt.Errorf("%s credentials file %s is empty", a.scheme, a.path)
+	}
+	a.modTime = info.ModTime()
+	a.size = info.Size()
+	a.value = a.format(string(secret))
+	return nil
+}
@@ -0,0 +1,76 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBearerAuthorizationRefresh(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("first\n"), 0600))
+
+	authorization, err := NewBearerAuthorization(tokenFile)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer first", authorization.Value())
+
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("second-token\n"), 0600))
+	assert.Equal(t, "Bearer second-token", authorization.Value())
+
+	// the last token is kept if the file cannot be read
+	require.NoError(t, os.Remove(tokenFile))
+	assert.Equal(t, "Bearer second-token", authorization.Value())
+	assert.NotContains(t, authorization.String(), "second-token")
+}
+
+func TestBasicAuthorization(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	passwordFile := filepath.Join(dir, "password")
+	require.NoError(t, ioutil.WriteFile(passwordFile, []byte("open sesame\n"), 0600))
+
+	authorization, err := NewBasicAuthorization("Aladdin", passwordFile)
+	require.NoError(t, err)
+	assert.Equal(t, "Basic QWxhZGRpbjpvcGVuIHNlc2FtZQ==", authorization.Value())
+
+	// same size, so only the modification time changes
+	require.NoError(t, ioutil.WriteFile(passwordFile, []byte("close sesam\n"), 0600))
+	require.NoError(t, os.Chtimes(passwordFile, time.Now(), time.Now().Add(time.Minute)))
+	assert.Equal(t, "Basic QWxhZGRpbjpjbG9zZSBzZXNhbQ==", authorization.Value())
+}
+
+func TestAuthorizationInvalidFile(t *testing.T) {
+	_, err := NewBearerAuthorization("missing")
+	assert.Error(t, err)
+
+	dir, err := ioutil.TempDir("", "auth")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	emptyFile := filepath.Join(dir, "empty")
+	require.NoError(t, ioutil.WriteFile(emptyFile, []byte("\n"), 0600))
+	_, err = NewBasicAuthorization("user", emptyFile)
+	assert.Error(t, err)
+}
@@ -16,72 +16,555 @@ package http
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"mittens/pkg/response"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/http2"
 )
 
+const defaultContentType = "application/json"
+
+// CompressGzip is the value of Request.Compress for gzip-compressed bodies.
+const CompressGzip = "gzip"
+
+// AcceptEncodingIdentity is the value of TransportConfig.AcceptEncoding to send no Accept-Encoding header, so that responses are not compressed.
+const AcceptEncodingIdentity = "identity"
+
+// maximum number of bytes of the response body kept in the response for logging
+const maxBodySample = 256
+
 // Client is a wrapper for the HTTP Client which includes a host.
+// Like the gRPC client, it is created once and shared by all the workers so that connections are reused across requests.
+// Settings that only need to happen once, such as logging the negotiated protocol, are guarded by a sync.Once.
 type Client struct {
-	httpClient *http.Client
-	host       string
+	httpClient               *http.Client
+	host                     string
+	protocolOnce             *sync.Once
+	streamingResponseTimeout time.Duration
+	acceptEncoding           string
+	timingBreakdown          bool
+	redirects                RedirectPolicy
+	transport                *http.Transport
+}
+
+// TransportConfig holds the settings of the transport shared by all the requests of a client.
+// Zero values mean no limit, except for MaxIdleConnsPerHost which defaults to MaxIdleConns as all the requests go to the same host.
+// If DisableKeepAlives is set, a new connection is opened for every request. If Proxy is not set, the proxy is taken from the HTTP_PROXY/HTTPS_PROXY environment variables.
+// Proxy can be an http://, https:// or socks5:// URL. Either way, hosts in the NO_PROXY environment variable and loopback addresses are not proxied.
+// If UnixSocket is set, connections are made to the Unix domain socket at that path instead of the host, and no proxy is used.
+// Resolve maps host:port addresses to the IP addresses that connections are made to, without changing the URL, Host header or TLS server name.
+// If ClientCertificate is set, it is presented to servers that request a client certificate (mTLS).
+// If RootCAs is set, server certificates are verified using these CAs instead of the system ones.
+// If TLSServerName is set, it is sent as the TLS server name (SNI) and used to verify the server certificate instead of the host,
+// which allows e.g. connecting to localhost while presenting the public name of the target.
+// DialTimeout, TLSHandshakeTimeout and ResponseHeaderTimeout limit each phase of a request and Timeout the whole request. Zero means no timeout.
+// If StreamingResponseTimeout is set, response bodies are read for at most that long and then closed, so that never-ending streams
+// such as server-sent events do not block the request. Reaching it is not an error. It should be shorter than Timeout.
+// Redirects controls whether redirect responses are followed.
+// If CookieJar is set, cookies set by the responses are stored and sent with the next requests, like in a browser session.
+// HTTP/2 is negotiated with HTTPS servers using ALPN unless DisableHTTP2 is set.
+// AcceptEncoding is sent as the Accept-Encoding header so that the target compresses the responses. It defaults to gzip, as the
+// standard transport requests, and no header is sent if it is AcceptEncodingIdentity.
+// If H2C is set, HTTP/2 is used without TLS (prior knowledge) for plain HTTP servers. In this case the connection pool settings, the proxy and the TLS settings are not used.
+// If TimingBreakdown is set, the duration of the phases of every request, e.g. DNS or TLS handshake, is captured using httptrace.
+type TransportConfig struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	DisableKeepAlives   bool
+	Proxy               *url.URL
+	UnixSocket          string
+	Resolve             Resolve
+	ClientCertificate   *tls.Certificate
+	RootCAs             *x509.CertPool
+	TLSServerName       string
+
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	Timeout               time.Duration
+
+	StreamingResponseTimeout time.Duration
+
+	Redirects RedirectPolicy
+	CookieJar bool
+
+	AcceptEncoding string
+
+	DisableHTTP2 bool
+	H2C          bool
+
+	TimingBreakdown bool
 }
 
 // NewClient creates a new HTTP client for a given host.
 // If insecure is true, the client will not verify the server's certificate chain and host name.
-func NewClient(host string, insecure bool) Client {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+// Connections are reused across requests based on the transport config.
+func NewClient(host string, insecure bool, transportConfig TransportConfig) Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = transportConfig.MaxIdleConns
+	// all the requests go to the same host so by default allow as many idle connections to it as in total
+	transport.MaxIdleConnsPerHost = transportConfig.MaxIdleConns
+	if transportConfig.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = transportConfig.MaxIdleConnsPerHost
+	}
+	transport.MaxConnsPerHost = transportConfig.MaxConnsPerHost
+	transport.IdleConnTimeout = transportConfig.IdleConnTimeout
+	if transportConfig.DisableKeepAlives {
+		log.Printf("HTTP client: keep-alive disabled")
+		transport.DisableKeepAlives = true
+	}
+	transport.TLSHandshakeTimeout = transportConfig.TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = transportConfig.ResponseHeaderTimeout
+	// the transport would otherwise decompress the responses, hiding their compressed size, so gzip is requested by the client instead
+	transport.DisableCompression = true
+	acceptEncoding := transportConfig.AcceptEncoding
+	switch acceptEncoding {
+	case "":
+		acceptEncoding = CompressGzip
+	case AcceptEncodingIdentity:
+		log.Printf("HTTP client: compression disabled")
+		acceptEncoding = ""
+	default:
+		log.Printf("HTTP client: accepting %s encoding", acceptEncoding)
+	}
+	dialer := &net.Dialer{Timeout: transportConfig.DialTimeout, KeepAlive: 30 * time.Second}
+	transport.DialContext = dialer.DialContext
+	if len(transportConfig.Resolve) > 0 {
+		for hostPort, address := range transportConfig.Resolve {
+			log.Printf("HTTP client: resolving %s to %s", hostPort, address)
+		}
+		transport.DialContext = transportConfig.Resolve.dialContext(dialer.DialContext)
+	}
+
+	if transportConfig.Proxy != nil {
+		log.Printf("HTTP client: using proxy %s", redactURL(transportConfig.Proxy))
+		proxyConfig := httpproxy.FromEnvironment()
+		proxyConfig.HTTPProxy = transportConfig.Proxy.String()
+		proxyConfig.HTTPSProxy = transportConfig.Proxy.String()
+		proxyFunc := proxyConfig.ProxyFunc()
+		transport.Proxy = func(r *http.Request) (*url.URL, error) {
+			return proxyFunc(r.URL)
+		}
 	}
 
+	if transportConfig.UnixSocket != "" {
+		log.Printf("HTTP client: using Unix socket %s", transportConfig.UnixSocket)
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", transportConfig.UnixSocket)
+		}
+	}
+
+	tlsConfig := &tls.Config{RootCAs: transportConfig.RootCAs, ServerName: transportConfig.TLSServerName}
+	if transportConfig.TLSServerName != "" {
+		log.Printf("HTTP client: TLS server name %s", transportConfig.TLSServerName)
+	}
 	if insecure {
 		log.Printf("HTTP client: insecure")
-		client.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if transportConfig.ClientCertificate != nil {
+		log.Printf("HTTP client: using client certificate")
+		tlsConfig.Certificates = []tls.Certificate{*transportConfig.ClientCertificate}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if transportConfig.DisableHTTP2 {
+		log.Printf("HTTP client: HTTP/2 disabled")
+		transport.ForceAttemptHTTP2 = false
+		// a non-nil empty map disables HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	client := &http.Client{
+		Timeout:       transportConfig.Timeout,
+		Transport:     transport,
+		CheckRedirect: transportConfig.Redirects.checkRedirect,
+	}
+	if transportConfig.CookieJar {
+		log.Printf("HTTP client: using a cookie jar")
+		client.Jar = newCookieJar()
+	}
+	// HTTPS servers negotiate HTTP/2 using ALPN instead
+	if transportConfig.H2C && !strings.HasPrefix(host, "https://") {
+		log.Printf("HTTP client: using h2c")
+		client.Transport = newH2CTransport(transport)
+	}
+	return Client{httpClient: client, host: strings.TrimRight(host, "/"), protocolOnce: new(sync.Once), streamingResponseTimeout: transportConfig.StreamingResponseTimeout,
+		acceptEncoding: acceptEncoding, timingBreakdown: transportConfig.TimingBreakdown, redirects: transportConfig.Redirects, transport: transport}
+}
+
+// WithCookieJar returns a copy of the client with its own cookie jar, which shares the connections of the client.
+// This allows e.g. each worker to have its own session.
+func (c Client) WithCookieJar() Client {
+	httpClient := *c.httpClient
+	httpClient.Jar = newCookieJar()
+	c.httpClient = &httpClient
+	return c
+}
+
+// newCookieJar returns an empty cookie jar.
+func newCookieJar() http.CookieJar {
+	// cannot fail as there are no options
+	jar, _ := cookiejar.New(nil)
+	return jar
+}
+
+// newH2CTransport returns an HTTP/2 transport that connects to plain HTTP servers without TLS (prior knowledge),
+// dialling connections in the same way as the given transport, so that e.g. resolve overrides and unix sockets are honoured.
+// Dials are aborted if the context of the request is done.
+func newH2CTransport(transport *http.Transport) *http2.Transport {
+	return &http2.Transport{
+		AllowHTTP:          true,
+		DisableCompression: transport.DisableCompression,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return transport.DialContext(ctx, network, addr)
+		},
 	}
-	return Client{httpClient: client, host: strings.TrimRight(host, "/")}
 }
 
 // SendRequest sends a request to the HTTP server and wraps useful information into a Response object.
-func (c Client) SendRequest(method, path string, headers map[string]string, requestBody *string) response.Response {
+// If there is a body, the content type is sent as the Content-Type header unless the headers already include one.
+// The content type defaults to application/json. Multipart bodies are streamed and always sent with their own content type.
+// Responses with an expected status code but without the required response headers or that fail the body assertions are returned with an error.
+// To check the body assertions, up to 1 MB of the body is read into memory.
+// Bodies of requests with gzip compression are compressed before sending, so the bytes sent are the compressed size.
+// If the client accepts an encoding, it is sent as the Accept-Encoding header unless the headers already include one or, as in the standard transport,
+// the request is a HEAD or range request.
+// Responses are never decompressed by the transport: gzip bodies are decompressed when read, so that both the compressed and decompressed sizes are known.
+// The request is cancelled if the context is done before the response is read.
+// If a streaming response timeout is set, the body is only read until the timeout, which does not count as an error.
+// Requests with digest credentials are sent again answering the challenge of a 401 response, and their duration includes both round trips.
+func (c Client) SendRequest(ctx context.Context, request Request) response.Response {
 	const respType = "http"
 	var body io.Reader
-	if requestBody != nil {
-		body = bytes.NewBufferString(*requestBody)
+	var bytesSent int64
+	var counter *countingReader
+	contentType := request.ContentType
+	if len(request.Multipart) > 0 {
+		multipartBody, multipartContentType := newMultipartBody(request.Multipart)
+		defer multipartBody.Close()
+		counter = &countingReader{reader: multipartBody}
+		body = counter
+		contentType = multipartContentType
+	} else if request.Body != nil {
+		buffer := bytes.NewBufferString(*request.Body)
+		if request.Compress == CompressGzip {
+			compressed, err := gzipBody(*request.Body)
+			if err != nil {
+				return response.Response{Duration: time.Duration(0), Err: err, Type: respType}
+			}
+			buffer = compressed
+		}
+		bytesSent = int64(buffer.Len())
+		body = buffer
 	}
 
-	url := fmt.Sprintf("%s/%s", c.host, strings.TrimLeft(path, "/"))
-	req, err := http.NewRequest(method, url, body)
+	url := fmt.Sprintf("%s/%s", c.hostFor(request), strings.TrimLeft(request.Path, "/"))
+	req, err := http.NewRequest(request.Method, url, body)
+	if err == nil {
+		req = req.WithContext(ctx)
+	}
 
 	if err != nil {
-		log.Printf("Failed to create request: %s %s: %v", method, url, err)
+		log.Printf("Failed to create request: %s %s: %v", request.Method, url, err)
 		return response.Response{Duration: time.Duration(0), Err: err, Type: respType}
 	}
 
-	for k, v := range headers {
+	for k, v := range request.Headers {
 		if strings.EqualFold(k, "Host") {
 			req.Host = v
 		}
 		req.Header.Add(k, v)
 	}
+	if request.Body != nil && request.Compress == CompressGzip {
+		req.Header.Set("Content-Encoding", CompressGzip)
+	}
+	if c.acceptEncoding != "" && req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" && req.Method != http.MethodHead {
+		req.Header.Set("Accept-Encoding", c.acceptEncoding)
+	}
+	if len(request.Multipart) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	} else if request.Body != nil && req.Header.Get("Content-Type") == "" {
+		if contentType == "" {
+			contentType = defaultContentType
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	var trace *timingTrace
+	if c.timingBreakdown {
+		var traceCtx context.Context
+		traceCtx, trace = withTimingTrace(req.Context())
+		req = req.WithContext(traceCtx)
+	}
 
 	startTime := time.Now()
 	resp, err := c.httpClient.Do(req)
+	if err == nil && request.DigestAuth != nil && resp.StatusCode == http.StatusUnauthorized {
+		resp, err = request.DigestAuth.authorize(c.httpClient, req, resp)
+	}
 	endTime := time.Now()
+	if counter != nil {
+		bytesSent = counter.Count()
+	}
+	if err == nil && c.protocolOnce != nil {
+		c.protocolOnce.Do(func() { log.Printf("HTTP client: negotiated %s", resp.Proto) })
+	}
+	if err != nil {
+		return response.Response{Duration: endTime.Sub(startTime), Err: err, ErrCause: errorCause(err), Type: respType, BytesSent: bytesSent}
+	}
+	defer resp.Body.Close()
+
+	// streams are closed once the timeout is reached, which makes the read below return
+	var streamClosed int32
+	if c.streamingResponseTimeout > 0 {
+		timer := time.AfterFunc(c.streamingResponseTimeout, func() {
+			atomic.StoreInt32(&streamClosed, 1)
+			resp.Body.Close()
+		})
+		defer timer.Stop()
+	}
+
+	// the whole body is read so that the connection can be reused
+	limit := int64(maxBodySample)
+	if !request.BodyAssertions.isEmpty() {
+		limit = maxAssertedBody
+	}
+	// compressed bodies are decompressed so that they can be checked and logged, counting the compressed bytes read from the connection
+	wire := &countingReader{reader: resp.Body}
+	respBody, err := decompressedBody(resp, wire)
+	var sample []byte
+	var bytesDecompressed, discarded int64
+	if err == nil {
+		sample, err = ioutil.ReadAll(io.LimitReader(respBody, limit))
+		bytesDecompressed = int64(len(sample))
+	}
+	if err == nil {
+		discarded, err = io.Copy(ioutil.Discard, respBody)
+		bytesDecompressed += discarded
+	}
+	bytesRead := wire.Count()
+	var timing *response.Timing
+	if trace != nil {
+		timing = trace.result(time.Since(endTime))
+	}
+	if err != nil && atomic.LoadInt32(&streamClosed) == 1 {
+		err = nil
+	}
+	if err == nil {
+		err = c.redirects.checkResponse(resp)
+	}
+	// the headers and body of responses with an unexpected status code are not checked so that the status code is reported instead
+	if err == nil && request.ExpectedStatusCodes.Contains(resp.StatusCode) {
+		err = checkResponseHeaders(resp.Header, request.RequiredResponseHeaders)
+		if err == nil && !request.BodyAssertions.isEmpty() {
+			err = request.BodyAssertions.check(sample, discarded > 0)
+		}
+	}
+	if len(sample) > maxBodySample {
+		sample = sample[:maxBodySample]
+	}
+	if err != nil {
+		return response.Response{Duration: endTime.Sub(startTime), Err: err, Type: respType, StatusCode: resp.StatusCode, Body: string(sample), BytesSent: bytesSent, BytesRead: bytesRead, BytesDecompressed: bytesDecompressed, Redirects: redirects(resp), Timing: timing}
+	}
+	return response.Response{Duration: endTime.Sub(startTime), Err: nil, Type: respType, StatusCode: resp.StatusCode, Body: string(sample), BytesSent: bytesSent, BytesRead: bytesRead, BytesDecompressed: bytesDecompressed, Redirects: redirects(resp), Timing: timing}
+}
+
+// decompressedBody returns a reader of the decompressed body if the response is gzip-compressed, or the body as is otherwise.
+// Bodies of responses to e.g. HEAD requests are empty even if the response is compressed.
+func decompressedBody(resp *http.Response, body io.Reader) (io.Reader, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), CompressGzip) {
+		return body, nil
+	}
+	reader, err := gzip.NewReader(body)
+	if err == io.EOF {
+		return body, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gzip response body: %v", err)
+	}
+	return reader, nil
+}
+
+// errorCause returns a short description of why a request failed to get a response, so that e.g. timeouts can be told apart from refused connections.
+// It returns an empty string if the cause is unknown.
+func errorCause(err error) string {
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &opErr) && opErr.Op == "proxyconnect", isProxyConnectStatus(err):
+		return "proxy connect error"
+	case errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout():
+		return "dial timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case strings.Contains(err.Error(), "TLS handshake timeout"):
+		return "TLS handshake timeout"
+	case strings.Contains(err.Error(), "timeout awaiting response headers"):
+		return "response header timeout"
+	case errors.Is(err, errTooManyRedirects):
+		return "too many redirects"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case isTimeout(err):
+		return "timeout"
+	}
+	return ""
+}
+
+// isProxyConnectStatus returns true if the error is the status text of a failed CONNECT request to a proxy, e.g. Forbidden.
+// This is the only case in which the transport returns a status as an error rather than as a response.
+func isProxyConnectStatus(err error) bool {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return false
+	}
+	for code := 100; code < 600; code++ {
+		if text := http.StatusText(code); text != "" && urlErr.Err.Error() == text {
+			return true
+		}
+	}
+	return false
+}
+
+// isTimeout returns true if the error is a timeout e.g. because the client timeout was exceeded.
+func isTimeout(err error) bool {
+	var timeoutErr interface{ Timeout() bool }
+	return errors.As(err, &timeoutErr) && timeoutErr.Timeout()
+}
+
+// checkResponseHeaders returns an error listing the required headers that are missing from the response or do not have the required value.
+// Headers with an empty required value only need to be present.
+func checkResponseHeaders(header http.Header, required map[string]string) error {
+	var missing []string
+	for name, value := range required {
+		values, ok := header[http.CanonicalHeaderKey(name)]
+		if ok && (value == "" || containsString(values, value)) {
+			continue
+		}
+		if value == "" {
+			missing = append(missing, name)
+		} else {
+			missing = append(missing, fmt.Sprintf("%s: %s", name, value))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("missing required response headers: %s", strings.Join(missing, ", "))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// hostFor returns the scheme, host and port the request is sent to.
+// Requests that override the host use the scheme of the client unless the host includes one.
+func (c Client) hostFor(request Request) string {
+	if request.Host == "" {
+		return c.host
+	}
+	if strings.Contains(request.Host, "://") {
+		return request.Host
+	}
+	scheme := "http"
+	if i := strings.Index(c.host, "://"); i != -1 {
+		scheme = c.host[:i]
+	}
+	return scheme + "://" + request.Host
+}
+
+// gzipBody returns the body compressed with gzip.
+func gzipBody(body string) (*bytes.Buffer, error) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return &buffer, nil
+}
+
+// countingReader counts the bytes read from a reader whose size is not known in advance.
+// The body is read by the transport in its own goroutine, so the count is updated atomically.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	atomic.AddInt64(&r.count, int64(n))
+	return n, err
+}
+
+// Count returns the number of bytes read so far.
+func (r *countingReader) Count() int64 {
+	return atomic.LoadInt64(&r.count)
+}
+
+// Get sends a GET request to the HTTP server and returns the response body.
+// It returns an error if the request fails or the status code is not 2xx.
+func (c Client) Get(path string) ([]byte, error) {
+	url := fmt.Sprintf("%s/%s", c.host, strings.TrimLeft(path, "/"))
+	resp, err := c.httpClient.Get(url)
 	if err != nil {
-		return response.Response{Duration: endTime.Sub(startTime), Err: err, Type: respType}
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if _, err = io.Copy(ioutil.Discard, resp.Body); err != nil {
-		return response.Response{Duration: endTime.Sub(startTime), Err: err, Type: respType, StatusCode: resp.StatusCode}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// the body is drained so that the connection can be reused
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, fmt.Errorf("GET %s: unexpected status code %d", url, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Close closes the idle connections of the client. Connections in use are closed once their requests finish.
+// The client can still be used after it is closed, in which case new connections are opened.
+func (c Client) Close() {
+	if c.httpClient != nil {
+		log.Print("Closing HTTP client connections")
+		c.httpClient.CloseIdleConnections()
+	}
+}
+
+// redactURL returns the URL as a string without the password, if any, so that it can be logged.
+func redactURL(u *url.URL) string {
+	if u.User == nil {
+		return u.String()
 	}
-	return response.Response{Duration: endTime.Sub(startTime), Err: nil, Type: respType, StatusCode: resp.StatusCode}
+	redacted := *u
+	redacted.User = url.User(u.User.Username())
+	return redacted.String()
 }
@@ -15,11 +15,33 @@
 package http
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func TestRequestSuccess(t *testing.T) {
@@ -31,9 +53,9 @@ func TestRequestSuccess(t *testing.T) {
 	}))
 	defer server.Close()
 
-	c := NewClient(server.URL, false)
+	c := NewClient(server.URL, false, TransportConfig{})
 	reqBody := ""
-	resp := c.SendRequest("GET", path, map[string]string{}, &reqBody)
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: path, Body: &reqBody})
 	assert.Nil(t, resp.Err)
 }
 
@@ -43,16 +65,700 @@ func TestHttpError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	c := NewClient(server.URL, false)
+	c := NewClient(server.URL, false, TransportConfig{})
 	reqBody := ""
-	resp := c.SendRequest("GET", "/", map[string]string{}, &reqBody)
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/", Body: &reqBody})
 	assert.Nil(t, resp.Err)
 	assert.Equal(t, resp.StatusCode, 400)
 }
 
+func TestResponseBytesRead(t *testing.T) {
+	body := strings.Repeat("a", 2*maxBodySample)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, false, TransportConfig{})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Nil(t, resp.Err)
+	assert.Equal(t, int64(len(body)), resp.BytesRead)
+	assert.Equal(t, body[:maxBodySample], resp.Body)
+}
+
 func TestConnectionError(t *testing.T) {
-	c := NewClient("http://localhost:9999", false)
+	c := NewClient("http://localhost:9999", false, TransportConfig{})
 	reqBody := ""
-	resp := c.SendRequest("GET", "/potato", map[string]string{}, &reqBody)
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/potato", Body: &reqBody})
 	assert.NotNil(t, resp.Err)
 }
+
+func TestRequestTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, false, TransportConfig{ResponseHeaderTimeout: 50 * time.Millisecond})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Error(t, resp.Err)
+	assert.Equal(t, "response header timeout", resp.ErrCause)
+
+	c = NewClient(server.URL, false, TransportConfig{Timeout: 50 * time.Millisecond})
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Error(t, resp.Err)
+	assert.Equal(t, "timeout", resp.ErrCause)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	listener.Close()
+	c = NewClient("http://"+listener.Addr().String(), false, TransportConfig{})
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Error(t, resp.Err)
+	assert.Equal(t, "connection refused", resp.ErrCause)
+}
+
+func TestRequestConnectionReuse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		// larger than the body sample so that the rest of the body has to be drained
+		rw.Write(bytes.Repeat([]byte("a"), 64*1024))
+	}))
+	defer server.Close()
+
+	var reused []bool
+	trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+		reused = append(reused, info.Reused)
+	}}
+	ctx := httptrace.WithClientTrace(context.Background(), trace)
+
+	c := NewClient(server.URL, false, TransportConfig{})
+	for i := 0; i < 3; i++ {
+		resp := c.SendRequest(ctx, Request{Method: "GET", Path: "/"})
+		require.Nil(t, resp.Err)
+	}
+	assert.Equal(t, []bool{false, true, true}, reused)
+
+	reused = nil
+	c = NewClient(server.URL, false, TransportConfig{DisableKeepAlives: true})
+	for i := 0; i < 3; i++ {
+		resp := c.SendRequest(ctx, Request{Method: "GET", Path: "/"})
+		require.Nil(t, resp.Err)
+	}
+	assert.Equal(t, []bool{false, false, false}, reused)
+}
+
+func TestRequestStreamingResponseTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+				rw.Write([]byte("data: ping\n\n"))
+				rw.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, false, TransportConfig{StreamingResponseTimeout: 100 * time.Millisecond, Timeout: time.Second})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/events"})
+	assert.Nil(t, resp.Err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.True(t, resp.BytesRead > 0)
+	assert.True(t, resp.Duration < time.Second)
+}
+
+func TestRequestThroughProxy(t *testing.T) {
+	var proxiedHost string
+	proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		proxiedHost = r.URL.Host
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	assert.NoError(t, err)
+
+	c := NewClient("http://target.internal:8080", false, TransportConfig{Proxy: proxyURL})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/potato"})
+	assert.Nil(t, resp.Err)
+	assert.Equal(t, "target.internal:8080", proxiedHost)
+}
+
+func TestRequestNoProxy(t *testing.T) {
+	var proxied bool
+	proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		proxied = true
+	}))
+	defer proxy.Close()
+	target := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	assert.NoError(t, err)
+
+	os.Setenv("NO_PROXY", "target.internal")
+	defer os.Unsetenv("NO_PROXY")
+
+	// loopback addresses are never proxied
+	c := NewClient(target.URL, false, TransportConfig{Proxy: proxyURL})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/potato"})
+	assert.Nil(t, resp.Err)
+
+	c = NewClient("http://target.internal:8080", false, TransportConfig{Proxy: proxyURL})
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/potato"})
+	assert.Error(t, resp.Err)
+	assert.False(t, proxied)
+}
+
+func TestRequestProxyConnectError(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusForbidden)
+	}))
+	proxyURL, err := url.Parse(proxy.URL)
+	assert.NoError(t, err)
+
+	c := NewClient("https://target.internal:8443", false, TransportConfig{Proxy: proxyURL})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/potato"})
+	assert.Error(t, resp.Err)
+	assert.Equal(t, "proxy connect error", resp.ErrCause)
+
+	proxy.Close()
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/potato"})
+	assert.Error(t, resp.Err)
+	assert.Equal(t, "proxy connect error", resp.ErrCause)
+}
+
+func TestRequestContentType(t *testing.T) {
+	contentTypes := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		contentTypes <- r.Header.Get("Content-Type")
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, false, TransportConfig{})
+	reqBody := "user=foo"
+
+	c.SendRequest(context.Background(), Request{Method: "POST", Path: "/", Body: &reqBody})
+	assert.Equal(t, "application/json", <-contentTypes)
+
+	c.SendRequest(context.Background(), Request{Method: "POST", Path: "/", Body: &reqBody, ContentType: "application/x-www-form-urlencoded"})
+	assert.Equal(t, "application/x-www-form-urlencoded", <-contentTypes)
+
+	c.SendRequest(context.Background(), Request{Method: "POST", Path: "/", Headers: map[string]string{"Content-Type": "text/plain"}, Body: &reqBody, ContentType: "application/x-www-form-urlencoded"})
+	assert.Equal(t, "text/plain", <-contentTypes)
+
+	c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Equal(t, "", <-contentTypes)
+}
+
+func TestRequestMultipart(t *testing.T) {
+	file := writeTempFile(t, "file content")
+	defer os.Remove(file)
+
+	type form struct {
+		contentType string
+		field       string
+		file        string
+		filename    string
+	}
+	forms := make(chan form, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		f := form{contentType: r.Header.Get("Content-Type")}
+		if err := r.ParseMultipartForm(1024); err == nil {
+			f.field = r.FormValue("description")
+			if fileHeader, ok := r.MultipartForm.File["upload"]; ok {
+				f.filename = fileHeader[0].Filename
+				content, _ := fileHeader[0].Open()
+				b, _ := ioutil.ReadAll(content)
+				f.file = string(b)
+			}
+		}
+		forms <- f
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, false, TransportConfig{})
+	resp := c.SendRequest(context.Background(), Request{
+		Method:  "POST",
+		Path:    "/upload",
+		Headers: map[string]string{"Content-Type": "text/plain"},
+		Multipart: []MultipartPart{
+			{Name: "description", Value: "a file"},
+			{Name: "upload", File: file},
+		},
+	})
+	assert.Nil(t, resp.Err)
+
+	f := <-forms
+	assert.True(t, strings.HasPrefix(f.contentType, "multipart/form-data; boundary="))
+	assert.Equal(t, "a file", f.field)
+	assert.Equal(t, "file content", f.file)
+	assert.Equal(t, filepath.Base(file), f.filename)
+}
+
+func TestRequestGzip(t *testing.T) {
+	type received struct {
+		contentEncoding string
+		length          int
+		body            string
+	}
+	requests := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		compressed, _ := ioutil.ReadAll(r.Body)
+		req := received{contentEncoding: r.Header.Get("Content-Encoding"), length: len(compressed)}
+		if reader, err := gzip.NewReader(bytes.NewReader(compressed)); err == nil {
+			body, _ := ioutil.ReadAll(reader)
+			req.body = string(body)
+		}
+		requests <- req
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, false, TransportConfig{})
+	reqBody := `{"key": "value"}`
+	resp := c.SendRequest(context.Background(), Request{Method: "POST", Path: "/", Body: &reqBody, Compress: CompressGzip})
+	assert.Nil(t, resp.Err)
+
+	req := <-requests
+	assert.Equal(t, "gzip", req.contentEncoding)
+	assert.Equal(t, reqBody, req.body)
+	assert.Equal(t, int64(req.length), resp.BytesSent)
+}
+
+func TestRequestHostOverride(t *testing.T) {
+	hosts := make(chan string, 1)
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		hosts <- r.Host
+	})
+	target := httptest.NewServer(handler)
+	defer target.Close()
+	admin := httptest.NewServer(handler)
+	defer admin.Close()
+
+	c := NewClient(target.URL, false, TransportConfig{})
+	adminURL, err := url.Parse(admin.URL)
+	assert.NoError(t, err)
+
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/admin", Host: adminURL.Host})
+	assert.Nil(t, resp.Err)
+	assert.Equal(t, adminURL.Host, <-hosts)
+
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/admin", Host: admin.URL})
+	assert.Nil(t, resp.Err)
+	assert.Equal(t, adminURL.Host, <-hosts)
+
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Nil(t, resp.Err)
+	assert.Equal(t, strings.TrimPrefix(target.URL, "http://"), <-hosts)
+}
+
+func TestRequestRequiredResponseHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("X-Cache", r.URL.Query().Get("cache"))
+		if r.URL.Path == "/error" {
+			rw.WriteHeader(500)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, false, TransportConfig{})
+	required := map[string]string{"x-cache": "HIT", "Content-Length": ""}
+
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/?cache=HIT", RequiredResponseHeaders: required})
+	assert.Nil(t, resp.Err)
+
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/?cache=MISS", RequiredResponseHeaders: required})
+	assert.EqualError(t, resp.Err, "missing required response headers: x-cache: HIT")
+
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/", RequiredResponseHeaders: map[string]string{"X-Warmed": "", "X-Cache": "HIT"}})
+	assert.EqualError(t, resp.Err, "missing required response headers: X-Cache: HIT, X-Warmed")
+
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/error", RequiredResponseHeaders: required})
+	assert.Nil(t, resp.Err)
+	assert.Equal(t, 500, resp.StatusCode)
+}
+
+func TestRequestBodyAssertions(t *testing.T) {
+	const payload = `{"status":"DEGRADED","checks":[{"name":"db","up":true}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/large" {
+			rw.Write([]byte(strings.Repeat(" ", maxAssertedBody)))
+		}
+		rw.Write([]byte(payload))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, false, TransportConfig{})
+	assertions, err := NewBodyAssertions("DEGRADED", `"status":"\w+"`, map[string]string{"$.checks[0].up": "true"})
+	require.NoError(t, err)
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/", BodyAssertions: assertions})
+	assert.Nil(t, resp.Err)
+
+	assertions, err = NewBodyAssertions("", "", map[string]string{"$.status": "UP"})
+	require.NoError(t, err)
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/", BodyAssertions: assertions})
+	assert.True(t, errors.Is(resp.Err, ErrBodyAssertion))
+	assert.EqualError(t, resp.Err, `body assertion failed: $.status is "DEGRADED", expected "UP"`)
+	assert.Contains(t, resp.Body, "DEGRADED")
+
+	// only the beginning of large bodies is read
+	assertions, err = NewBodyAssertions("DEGRADED", "", nil)
+	require.NoError(t, err)
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/large", BodyAssertions: assertions})
+	assert.True(t, errors.Is(resp.Err, ErrBodyAssertion))
+	assert.Len(t, resp.Body, maxBodySample)
+	assert.Equal(t, int64(maxAssertedBody+len(payload)), resp.BytesRead)
+}
+
+func TestRequestAcceptEncoding(t *testing.T) {
+	const payload = `{"status":"UP","padding":"` + "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa" + `"}`
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte(payload))
+	writer.Close()
+
+	acceptEncodings := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		acceptEncodings <- r.Header.Get("Accept-Encoding")
+		if r.Header.Get("Accept-Encoding") == "gzip" {
+			rw.Header().Set("Content-Encoding", "gzip")
+			rw.Write(compressed.Bytes())
+			return
+		}
+		rw.Write([]byte(payload))
+	}))
+	defer server.Close()
+
+	assertions, err := NewBodyAssertions("", "", map[string]string{"$.status": "UP"})
+	require.NoError(t, err)
+
+	c := NewClient(server.URL, false, TransportConfig{AcceptEncoding: "gzip"})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/", BodyAssertions: assertions})
+	require.NoError(t, resp.Err)
+	assert.Equal(t, "gzip", <-acceptEncodings)
+	assert.Equal(t, int64(compressed.Len()), resp.BytesRead)
+	assert.Equal(t, int64(len(payload)), resp.BytesDecompressed)
+	assert.Equal(t, payload, resp.Body)
+
+	// gzip is accepted by default, as with the standard transport
+	c = NewClient(server.URL, false, TransportConfig{})
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	require.NoError(t, resp.Err)
+	assert.Equal(t, "gzip", <-acceptEncodings)
+	assert.Equal(t, int64(compressed.Len()), resp.BytesRead)
+	assert.Equal(t, int64(len(payload)), resp.BytesDecompressed)
+
+	c = NewClient(server.URL, false, TransportConfig{AcceptEncoding: AcceptEncodingIdentity})
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	require.NoError(t, resp.Err)
+	assert.Equal(t, "", <-acceptEncodings)
+	assert.Equal(t, int64(len(payload)), resp.BytesRead)
+	assert.Equal(t, int64(len(payload)), resp.BytesDecompressed)
+}
+
+func TestRequestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mittens")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "app.sock")
+
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	hosts := make(chan string, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		hosts <- r.Host
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	c := NewClient("http://my-service:8080", false, TransportConfig{UnixSocket: socket})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Nil(t, resp.Err)
+	assert.Equal(t, "my-service:8080", <-hosts)
+}
+
+func TestRequestClientCertificate(t *testing.T) {
+	clientCertificate := newTestCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCertificate.Leaf)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	c := NewClient(server.URL, true, TransportConfig{})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Error(t, resp.Err)
+
+	c = NewClient(server.URL, true, TransportConfig{ClientCertificate: &clientCertificate})
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Nil(t, resp.Err)
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+func TestRequestRootCAs(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	c := NewClient(server.URL, false, TransportConfig{})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Error(t, resp.Err)
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	c = NewClient(server.URL, false, TransportConfig{RootCAs: rootCAs})
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Nil(t, resp.Err)
+}
+
+func TestRequestTLSServerNameAndHostHeader(t *testing.T) {
+	type received struct{ serverName, host string }
+	requests := make(chan received, 1)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests <- received{serverName: r.TLS.ServerName, host: r.Host}
+	}))
+	defer server.Close()
+
+	// the certificate of the test server is valid for example.com
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	c := NewClient(server.URL, false, TransportConfig{RootCAs: rootCAs, TLSServerName: "example.com"})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/", Headers: map[string]string{"Host": "www.brand-a.com"}})
+	require.NoError(t, resp.Err)
+	assert.Equal(t, received{serverName: "example.com", host: "www.brand-a.com"}, <-requests)
+
+	c = NewClient(server.URL, false, TransportConfig{RootCAs: rootCAs, TLSServerName: "www.brand-a.com"})
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Error(t, resp.Err)
+}
+
+func TestRequestHTTP2(t *testing.T) {
+	protocols := make(chan string, 1)
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		protocols <- r.Proto
+	})
+
+	server := httptest.NewUnstartedServer(handler)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	c := NewClient(server.URL, true, TransportConfig{})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Nil(t, resp.Err)
+	assert.Equal(t, "HTTP/2.0", <-protocols)
+
+	c = NewClient(server.URL, true, TransportConfig{DisableHTTP2: true})
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Nil(t, resp.Err)
+	assert.Equal(t, "HTTP/1.1", <-protocols)
+}
+
+func TestRequestH2C(t *testing.T) {
+	protocols := make(chan string, 1)
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		protocols <- r.Proto
+	}), &http2.Server{}))
+	defer server.Close()
+
+	c := NewClient(server.URL, false, TransportConfig{H2C: true})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Nil(t, resp.Err)
+	assert.Equal(t, "HTTP/2.0", <-protocols)
+}
+
+func TestH2CDialIsCancelledWithTheRequest(t *testing.T) {
+	dialling := make(chan struct{})
+	transport := &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		close(dialling)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-dialling
+		cancel()
+	}()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost/", nil)
+	require.NoError(t, err)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := newH2CTransport(transport).RoundTrip(req)
+		errs <- err
+	}()
+	select {
+	case err := <-errs:
+		assert.True(t, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("h2c dial was not cancelled")
+	}
+}
+
+// newTestCertificate returns a self-signed certificate for localhost.
+func newTestCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestRequestRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/items":
+			http.Redirect(rw, r, "/items/", http.StatusMovedPermanently)
+		case "/items/":
+			http.Redirect(rw, r, "/items/all", http.StatusFound)
+		case "/loop":
+			http.Redirect(rw, r, "/loop", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, false, TransportConfig{})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/items"})
+	assert.Nil(t, resp.Err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, 2, resp.Redirects)
+
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/loop"})
+	assert.Error(t, resp.Err)
+	assert.Equal(t, "too many redirects", resp.ErrCause)
+
+	c = NewClient(server.URL, false, TransportConfig{Redirects: RedirectPolicy{MaxRedirects: 1}})
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/items"})
+	assert.Error(t, resp.Err)
+	assert.Equal(t, "too many redirects", resp.ErrCause)
+
+	c = NewClient(server.URL, false, TransportConfig{Redirects: RedirectPolicy{NeverFollow: true}})
+	resp = c.SendRequest(context.Background(), Request{Method: "POST", Path: "/items"})
+	assert.Nil(t, resp.Err)
+	assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal(t, 0, resp.Redirects)
+
+	c = NewClient(server.URL, false, TransportConfig{Redirects: RedirectPolicy{NeverFollow: true, Reject: true}})
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/items"})
+	require.Error(t, resp.Err)
+	assert.Equal(t, "redirect with status code 301 to /items/ not followed", resp.Err.Error())
+	assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal(t, 0, resp.Redirects)
+}
+
+func TestParseRedirectPolicy(t *testing.T) {
+	policy, err := ParseRedirectPolicy("follow")
+	require.NoError(t, err)
+	assert.Equal(t, RedirectPolicy{}, policy)
+
+	policy, err = ParseRedirectPolicy("never-follow")
+	require.NoError(t, err)
+	assert.Equal(t, RedirectPolicy{NeverFollow: true}, policy)
+
+	policy, err = ParseRedirectPolicy("follow-with-limit=3")
+	require.NoError(t, err)
+	assert.Equal(t, RedirectPolicy{MaxRedirects: 3}, policy)
+
+	for _, invalid := range []string{"always", "follow-with-limit=0", "follow-with-limit=x"} {
+		_, err = ParseRedirectPolicy(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestRequestCookieJar(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(rw, &http.Cookie{Name: "session", Value: "abc"})
+			return
+		}
+		if _, err := r.Cookie("session"); err != nil {
+			rw.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, false, TransportConfig{})
+	c.SendRequest(context.Background(), Request{Method: "POST", Path: "/login"})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/dashboard"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	c = NewClient(server.URL, false, TransportConfig{CookieJar: true})
+	c.SendRequest(context.Background(), Request{Method: "POST", Path: "/login"})
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/dashboard"})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// a copy with its own jar does not share the session
+	worker := c.WithCookieJar()
+	resp = worker.SendRequest(context.Background(), Request{Method: "GET", Path: "/dashboard"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	worker.SendRequest(context.Background(), Request{Method: "POST", Path: "/login"})
+	resp = worker.SendRequest(context.Background(), Request{Method: "GET", Path: "/dashboard"})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestClientClose(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var reused []bool
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+		reused = append(reused, info.Reused)
+	}})
+
+	c := NewClient(server.URL, false, TransportConfig{})
+	c.SendRequest(ctx, Request{Method: "GET", Path: "/"})
+	c.Close()
+	resp := c.SendRequest(ctx, Request{Method: "GET", Path: "/"})
+	assert.Nil(t, resp.Err)
+	assert.Equal(t, []bool{false, false}, reused)
+
+	// closing a zero value client is a no-op
+	Client{}.Close()
+}
+
+func TestRequestTimingBreakdown(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("pong"))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, true, TransportConfig{TimingBreakdown: true})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/ping"})
+	require.NoError(t, resp.Err)
+	require.NotNil(t, resp.Timing)
+	assert.True(t, resp.Timing.Connect > 0)
+	assert.True(t, resp.Timing.TLSHandshake > 0)
+	assert.True(t, resp.Timing.TimeToFirstByte > 0)
+
+	// the second request reuses the connection so it does not connect again
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/ping"})
+	require.NoError(t, resp.Err)
+	require.NotNil(t, resp.Timing)
+	assert.Equal(t, time.Duration(0), resp.Timing.Connect)
+	assert.Equal(t, time.Duration(0), resp.Timing.TLSHandshake)
+	assert.True(t, resp.Timing.TimeToFirstByte > 0)
+
+	c = NewClient(server.URL, true, TransportConfig{})
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/ping"})
+	require.NoError(t, resp.Err)
+	assert.Nil(t, resp.Timing)
+}
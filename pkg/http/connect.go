@@ -0,0 +1,107 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mittens/pkg/response"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ConnectionType is the type of the responses of Client.Connect, so that connections are reported separately from requests.
+const ConnectionType = "connection"
+
+// Connect opens a new connection to the host, completing the TLS handshake for HTTPS hosts, and closes it.
+// The connection is made in the same way as for requests, e.g. through the Unix socket or to the resolved address if set, but never through a proxy.
+// The duration of the response is the time to connect, including the TLS handshake.
+// If path is set, a HEAD request to that path is sent on the connection once connected and its status code is the status code of the response.
+func (c Client) Connect(ctx context.Context, path string) response.Response {
+	u, err := url.Parse(c.host)
+	if err != nil {
+		return response.Response{Err: err, Type: ConnectionType}
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	address := net.JoinHostPort(u.Hostname(), port)
+
+	startTime := time.Now()
+	conn, err := c.transport.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return response.Response{Duration: time.Since(startTime), Err: err, ErrCause: errorCause(err), Type: ConnectionType}
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if u.Scheme == "https" {
+		tlsConfig := c.transport.TLSClientConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		// the request, if any, is sent using HTTP/1.1
+		tlsConfig.NextProtos = nil
+		tlsConn := tls.Client(conn, tlsConfig)
+		if timeout := c.transport.TLSHandshakeTimeout; timeout > 0 {
+			tlsConn.SetDeadline(time.Now().Add(timeout))
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			return response.Response{Duration: time.Since(startTime), Err: fmt.Errorf("TLS handshake: %v", err), ErrCause: errorCause(err), Type: ConnectionType}
+		}
+		tlsConn.SetDeadline(time.Time{})
+		conn = tlsConn
+	}
+	duration := time.Since(startTime)
+
+	if path == "" {
+		return response.Response{Duration: duration, Type: ConnectionType}
+	}
+	statusCode, err := sendHead(conn, u.Host, path)
+	return response.Response{Duration: duration, Err: err, Type: ConnectionType, StatusCode: statusCode}
+}
+
+// sendHead sends a HEAD request on the connection and returns the status code of the response.
+func sendHead(conn net.Conn, host, path string) (int, error) {
+	req, err := http.NewRequest("HEAD", "/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Host = host
+	req.Close = true
+	if err := req.Write(conn); err != nil {
+		return 0, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
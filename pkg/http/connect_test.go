@@ -0,0 +1,65 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnect(t *testing.T) {
+	requests := make(chan string, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests <- r.Method + " " + r.URL.Path
+	}))
+	var connections int
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections++
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	c := NewClient(server.URL, true, TransportConfig{})
+	resp := c.Connect(context.Background(), "")
+	require.NoError(t, resp.Err)
+	assert.Equal(t, ConnectionType, resp.Type)
+	assert.True(t, resp.Duration > 0)
+	assert.Equal(t, 0, resp.StatusCode)
+
+	resp = c.Connect(context.Background(), "/ping")
+	require.NoError(t, resp.Err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "HEAD /ping", <-requests)
+	assert.Equal(t, 2, connections)
+}
+
+func TestConnectTLSHandshakeError(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// the certificate of the test server is not trusted
+	c := NewClient(server.URL, false, TransportConfig{})
+	resp := c.Connect(context.Background(), "")
+	assert.Error(t, resp.Err)
+	assert.Equal(t, ConnectionType, resp.Type)
+}
@@ -0,0 +1,249 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// curlOptionsWithArgument are the curl options that are not supported but take an argument which needs to be skipped.
+var curlOptionsWithArgument = map[string]interface{}{
+	"-b": nil, "--cookie": nil, "-c": nil, "--cookie-jar": nil, "-o": nil, "--output": nil,
+	"-u": nil, "--user": nil, "-w": nil, "--write-out": nil, "-m": nil, "--max-time": nil,
+	"--connect-timeout": nil, "-x": nil, "--proxy": nil, "-F": nil, "--form": nil,
+	"--cacert": nil, "--cert": nil, "--key": nil, "--resolve": nil, "-T": nil, "--upload-file": nil,
+	"--retry": nil, "--limit-rate": nil, "-r": nil, "--range": nil,
+}
+
+// ToHTTPRequestFromCurl parses a curl command and converts it to a Request using the zero Parser.
+func ToHTTPRequestFromCurl(command string) (Request, *url.URL, error) {
+	return Parser{}.ToHTTPRequestFromCurl(command)
+}
+
+// ToHTTPRequestFromCurl parses a curl command and converts it to a Request.
+// It supports the method (-X), headers (-H, -A, -e), and body (-d, --data, --data-raw, --data-binary, --json) options.
+// Unsupported options are logged and ignored. The URL is returned so that its host can be validated against the target.
+func (p Parser) ToHTTPRequestFromCurl(command string) (Request, *url.URL, error) {
+	args, err := splitCommandLine(command)
+	if err != nil {
+		return Request{}, nil, fmt.Errorf("invalid curl command: %s, %v", command, err)
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return Request{}, nil, fmt.Errorf("invalid curl command: %s, expected it to start with curl", command)
+	}
+
+	var method, rawURL string
+	var data []string
+	var ignored []string
+	headers := make(map[string]string)
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		// value returns the argument of the current option
+		value := func() (string, error) {
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("missing value for option %s", arg)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch {
+		case arg == "-X" || arg == "--request":
+			if method, err = value(); err != nil {
+				return Request{}, nil, fmt.Errorf("invalid curl command: %s, %v", command, err)
+			}
+		case strings.HasPrefix(arg, "-X") && len(arg) > 2:
+			method = arg[2:]
+		case arg == "-H" || arg == "--header":
+			header, err := value()
+			if err != nil {
+				return Request{}, nil, fmt.Errorf("invalid curl command: %s, %v", command, err)
+			}
+			kv := strings.SplitN(header, ":", 2)
+			if len(kv) != 2 {
+				return Request{}, nil, fmt.Errorf("invalid curl command: %s, invalid header %s", command, header)
+			}
+			headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		case arg == "-A" || arg == "--user-agent":
+			if headers["User-Agent"], err = value(); err != nil {
+				return Request{}, nil, fmt.Errorf("invalid curl command: %s, %v", command, err)
+			}
+		case arg == "-e" || arg == "--referer":
+			if headers["Referer"], err = value(); err != nil {
+				return Request{}, nil, fmt.Errorf("invalid curl command: %s, %v", command, err)
+			}
+		case arg == "-d" || arg == "--data" || arg == "--data-raw" || arg == "--data-binary" || arg == "--data-ascii":
+			d, err := value()
+			if err != nil {
+				return Request{}, nil, fmt.Errorf("invalid curl command: %s, %v", command, err)
+			}
+			data = append(data, d)
+		case arg == "--json":
+			d, err := value()
+			if err != nil {
+				return Request{}, nil, fmt.Errorf("invalid curl command: %s, %v", command, err)
+			}
+			data = append(data, d)
+			headers["Content-Type"] = "application/json"
+			headers["Accept"] = "application/json"
+		case arg == "-I" || arg == "--head":
+			method = "HEAD"
+		case arg == "--url":
+			if rawURL, err = value(); err != nil {
+				return Request{}, nil, fmt.Errorf("invalid curl command: %s, %v", command, err)
+			}
+		case strings.HasPrefix(arg, "-"):
+			if _, ok := curlOptionsWithArgument[arg]; ok && i+1 < len(args) {
+				i++
+			}
+			ignored = append(ignored, arg)
+		default:
+			rawURL = arg
+		}
+	}
+
+	if len(ignored) > 0 {
+		log.Printf("curl command: ignoring unsupported options %v", ignored)
+	}
+
+	if rawURL == "" {
+		return Request{}, nil, fmt.Errorf("invalid curl command: %s, missing URL", command)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Request{}, nil, fmt.Errorf("invalid curl command: %s, %v", command, err)
+	}
+
+	var body *string
+	if len(data) > 0 {
+		joined := strings.Join(data, "&")
+		body = &joined
+		if method == "" {
+			method = "POST"
+		}
+		if _, ok := headers["Content-Type"]; !ok {
+			headers["Content-Type"] = "application/x-www-form-urlencoded"
+		}
+	}
+	if method == "" {
+		method = "GET"
+	}
+
+	request, err := p.newRequest(method, u.RequestURI(), body, headers)
+	if err != nil {
+		return Request{}, nil, fmt.Errorf("invalid curl command: %s, %v", command, err)
+	}
+	return request, u, nil
+}
+
+// ReadCurlCommands reads a file with one curl command per line. Commands can span multiple lines using a trailing \.
+// Empty lines and lines starting with # are ignored.
+func ReadCurlCommands(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("curl file %s: %v", file, err)
+	}
+	defer f.Close()
+
+	var commands []string
+	var current strings.Builder
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if current.Len() == 0 && (line == "" || strings.HasPrefix(line, "#")) {
+			continue
+		}
+		if strings.HasSuffix(line, "\\") {
+			current.WriteString(strings.TrimSuffix(line, "\\"))
+			current.WriteString(" ")
+			continue
+		}
+		current.WriteString(line)
+		commands = append(commands, current.String())
+		current.Reset()
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("curl file %s: %v", file, err)
+	}
+	if current.Len() > 0 {
+		commands = append(commands, current.String())
+	}
+	return commands, nil
+}
+
+// splitCommandLine splits a command line into arguments following the quoting rules of a POSIX shell.
+func splitCommandLine(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+				i++
+				current.WriteRune(runes[i])
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				if runes[i] != '\n' {
+					current.WriteRune(runes[i])
+					inArg = true
+				}
+			}
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote %c", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,86 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttp_CurlToHttpRequest(t *testing.T) {
+	command := `curl -s -X PUT 'http://localhost:8080/items/1?full=true' -H 'Content-Type: application/json' -H "X-Tenant: foo" --data-raw '{"name": "it'\''s"}'`
+	request, u, err := ToHTTPRequestFromCurl(command)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodPut, request.Method)
+	assert.Equal(t, "/items/1?full=true", request.Path)
+	assert.Equal(t, `{"name": "it's"}`, *request.Body)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json", "X-Tenant": "foo"}, request.Headers)
+	assert.Equal(t, "localhost:8080", u.Host)
+}
+
+func TestHttp_CurlWithDataDefaultsToPost(t *testing.T) {
+	request, _, err := ToHTTPRequestFromCurl(`curl http://localhost/login -d user=foo -d pass=bar`)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodPost, request.Method)
+	assert.Equal(t, "/login", request.Path)
+	assert.Equal(t, "user=foo&pass=bar", *request.Body)
+	assert.Equal(t, "application/x-www-form-urlencoded", request.Headers["Content-Type"])
+}
+
+func TestHttp_CurlWithoutDataDefaultsToGet(t *testing.T) {
+	request, _, err := ToHTTPRequestFromCurl(`curl -k -L --compressed -o /dev/null http://localhost/health`)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodGet, request.Method)
+	assert.Equal(t, "/health", request.Path)
+	assert.Nil(t, request.Body)
+}
+
+func TestHttp_InvalidCurlToHttpRequest(t *testing.T) {
+	_, _, err := ToHTTPRequestFromCurl(`wget http://localhost/health`)
+	require.Error(t, err)
+
+	_, _, err = ToHTTPRequestFromCurl(`curl -X GET`)
+	require.Error(t, err)
+
+	_, _, err = ToHTTPRequestFromCurl(`curl 'http://localhost/health`)
+	require.Error(t, err)
+}
+
+func TestHttp_ReadCurlCommands(t *testing.T) {
+	file := writeTempFile(t, `# health check
+curl http://localhost/health
+
+curl -X POST http://localhost/search \
+  -H 'Content-Type: application/json' \
+  -d '{"query": "foo"}'
+`)
+	defer os.Remove(file)
+
+	commands, err := ReadCurlCommands(file)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(commands))
+	assert.Equal(t, "curl http://localhost/health", commands[0])
+
+	request, _, err := ToHTTPRequestFromCurl(commands[1])
+	require.NoError(t, err)
+	assert.Equal(t, `{"query": "foo"}`, *request.Body)
+}
@@ -0,0 +1,249 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// DigestAuth holds the credentials of HTTP digest authentication (RFC 7616).
+// Requests with digest credentials that receive a 401 response with a digest challenge are sent again with an Authorization header that answers it.
+// The MD5 and SHA-256 algorithms, including their -sess variants, and the auth and auth-int qop values are supported.
+type DigestAuth struct {
+	Username string
+	Password string
+}
+
+// String returns the username, so that the password is redacted when logged.
+func (d *DigestAuth) String() string {
+	return fmt.Sprintf("Digest %s:[REDACTED]", d.Username)
+}
+
+// digestChallenge holds the parameters of a WWW-Authenticate: Digest header.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       []string
+}
+
+// digestHashes are the supported algorithms, in order of preference.
+var digestHashes = []struct {
+	algorithm string
+	hash      func() hash.Hash
+}{
+	{"SHA-256", sha256.New},
+	{"MD5", md5.New},
+}
+
+// authorize sends the request again answering the digest challenge of the 401 response, if it has one.
+// It returns the response as is if there is no supported challenge or if the body of the request cannot be sent again, e.g. if it is multipart.
+func (d *DigestAuth) authorize(client *http.Client, req *http.Request, resp *http.Response) (*http.Response, error) {
+	challenge, ok := chooseDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+	if !ok || (req.Body != nil && req.GetBody == nil) {
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	var body []byte
+	if req.GetBody != nil {
+		bodyReader, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		if body, err = ioutil.ReadAll(bodyReader); err != nil {
+			return resp, nil
+		}
+		retry.Body, _ = req.GetBody()
+	}
+	cnonce, err := newCnonce()
+	// the body of the challenge is discarded so that the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	retry.Header.Set("Authorization", d.authorization(challenge, req.Method, req.URL.RequestURI(), body, cnonce))
+	return client.Do(retry)
+}
+
+// authorization returns the value of the Authorization header that answers the challenge with the client nonce.
+func (d *DigestAuth) authorization(challenge digestChallenge, method, uri string, body []byte, cnonce string) string {
+	algorithm := strings.TrimSuffix(strings.ToUpper(challenge.algorithm), "-SESS")
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+	var newHash func() hash.Hash
+	for _, h := range digestHashes {
+		if h.algorithm == algorithm {
+			newHash = h.hash
+		}
+	}
+	digest := func(parts ...string) string {
+		h := newHash()
+		io.WriteString(h, strings.Join(parts, ":"))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	const nc = "00000001"
+	ha1 := digest(d.Username, challenge.realm, d.Password)
+	if strings.HasSuffix(strings.ToUpper(challenge.algorithm), "-SESS") {
+		ha1 = digest(ha1, challenge.nonce, cnonce)
+	}
+
+	qop := ""
+	for _, q := range challenge.qop {
+		if q == "auth" || (q == "auth-int" && qop == "") {
+			qop = q
+		}
+	}
+	ha2 := digest(method, uri)
+	if qop == "auth-int" {
+		ha2 = digest(method, uri, digest(string(body)))
+	}
+
+	var response string
+	if qop == "" {
+		// RFC 2069 compatibility
+		response = digest(ha1, challenge.nonce, ha2)
+	} else {
+		response = digest(ha1, challenge.nonce, nc, cnonce, qop, ha2)
+	}
+
+	fields := []string{
+		fmt.Sprintf("username=%s", quote(d.Username)),
+		fmt.Sprintf("realm=%s", quote(challenge.realm)),
+		fmt.Sprintf("nonce=%s", quote(challenge.nonce)),
+		fmt.Sprintf("uri=%s", quote(uri)),
+		fmt.Sprintf("response=%s", quote(response)),
+	}
+	if challenge.algorithm != "" {
+		fields = append(fields, "algorithm="+challenge.algorithm)
+	}
+	if challenge.opaque != "" {
+		fields = append(fields, fmt.Sprintf("opaque=%s", quote(challenge.opaque)))
+	}
+	if qop != "" {
+		fields = append(fields, "qop="+qop, "nc="+nc, fmt.Sprintf("cnonce=%s", quote(cnonce)))
+	}
+	return "Digest " + strings.Join(fields, ", ")
+}
+
+// chooseDigestChallenge returns the digest challenge with the preferred algorithm among the WWW-Authenticate headers.
+// It returns false if there is no digest challenge with a supported algorithm.
+func chooseDigestChallenge(headers []string) (digestChallenge, bool) {
+	var chosen digestChallenge
+	rank := len(digestHashes)
+	for _, header := range headers {
+		challenge, ok := parseDigestChallenge(header)
+		if !ok {
+			continue
+		}
+		algorithm := strings.TrimSuffix(strings.ToUpper(challenge.algorithm), "-SESS")
+		if algorithm == "" {
+			algorithm = "MD5"
+		}
+		for i, h := range digestHashes {
+			if h.algorithm == algorithm && i < rank {
+				chosen, rank = challenge, i
+			}
+		}
+	}
+	return chosen, rank < len(digestHashes)
+}
+
+// parseDigestChallenge parses a WWW-Authenticate header with a digest challenge e.g. Digest realm="api", nonce="abc", qop="auth".
+// It returns false if the header is not a digest challenge or does not have a nonce.
+func parseDigestChallenge(header string) (digestChallenge, bool) {
+	const scheme = "digest "
+	if len(header) < len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) {
+		return digestChallenge{}, false
+	}
+
+	var challenge digestChallenge
+	s := header[len(scheme):]
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		eq := strings.IndexByte(s, '=')
+		if eq == -1 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(s, `"`) {
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end == -1 {
+				end = len(s)
+			}
+			value.WriteString(strings.TrimSpace(s[:end]))
+			s = s[end:]
+		}
+
+		switch key {
+		case "realm":
+			challenge.realm = value.String()
+		case "nonce":
+			challenge.nonce = value.String()
+		case "opaque":
+			challenge.opaque = value.String()
+		case "algorithm":
+			challenge.algorithm = value.String()
+		case "qop":
+			for _, qop := range strings.Split(value.String(), ",") {
+				challenge.qop = append(challenge.qop, strings.ToLower(strings.TrimSpace(qop)))
+			}
+		}
+	}
+	return challenge, challenge.nonce != ""
+}
+
+// newCnonce returns a random client nonce.
+func newCnonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// quote returns the value as a quoted string of an Authorization header.
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
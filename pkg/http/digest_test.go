@@ -0,0 +1,113 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the example of RFC 7616 section 3.9.1
+const rfc7616Challenge = `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=%s, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`
+
+func TestDigestAuthorization(t *testing.T) {
+	d := &DigestAuth{Username: "Mufasa", Password: "Circle of Life"}
+	for algorithm, response := range map[string]string{
+		"MD5":     "8ca523f5e9506fed4657c9700eebdbec",
+		"SHA-256": "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+	} {
+		challenge, ok := parseDigestChallenge(fmt.Sprintf(rfc7616Challenge, algorithm))
+		require.True(t, ok)
+		authorization := d.authorization(challenge, "GET", "/dir/index.html", nil, "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ")
+		assert.Contains(t, authorization, `response="`+response+`"`)
+		assert.Contains(t, authorization, `username="Mufasa"`)
+		assert.Contains(t, authorization, "qop=auth,")
+		assert.Contains(t, authorization, `opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`)
+	}
+}
+
+func TestChooseDigestChallenge(t *testing.T) {
+	challenge, ok := chooseDigestChallenge([]string{
+		`Basic realm="api"`,
+		fmt.Sprintf(rfc7616Challenge, "MD5"),
+		fmt.Sprintf(rfc7616Challenge, "SHA-256"),
+	})
+	require.True(t, ok)
+	assert.Equal(t, "SHA-256", challenge.algorithm)
+	assert.Equal(t, []string{"auth", "auth-int"}, challenge.qop)
+
+	_, ok = chooseDigestChallenge([]string{`Basic realm="api"`, fmt.Sprintf(rfc7616Challenge, "SHA-512-256")})
+	assert.False(t, ok)
+}
+
+func TestRequestDigestAuth(t *testing.T) {
+	md5Hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		authorization := r.Header.Get("Authorization")
+		if authorization == "" {
+			w.Header().Set("WWW-Authenticate", `Digest realm="api", qop="auth", nonce="abc", opaque="xyz"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		params := map[string]string{}
+		for _, field := range []string{"nonce", "uri", "cnonce", "nc", "response"} {
+			params[field] = digestParam(authorization, field)
+		}
+		ha1 := md5Hex("user:api:secret")
+		ha2 := md5Hex(r.Method + ":" + params["uri"])
+		if params["nonce"] != "abc" || params["response"] != md5Hex(ha1+":abc:"+params["nc"]+":"+params["cnonce"]+":auth:"+ha2) {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, false, TransportConfig{})
+	body := `{"key": "value"}`
+	resp := c.SendRequest(context.Background(), Request{Method: "POST", Path: "/search?q=1", Body: &body, DigestAuth: &DigestAuth{Username: "user", Password: "secret"}})
+	require.NoError(t, resp.Err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, requests)
+
+	// wrong credentials are rejected by the server
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/", DigestAuth: &DigestAuth{Username: "user", Password: "wrong"}})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// requests without credentials get the challenge
+	resp = c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+// digestParam returns the value of a parameter of an Authorization: Digest header.
+func digestParam(authorization, name string) string {
+	match := regexp.MustCompile(`[ ,]` + name + `="?([^",]*)"?`).FindStringSubmatch(authorization)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
@@ -0,0 +1,245 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// requestDefinition represents an HTTP request as defined in a requests file.
+// The body can either be a string or a structured object in which case it is sent as JSON.
+// The weight defaults to 1 if not set. Expect is a comma-separated list of expected status codes e.g. 200,201 or 2xx.
+// Multipart defines the parts of a multipart/form-data body and cannot be combined with a body.
+// Compress can be set to gzip to send the body gzip-compressed.
+// Host overrides the host and port of the target, e.g. to send the request to a different port of the same pod.
+// RequiredResponseHeaders are the headers that a response must include, e.g. X-Cache: HIT, to count as successful.
+// BodyContains, BodyMatches and BodyJSONPath are the assertions that the response body must pass, e.g. bodyJSONPath: {$.status: UP}.
+// If Template is go, the body is executed as a Go text/template instead of replacing its placeholders.
+type requestDefinition struct {
+	Name                    string                    `yaml:"name"`
+	Method                  string                    `yaml:"method"`
+	Host                    string                    `yaml:"host"`
+	Path                    string                    `yaml:"path"`
+	Body                    interface{}               `yaml:"body"`
+	Multipart               []multipartPartDefinition `yaml:"multipart"`
+	ContentType             string                    `yaml:"contentType"`
+	Compress                string                    `yaml:"compress"`
+	Template                string                    `yaml:"template"`
+	Headers                 map[string]string         `yaml:"headers"`
+	Weight                  *int                      `yaml:"weight"`
+	Expect                  string                    `yaml:"expect"`
+	RequiredResponseHeaders map[string]string         `yaml:"requiredResponseHeaders"`
+	BodyContains            string                    `yaml:"bodyContains"`
+	BodyMatches             string                    `yaml:"bodyMatches"`
+	BodyJSONPath            map[string]string         `yaml:"bodyJSONPath"`
+}
+
+// multipartPartDefinition represents a part of a multipart body as defined in a requests file.
+// It is either a text field with a value or a file part with the path of the file to upload.
+type multipartPartDefinition struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+	File  string `yaml:"file"`
+}
+
+// ToHTTPRequestsFromFile parses a JSON or YAML file containing a list of HTTP requests using the zero Parser.
+func ToHTTPRequestsFromFile(file string) ([]Request, error) {
+	return Parser{}.ToHTTPRequestsFromFile(file)
+}
+
+// ToHTTPRequestsFromFile parses a JSON or YAML file containing a list of HTTP requests.
+// An empty file or list is not an error and returns no requests.
+func (p Parser) ToHTTPRequestsFromFile(file string) ([]Request, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("requests file %s: %v", file, err)
+	}
+	defer f.Close()
+
+	var definitions []requestDefinition
+	if err := yaml.NewDecoder(f).Decode(&definitions); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("requests file %s: %v", file, err)
+	}
+
+	var requests []Request
+	for i, definition := range definitions {
+		request, err := definition.toRequest(p)
+		if err != nil {
+			return nil, fmt.Errorf("requests file %s: request %d: %v", file, i, err)
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+// ToHTTPRequestsFromLines parses a file with one HTTP request per line using the zero Parser.
+func ToHTTPRequestsFromLines(file string) ([]Request, error) {
+	return Parser{}.ToHTTPRequestsFromLines(file)
+}
+
+// ToHTTPRequestsFromLines parses a file with one HTTP request per line in the same format as the http-requests flag.
+// Empty lines and lines starting with # are ignored.
+func (p Parser) ToHTTPRequestsFromLines(file string) ([]Request, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("request file %s: %v", file, err)
+	}
+	defer f.Close()
+
+	var requests []Request
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		request, err := p.ToHTTPRequest(line)
+		if err != nil {
+			return nil, fmt.Errorf("request file %s: line %d: %v", file, number, err)
+		}
+		requests = append(requests, request)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("request file %s: %v", file, err)
+	}
+	return requests, nil
+}
+
+// toRequest validates the definition and converts it to a Request.
+func (d requestDefinition) toRequest(p Parser) (Request, error) {
+	if d.Path == "" {
+		return Request{}, fmt.Errorf("path is required")
+	}
+	if d.Weight != nil && *d.Weight <= 0 {
+		return Request{}, fmt.Errorf("invalid weight %d, expected it to be greater than zero", *d.Weight)
+	}
+	if d.Body != nil && len(d.Multipart) > 0 {
+		return Request{}, fmt.Errorf("body and multipart cannot be used together")
+	}
+	if d.Compress != "" && d.Compress != CompressGzip {
+		return Request{}, fmt.Errorf("invalid compress %s, only %s is supported", d.Compress, CompressGzip)
+	}
+	if d.Compress != "" && d.Body == nil {
+		return Request{}, fmt.Errorf("compress requires a body")
+	}
+	if d.Host != "" {
+		if err := validateHost(d.Host); err != nil {
+			return Request{}, err
+		}
+	}
+	if d.Template != "" && d.Template != templateGo {
+		return Request{}, fmt.Errorf("invalid template %s, only %s is supported", d.Template, templateGo)
+	}
+
+	var body *string
+	switch b := d.Body.(type) {
+	case nil:
+	case string:
+		body = &b
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			return Request{}, fmt.Errorf("invalid body: %v", err)
+		}
+		s := string(encoded)
+		body = &s
+	}
+
+	if d.Template == templateGo {
+		return d.toTemplateRequest(p)
+	}
+
+	request, err := p.newRequest(d.Method, d.Path, body, d.Headers)
+	if err != nil {
+		return Request{}, err
+	}
+	return d.withOptions(request)
+}
+
+// toTemplateRequest creates a Request whose body is the result of executing the body as a Go template.
+// The body must be a string, optionally read from a file if it starts with @.
+func (d requestDefinition) toTemplateRequest(p Parser) (Request, error) {
+	body, ok := d.Body.(string)
+	if !ok {
+		return Request{}, fmt.Errorf("template requires a string body")
+	}
+	content, err := loadBody(body)
+	if err != nil {
+		return Request{}, err
+	}
+	rendered, err := executeTemplate(d.Method+" "+d.Path, content, p.TemplateValues)
+	if err != nil {
+		return Request{}, err
+	}
+
+	request, err := p.newRequest(d.Method, d.Path, nil, d.Headers)
+	if err != nil {
+		return Request{}, err
+	}
+	request.Body = &rendered
+	return d.withOptions(request)
+}
+
+// withOptions sets the optional fields of the definition on the request.
+func (d requestDefinition) withOptions(request Request) (Request, error) {
+	request.Name = d.Name
+	request.Host = strings.TrimRight(d.Host, "/")
+	request.ContentType = d.ContentType
+	request.Compress = d.Compress
+	request.RequiredResponseHeaders = d.RequiredResponseHeaders
+	bodyAssertions, err := NewBodyAssertions(d.BodyContains, d.BodyMatches, d.BodyJSONPath)
+	if err != nil {
+		return Request{}, err
+	}
+	request.BodyAssertions = bodyAssertions
+	for _, part := range d.Multipart {
+		if part.Value != "" && part.File != "" {
+			return Request{}, fmt.Errorf("multipart part %s cannot have both a value and a file", part.Name)
+		}
+		multipartPart, err := newMultipartPart(part.Name, part.Value, part.File)
+		if err != nil {
+			return Request{}, err
+		}
+		request.Multipart = append(request.Multipart, multipartPart)
+	}
+	if d.Expect != "" {
+		expected, err := ParseStatusCodes(d.Expect)
+		if err != nil {
+			return Request{}, err
+		}
+		request.ExpectedStatusCodes = expected
+	}
+	if d.Weight != nil {
+		request.Weight = *d.Weight
+	}
+	return request, nil
+}
+
+// toHTTPRequestFromObject parses a single request defined as a JSON or YAML object.
+func (p Parser) toHTTPRequestFromObject(object string) (Request, error) {
+	var definition requestDefinition
+	if err := yaml.Unmarshal([]byte(object), &definition); err != nil {
+		return Request{}, err
+	}
+	return definition.toRequest(p)
+}
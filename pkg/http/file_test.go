@@ -0,0 +1,253 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttp_YamlFileToHttpRequests(t *testing.T) {
+	file := writeTempFile(t, `
+- method: get
+  path: /health
+- method: post
+  path: /ping_{$range|min=1,max=1}
+  headers:
+    Content-Type: application/json
+  body:
+    key: value
+- method: put
+  path: /raw
+  body: '{"key": "value"}'
+`)
+	defer os.Remove(file)
+
+	requests, err := ToHTTPRequestsFromFile(file)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(requests))
+
+	assert.Equal(t, http.MethodGet, requests[0].Method)
+	assert.Equal(t, "/health", requests[0].Path)
+	assert.Nil(t, requests[0].Body)
+
+	assert.Equal(t, http.MethodPost, requests[1].Method)
+	assert.Equal(t, "/ping_1", requests[1].Path)
+	assert.Equal(t, `{"key":"value"}`, *requests[1].Body)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json"}, requests[1].Headers)
+
+	assert.Equal(t, `{"key": "value"}`, *requests[2].Body)
+}
+
+func TestHttp_JsonFileToHttpRequests(t *testing.T) {
+	file := writeTempFile(t, `[{"method": "get", "path": "/health"}]`)
+	defer os.Remove(file)
+
+	requests, err := ToHTTPRequestsFromFile(file)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(requests))
+	assert.Equal(t, "/health", requests[0].Path)
+}
+
+func TestHttp_EmptyFileToHttpRequests(t *testing.T) {
+	for _, content := range []string{"", "[]"} {
+		file := writeTempFile(t, content)
+		defer os.Remove(file)
+
+		requests, err := ToHTTPRequestsFromFile(file)
+		require.NoError(t, err)
+		assert.Empty(t, requests)
+	}
+}
+
+func TestHttp_InvalidFileToHttpRequests(t *testing.T) {
+	file := writeTempFile(t, `
+- method: get
+  path: /health
+- method: hmm
+  path: /ping
+`)
+	defer os.Remove(file)
+
+	_, err := ToHTTPRequestsFromFile(file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), file)
+	assert.Contains(t, err.Error(), "request 1")
+}
+
+func TestHttp_LinesFileToHttpRequests(t *testing.T) {
+	file := writeTempFile(t, `# health checks
+get:/health
+
+post:/search:{"query": "foo"}
+`)
+	defer os.Remove(file)
+
+	requests, err := ToHTTPRequestsFromLines(file)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(requests))
+	assert.Equal(t, "/health", requests[0].Path)
+	assert.Equal(t, "POST", requests[1].Method)
+	assert.Equal(t, `{"query": "foo"}`, *requests[1].Body)
+}
+
+func TestHttp_InvalidLinesFileToHttpRequests(t *testing.T) {
+	file := writeTempFile(t, "get:/health\nhmm:/ping\n")
+	defer os.Remove(file)
+
+	_, err := ToHTTPRequestsFromLines(file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), file)
+	assert.Contains(t, err.Error(), "line 2")
+}
+
+func TestHttp_FileWithWeights(t *testing.T) {
+	file := writeTempFile(t, `
+- method: get
+  path: /search
+  weight: 80
+- method: get
+  path: /admin/report
+`)
+	defer os.Remove(file)
+
+	requests, err := ToHTTPRequestsFromFile(file)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(requests))
+	assert.Equal(t, 80, requests[0].Weight)
+	assert.Equal(t, 1, requests[1].Weight)
+}
+
+func TestHttp_InvalidWeight(t *testing.T) {
+	for _, weight := range []string{"0", "-1"} {
+		_, err := ToHTTPRequest(`{method: get, path: /search, weight: ` + weight + `}`)
+		assert.Error(t, err, weight)
+	}
+}
+
+func TestHttp_NamedRequests(t *testing.T) {
+	request, err := ToHTTPRequest(`{name: search, method: get, path: /search}`)
+	require.NoError(t, err)
+	assert.Equal(t, "search", request.GetName())
+
+	request, err = ToHTTPRequest(`get:/health`)
+	require.NoError(t, err)
+	assert.Equal(t, "GET /health", request.GetName())
+}
+
+func TestHttp_MultipartRequests(t *testing.T) {
+	upload := writeTempFile(t, "file content")
+	defer os.Remove(upload)
+
+	file := writeTempFile(t, `
+- method: post
+  path: /upload
+  multipart:
+    - name: description
+      value: upload_{$range|min=1,max=1}
+    - name: file
+      file: `+upload+`
+`)
+	defer os.Remove(file)
+
+	requests, err := ToHTTPRequestsFromFile(file)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(requests))
+	assert.Nil(t, requests[0].Body)
+	assert.Equal(t, []MultipartPart{{Name: "description", Value: "upload_1"}, {Name: "file", File: upload}}, requests[0].Multipart)
+}
+
+func TestHttp_InvalidMultipartRequests(t *testing.T) {
+	for _, request := range []string{
+		`{method: post, path: /upload, multipart: [{name: file, file: /does/not/exist}]}`,
+		`{method: post, path: /upload, multipart: [{value: nameless}]}`,
+		`{method: post, path: /upload, body: test, multipart: [{name: description, value: test}]}`,
+	} {
+		_, err := ToHTTPRequest(request)
+		assert.Error(t, err, request)
+	}
+}
+
+func TestHttp_CompressedRequests(t *testing.T) {
+	request, err := ToHTTPRequest(`{method: post, path: /ingest, body: {key: value}, compress: gzip}`)
+	require.NoError(t, err)
+	assert.Equal(t, CompressGzip, request.Compress)
+
+	for _, invalid := range []string{
+		`{method: post, path: /ingest, body: test, compress: br}`,
+		`{method: post, path: /ingest, compress: gzip}`,
+	} {
+		_, err := ToHTTPRequest(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestHttp_RequestsWithRequiredResponseHeaders(t *testing.T) {
+	request, err := ToHTTPRequest(`{method: get, path: /search, requiredResponseHeaders: {X-Cache: HIT, X-Warmed: ""}}`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"X-Cache": "HIT", "X-Warmed": ""}, request.RequiredResponseHeaders)
+}
+
+func TestHttp_RequestsWithBodyAssertions(t *testing.T) {
+	request, err := ToHTTPRequest(`{method: get, path: /health, bodyContains: UP, bodyMatches: "\"status\": ?\"UP\"", bodyJSONPath: {$.status: UP, "$.checks[0].count": 3}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "UP", request.BodyAssertions.Contains)
+	assert.Equal(t, `"status": ?"UP"`, request.BodyAssertions.Matches.String())
+	assert.Equal(t, map[string]string{"$.status": "UP", "$.checks[0].count": "3"}, request.BodyAssertions.JSONPath)
+
+	for _, invalid := range []string{
+		`{method: get, path: /health, bodyMatches: "("}`,
+		`{method: get, path: /health, bodyJSONPath: {status: UP}}`,
+		`{method: get, path: /health, bodyJSONPath: {"$.checks[first]": UP}}`,
+	} {
+		_, err := ToHTTPRequest(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestHttp_RequestsWithHost(t *testing.T) {
+	request, err := ToHTTPRequest(`{method: get, path: /admin/cache, host: "localhost:8081"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:8081", request.Host)
+	assert.Equal(t, "GET localhost:8081/admin/cache", request.GetName())
+
+	request, err = ToHTTPRequest(`{method: get, path: /home, headers: {host: www.brand-a.com}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "GET www.brand-a.com/home", request.GetName())
+
+	request, err = ToHTTPRequest(`{method: get, path: /admin/cache, host: "https://localhost:8443/"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "https://localhost:8443", request.Host)
+
+	for _, host := range []string{"localhost:8081/admin", "http://", ":bad:host"} {
+		_, err := ToHTTPRequest(`{method: get, path: /admin, host: "` + host + `"}`)
+		assert.Error(t, err, host)
+	}
+}
+
+func writeTempFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "mittens")
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	return f.Name()
+}
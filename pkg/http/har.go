@@ -0,0 +1,165 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// headers that are set by the client when sending the request and must not be replayed.
+var harIgnoredHeaders = map[string]interface{}{
+	"host":              nil,
+	"content-length":    nil,
+	"connection":        nil,
+	"transfer-encoding": nil,
+}
+
+type har struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	Request struct {
+		Method   string         `json:"method"`
+		URL      string         `json:"url"`
+		Headers  []harNameValue `json:"headers"`
+		Cookies  []harNameValue `json:"cookies"`
+		PostData *struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"postData"`
+	} `json:"request"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ToHTTPRequestsFromHAR reads a HAR 1.2 file and converts the entries sent to the target to requests using the zero Parser.
+func ToHTTPRequestsFromHAR(file string, target *url.URL, includeCookies bool) ([]Request, error) {
+	return Parser{}.ToHTTPRequestsFromHAR(file, target, includeCookies)
+}
+
+// ToHTTPRequestsFromHAR reads a HAR 1.2 file and converts the entries sent to the target to requests.
+// Entries sent to other hosts and entries with binary bodies are skipped and their count is logged.
+// Cookies are only included if includeCookies is true. Recorded requests are replayed as is, placeholders are not interpolated.
+func (p Parser) ToHTTPRequestsFromHAR(file string, target *url.URL, includeCookies bool) ([]Request, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("HAR file %s: %v", file, err)
+	}
+
+	var archive har
+	if err := json.Unmarshal(content, &archive); err != nil {
+		return nil, fmt.Errorf("HAR file %s: %v", file, err)
+	}
+
+	var requests []Request
+	var otherHosts, binaryBodies int
+	for i, entry := range archive.Log.Entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("HAR file %s: entry %d: %v", file, i, err)
+		}
+		if !SameHost(u, target) {
+			otherHosts++
+			continue
+		}
+
+		method := strings.ToUpper(entry.Request.Method)
+		if err := p.validateMethod(method); err != nil {
+			return nil, fmt.Errorf("HAR file %s: entry %d: %v", file, i, err)
+		}
+
+		request := Request{Method: method, Path: u.RequestURI(), Weight: 1}
+		if postData := entry.Request.PostData; postData != nil && postData.Text != "" {
+			if postData.Encoding != "" || !utf8.ValidString(postData.Text) {
+				binaryBodies++
+				continue
+			}
+			body := postData.Text
+			request.Body = &body
+		}
+
+		headers := make(map[string]string)
+		for _, header := range entry.Request.Headers {
+			name := strings.ToLower(header.Name)
+			// HTTP/2 pseudo headers e.g. :authority
+			if strings.HasPrefix(name, ":") {
+				continue
+			}
+			if _, ok := harIgnoredHeaders[name]; ok {
+				continue
+			}
+			if name == "cookie" && !includeCookies {
+				continue
+			}
+			headers[header.Name] = header.Value
+		}
+		if includeCookies && len(entry.Request.Cookies) > 0 && !hasHeader(headers, "Cookie") {
+			var cookies []string
+			for _, cookie := range entry.Request.Cookies {
+				cookies = append(cookies, cookie.Name+"="+cookie.Value)
+			}
+			headers["Cookie"] = strings.Join(cookies, "; ")
+		}
+		if len(headers) > 0 {
+			request.Headers = headers
+		}
+		requests = append(requests, request)
+	}
+
+	if otherHosts > 0 {
+		log.Printf("HAR file %s: skipped %d entries for hosts other than %s", file, otherHosts, target.Host)
+	}
+	if binaryBodies > 0 {
+		log.Printf("HAR file %s: skipped %d entries with binary bodies", file, binaryBodies)
+	}
+	return requests, nil
+}
+
+// SameHost returns true if both URLs point to the same host and port, taking into account the default port of each scheme.
+func SameHost(a, b *url.URL) bool {
+	return a.Hostname() == b.Hostname() && portOrDefault(a) == portOrDefault(b)
+}
+
+func portOrDefault(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if u.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
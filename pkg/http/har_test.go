@@ -0,0 +1,112 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const harFile = `{
+  "log": {
+    "version": "1.2",
+    "entries": [
+      {
+        "request": {
+          "method": "GET",
+          "url": "http://localhost:8080/products?page=2",
+          "headers": [
+            {"name": ":authority", "value": "localhost:8080"},
+            {"name": "Host", "value": "localhost:8080"},
+            {"name": "Accept", "value": "application/json"},
+            {"name": "Cookie", "value": "session=abc"}
+          ],
+          "cookies": [{"name": "session", "value": "abc"}]
+        }
+      },
+      {
+        "request": {
+          "method": "POST",
+          "url": "http://localhost:8080/cart",
+          "headers": [{"name": "Content-Type", "value": "application/json"}],
+          "cookies": [{"name": "session", "value": "abc"}],
+          "postData": {"mimeType": "application/json", "text": "{\"id\":1}"}
+        }
+      },
+      {
+        "request": {
+          "method": "POST",
+          "url": "http://localhost:8080/upload",
+          "headers": [],
+          "postData": {"mimeType": "image/png", "text": "iVBORw0KGgo=", "encoding": "base64"}
+        }
+      },
+      {
+        "request": {
+          "method": "GET",
+          "url": "https://cdn.example.com/app.js",
+          "headers": []
+        }
+      }
+    ]
+  }
+}`
+
+func TestHttp_HarToHttpRequests(t *testing.T) {
+	file := writeTempFile(t, harFile)
+	defer os.Remove(file)
+
+	target, _ := url.Parse("http://localhost:8080")
+	requests, err := ToHTTPRequestsFromHAR(file, target, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(requests))
+
+	assert.Equal(t, http.MethodGet, requests[0].Method)
+	assert.Equal(t, "/products?page=2", requests[0].Path)
+	assert.Nil(t, requests[0].Body)
+	assert.Equal(t, map[string]string{"Accept": "application/json"}, requests[0].Headers)
+
+	assert.Equal(t, http.MethodPost, requests[1].Method)
+	assert.Equal(t, "/cart", requests[1].Path)
+	assert.Equal(t, `{"id":1}`, *requests[1].Body)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json"}, requests[1].Headers)
+}
+
+func TestHttp_HarToHttpRequestsWithCookies(t *testing.T) {
+	file := writeTempFile(t, harFile)
+	defer os.Remove(file)
+
+	target, _ := url.Parse("http://localhost:8080")
+	requests, err := ToHTTPRequestsFromHAR(file, target, true)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(requests))
+
+	assert.Equal(t, "session=abc", requests[0].Headers["Cookie"])
+	assert.Equal(t, "session=abc", requests[1].Headers["Cookie"])
+}
+
+func TestHttp_InvalidHarFile(t *testing.T) {
+	file := writeTempFile(t, `not json`)
+	defer os.Remove(file)
+
+	target, _ := url.Parse("http://localhost:8080")
+	_, err := ToHTTPRequestsFromHAR(file, target, false)
+	assert.Error(t, err)
+}
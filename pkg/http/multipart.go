@@ -0,0 +1,99 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"mittens/pkg/placeholders"
+	"os"
+	"path/filepath"
+)
+
+// MultipartPart is a part of a multipart/form-data body.
+// It is either a text field with a value or, if File is set, a file which is streamed from disk when the request is sent.
+type MultipartPart struct {
+	Name  string
+	Value string
+	File  string
+}
+
+// newMultipartPart validates a part and interpolates the placeholders in the value of text fields.
+// Files must exist so that missing files are reported when parsing the requests rather than when sending them.
+func newMultipartPart(name, value, file string) (MultipartPart, error) {
+	if name == "" {
+		return MultipartPart{}, fmt.Errorf("multipart part name is required")
+	}
+	if file != "" {
+		info, err := os.Stat(file)
+		if err != nil {
+			return MultipartPart{}, fmt.Errorf("multipart part %s: %v", name, err)
+		}
+		if info.IsDir() {
+			return MultipartPart{}, fmt.Errorf("multipart part %s: %s is a directory", name, file)
+		}
+		return MultipartPart{Name: name, File: file}, nil
+	}
+
+	interpolatedValue, err := placeholders.Interpolate(value)
+	if err != nil {
+		return MultipartPart{}, err
+	}
+	return MultipartPart{Name: name, Value: interpolatedValue}, nil
+}
+
+// newMultipartBody returns a reader that streams the multipart body, so that files are not read fully into memory, and its content type including the boundary.
+// The reader must be closed once the request is sent.
+func newMultipartBody(parts []MultipartPart) (io.ReadCloser, string) {
+	reader, writer := io.Pipe()
+	multipartWriter := multipart.NewWriter(writer)
+
+	go func() {
+		writer.CloseWithError(writeMultipartParts(multipartWriter, parts))
+	}()
+	return reader, multipartWriter.FormDataContentType()
+}
+
+func writeMultipartParts(multipartWriter *multipart.Writer, parts []MultipartPart) error {
+	for _, part := range parts {
+		if part.File == "" {
+			if err := multipartWriter.WriteField(part.Name, part.Value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := writeMultipartFile(multipartWriter, part); err != nil {
+			return err
+		}
+	}
+	return multipartWriter.Close()
+}
+
+func writeMultipartFile(multipartWriter *multipart.Writer, part MultipartPart) error {
+	f, err := os.Open(part.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := multipartWriter.CreateFormFile(part.Name, filepath.Base(part.File))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
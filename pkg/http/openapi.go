@@ -0,0 +1,325 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPIFilter selects the operations of an OpenAPI spec that are converted to requests.
+// Methods defaults to GET. If Tags is set, only operations with at least one of the tags are included.
+type OpenAPIFilter struct {
+	Methods   []string
+	Tags      []string
+	PathRegex *regexp.Regexp
+}
+
+type openAPISpec struct {
+	Swagger    string                     `yaml:"swagger"`
+	Servers    []openAPIServer            `yaml:"servers"`
+	Paths      map[string]openAPIPathItem `yaml:"paths"`
+	Components struct {
+		Parameters map[string]openAPIParameter `yaml:"parameters"`
+		Schemas    map[string]openAPISchema    `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+type openAPIServer struct {
+	URL       string `yaml:"url"`
+	Variables map[string]struct {
+		Default string `yaml:"default"`
+	} `yaml:"variables"`
+}
+
+type openAPIPathItem struct {
+	Parameters []openAPIParameter `yaml:"parameters"`
+	Get        *openAPIOperation  `yaml:"get"`
+	Put        *openAPIOperation  `yaml:"put"`
+	Post       *openAPIOperation  `yaml:"post"`
+	Delete     *openAPIOperation  `yaml:"delete"`
+	Options    *openAPIOperation  `yaml:"options"`
+	Head       *openAPIOperation  `yaml:"head"`
+	Patch      *openAPIOperation  `yaml:"patch"`
+	Trace      *openAPIOperation  `yaml:"trace"`
+}
+
+type openAPIOperation struct {
+	Tags        []string            `yaml:"tags"`
+	Parameters  []openAPIParameter  `yaml:"parameters"`
+	RequestBody *openAPIRequestBody `yaml:"requestBody"`
+}
+
+type openAPIParameter struct {
+	Ref      string         `yaml:"$ref"`
+	Name     string         `yaml:"name"`
+	In       string         `yaml:"in"`
+	Required bool           `yaml:"required"`
+	Example  interface{}    `yaml:"example"`
+	Schema   *openAPISchema `yaml:"schema"`
+}
+
+type openAPISchema struct {
+	Ref     string        `yaml:"$ref"`
+	Type    string        `yaml:"type"`
+	Format  string        `yaml:"format"`
+	Example interface{}   `yaml:"example"`
+	Default interface{}   `yaml:"default"`
+	Enum    []interface{} `yaml:"enum"`
+}
+
+type openAPIRequestBody struct {
+	Required bool `yaml:"required"`
+	Content  map[string]struct {
+		Example interface{} `yaml:"example"`
+	} `yaml:"content"`
+}
+
+// ToHTTPRequestsFromOpenAPIFile reads an OpenAPI 3 spec in JSON or YAML format and converts its operations to requests.
+func (p Parser) ToHTTPRequestsFromOpenAPIFile(file string, filter OpenAPIFilter) ([]Request, error) {
+	spec, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("OpenAPI spec %s: %v", file, err)
+	}
+	requests, err := p.ToHTTPRequestsFromOpenAPI(spec, filter)
+	if err != nil {
+		return nil, fmt.Errorf("OpenAPI spec %s: %v", file, err)
+	}
+	return requests, nil
+}
+
+// ToHTTPRequestsFromOpenAPI converts the operations of an OpenAPI 3 spec to requests using the zero Parser.
+func ToHTTPRequestsFromOpenAPI(content []byte, filter OpenAPIFilter) ([]Request, error) {
+	return Parser{}.ToHTTPRequestsFromOpenAPI(content, filter)
+}
+
+// ToHTTPRequestsFromOpenAPI converts the operations of an OpenAPI 3 spec in JSON or YAML format to requests.
+// Path, query and header parameters are filled using their examples, defaults or enums, falling back to sensible values for numbers and booleans.
+// Operations with parameters or bodies that cannot be filled are skipped and the reason is logged.
+func (p Parser) ToHTTPRequestsFromOpenAPI(content []byte, filter OpenAPIFilter) ([]Request, error) {
+	var spec openAPISpec
+	if err := yaml.Unmarshal(content, &spec); err != nil {
+		return nil, err
+	}
+	if spec.Swagger != "" {
+		return nil, fmt.Errorf("swagger %s is not supported, only OpenAPI 3 specs are", spec.Swagger)
+	}
+
+	methods := filter.Methods
+	if len(methods) == 0 {
+		methods = []string{"GET"}
+	}
+
+	basePath := spec.basePath()
+	var paths []string
+	for path := range spec.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var requests []Request
+	for _, path := range paths {
+		if filter.PathRegex != nil && !filter.PathRegex.MatchString(path) {
+			continue
+		}
+		item := spec.Paths[path]
+		for _, method := range methods {
+			method = strings.ToUpper(method)
+			operation := item.operation(method)
+			if operation == nil || !operation.hasAnyTag(filter.Tags) {
+				continue
+			}
+
+			request, err := spec.toRequest(p, method, basePath+path, append(append([]openAPIParameter{}, item.Parameters...), operation.Parameters...), operation.RequestBody)
+			if err != nil {
+				log.Printf("OpenAPI: skipping %s %s: %v", method, path, err)
+				continue
+			}
+			requests = append(requests, request)
+		}
+	}
+	return requests, nil
+}
+
+// basePath returns the path of the first server, if any, so that it can be prepended to the paths of the operations.
+func (s openAPISpec) basePath() string {
+	if len(s.Servers) == 0 {
+		return ""
+	}
+	server := s.Servers[0]
+	serverURL := server.URL
+	for name, variable := range server.Variables {
+		serverURL = strings.Replace(serverURL, "{"+name+"}", variable.Default, -1)
+	}
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(u.Path, "/")
+}
+
+// toRequest creates a request for an operation filling its parameters and body.
+func (s openAPISpec) toRequest(parser Parser, method, path string, parameters []openAPIParameter, requestBody *openAPIRequestBody) (Request, error) {
+	query := url.Values{}
+	headers := make(map[string]string)
+
+	for _, p := range parameters {
+		parameter, err := s.resolveParameter(p)
+		if err != nil {
+			return Request{}, err
+		}
+		if !parameter.Required && parameter.In != "path" {
+			continue
+		}
+
+		value, ok := s.parameterValue(parameter)
+		if !ok {
+			return Request{}, fmt.Errorf("cannot fill required %s parameter %s, no example or default found", parameter.In, parameter.Name)
+		}
+		switch parameter.In {
+		case "path":
+			path = strings.Replace(path, "{"+parameter.Name+"}", url.PathEscape(value), -1)
+		case "query":
+			query.Set(parameter.Name, value)
+		case "header":
+			headers[parameter.Name] = value
+		default:
+			return Request{}, fmt.Errorf("required %s parameter %s is not supported", parameter.In, parameter.Name)
+		}
+	}
+	if strings.Contains(path, "{") {
+		return Request{}, fmt.Errorf("path parameters are not defined in %s", path)
+	}
+	if len(query) > 0 {
+		path = path + "?" + query.Encode()
+	}
+
+	var body *string
+	if requestBody != nil {
+		content, ok := requestBody.Content["application/json"]
+		if ok && content.Example != nil {
+			encoded, err := json.Marshal(content.Example)
+			if err != nil {
+				return Request{}, fmt.Errorf("invalid request body example: %v", err)
+			}
+			b := string(encoded)
+			body = &b
+			headers["Content-Type"] = "application/json"
+		} else if requestBody.Required {
+			return Request{}, fmt.Errorf("cannot fill required request body, no application/json example found")
+		}
+	}
+
+	return parser.newRequest(method, path, body, headers)
+}
+
+// resolveParameter returns the parameter referenced by $ref, if any.
+func (s openAPISpec) resolveParameter(parameter openAPIParameter) (openAPIParameter, error) {
+	if parameter.Ref == "" {
+		return parameter, nil
+	}
+	name := strings.TrimPrefix(parameter.Ref, "#/components/parameters/")
+	resolved, ok := s.Components.Parameters[name]
+	if !ok || name == parameter.Ref {
+		return openAPIParameter{}, fmt.Errorf("cannot resolve parameter %s", parameter.Ref)
+	}
+	return resolved, nil
+}
+
+// parameterValue returns a value for the parameter based on its example or schema.
+func (s openAPISpec) parameterValue(parameter openAPIParameter) (string, bool) {
+	if parameter.Example != nil {
+		return fmt.Sprint(parameter.Example), true
+	}
+	if parameter.Schema == nil {
+		return "", false
+	}
+
+	schema := *parameter.Schema
+	if schema.Ref != "" {
+		resolved, ok := s.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			return "", false
+		}
+		schema = resolved
+	}
+
+	switch {
+	case schema.Example != nil:
+		return fmt.Sprint(schema.Example), true
+	case schema.Default != nil:
+		return fmt.Sprint(schema.Default), true
+	case len(schema.Enum) > 0:
+		return fmt.Sprint(schema.Enum[0]), true
+	case schema.Type == "integer" || schema.Type == "number":
+		return "1", true
+	case schema.Type == "boolean":
+		return "true", true
+	case schema.Type == "string" && schema.Format == "date":
+		return time.Now().Format("2006-01-02"), true
+	case schema.Type == "string" && schema.Format == "date-time":
+		return time.Now().Format(time.RFC3339), true
+	case schema.Type == "string" && schema.Format == "uuid":
+		return "00000000-0000-0000-0000-000000000000", true
+	}
+	return "", false
+}
+
+// operation returns the operation of the path item for the given method, or nil if there is none.
+func (p openAPIPathItem) operation(method string) *openAPIOperation {
+	switch method {
+	case "GET":
+		return p.Get
+	case "PUT":
+		return p.Put
+	case "POST":
+		return p.Post
+	case "DELETE":
+		return p.Delete
+	case "OPTIONS":
+		return p.Options
+	case "HEAD":
+		return p.Head
+	case "PATCH":
+		return p.Patch
+	case "TRACE":
+		return p.Trace
+	}
+	return nil
+}
+
+// hasAnyTag returns true if no tags are given or the operation has at least one of them.
+func (o openAPIOperation) hasAnyTag(tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		for _, t := range o.Tags {
+			if t == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const openAPISpecYaml = `
+openapi: 3.0.1
+servers:
+  - url: http://localhost:8080/api
+paths:
+  /users/{id}:
+    parameters:
+      - $ref: '#/components/parameters/UserId'
+    get:
+      tags: [users]
+      parameters:
+        - name: verbose
+          in: query
+          schema:
+            type: boolean
+    delete:
+      tags: [users]
+  /orders:
+    get:
+      tags: [orders]
+      parameters:
+        - name: status
+          in: query
+          required: true
+          schema:
+            type: string
+            enum: [open, closed]
+        - name: X-Tenant
+          in: header
+          required: true
+          example: acme
+    post:
+      tags: [orders]
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+  /search:
+    get:
+      tags: [search]
+      parameters:
+        - name: q
+          in: query
+          required: true
+          schema:
+            type: string
+components:
+  parameters:
+    UserId:
+      name: id
+      in: path
+      required: true
+      schema:
+        type: integer
+`
+
+func TestHttp_OpenAPIToHttpRequests(t *testing.T) {
+	requests, err := ToHTTPRequestsFromOpenAPI([]byte(openAPISpecYaml), OpenAPIFilter{})
+	require.NoError(t, err)
+	// /search is skipped because q cannot be filled
+	require.Equal(t, 2, len(requests))
+
+	assert.Equal(t, http.MethodGet, requests[0].Method)
+	assert.Equal(t, "/api/orders?status=open", requests[0].Path)
+	assert.Equal(t, map[string]string{"X-Tenant": "acme"}, requests[0].Headers)
+
+	assert.Equal(t, http.MethodGet, requests[1].Method)
+	assert.Equal(t, "/api/users/1", requests[1].Path)
+}
+
+func TestHttp_OpenAPIFilter(t *testing.T) {
+	requests, err := ToHTTPRequestsFromOpenAPI([]byte(openAPISpecYaml), OpenAPIFilter{
+		Methods:   []string{"get", "delete", "post"},
+		Tags:      []string{"users", "orders"},
+		PathRegex: regexp.MustCompile("^/users"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(requests))
+
+	assert.Equal(t, http.MethodGet, requests[0].Method)
+	assert.Equal(t, http.MethodDelete, requests[1].Method)
+	assert.Equal(t, "/api/users/1", requests[1].Path)
+}
+
+func TestHttp_OpenAPIRequestBodyExample(t *testing.T) {
+	spec := `{
+  "openapi": "3.0.1",
+  "paths": {
+    "/orders": {
+      "post": {
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"example": {"item": "book"}}}
+        }
+      }
+    }
+  }
+}`
+	requests, err := ToHTTPRequestsFromOpenAPI([]byte(spec), OpenAPIFilter{Methods: []string{"post"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(requests))
+
+	assert.Equal(t, "/orders", requests[0].Path)
+	assert.Equal(t, `{"item":"book"}`, *requests[0].Body)
+	assert.Equal(t, "application/json", requests[0].Headers["Content-Type"])
+}
+
+func TestHttp_SwaggerSpecNotSupported(t *testing.T) {
+	_, err := ToHTTPRequestsFromOpenAPI([]byte(`swagger: "2.0"`), OpenAPIFilter{})
+	assert.Error(t, err)
+}
+
+func TestHttp_OpenAPISpecFromServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v3/api-docs", r.URL.Path)
+		w.Write([]byte(openAPISpecYaml))
+	}))
+	defer server.Close()
+
+	spec, err := NewClient(server.URL, false, TransportConfig{}).Get("/v3/api-docs")
+	require.NoError(t, err)
+
+	requests, err := ToHTTPRequestsFromOpenAPI(spec, OpenAPIFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(requests))
+}
@@ -0,0 +1,92 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxRedirects is the number of redirects followed by default, the same as the Go HTTP client.
+const defaultMaxRedirects = 10
+
+const redirectLimitPrefix = "follow-with-limit="
+
+var errTooManyRedirects = errors.New("too many redirects")
+
+// RedirectPolicy controls how redirect responses are handled.
+// If NeverFollow is set, the 3xx response is returned as the result of the request, or if Reject is set too, the request fails with the redirect target.
+// Otherwise redirects are followed up to MaxRedirects times, or 10 times if zero, after which the request fails.
+type RedirectPolicy struct {
+	NeverFollow  bool
+	Reject       bool
+	MaxRedirects int
+}
+
+// ParseRedirectPolicy parses a redirect policy, which is one of follow, never-follow or follow-with-limit=N.
+func ParseRedirectPolicy(policy string) (RedirectPolicy, error) {
+	switch {
+	case policy == "follow":
+		return RedirectPolicy{}, nil
+	case policy == "never-follow":
+		return RedirectPolicy{NeverFollow: true}, nil
+	case strings.HasPrefix(policy, redirectLimitPrefix):
+		limit, err := strconv.Atoi(strings.TrimPrefix(policy, redirectLimitPrefix))
+		if err != nil || limit < 1 {
+			return RedirectPolicy{}, fmt.Errorf("invalid redirect limit in %s, expected a number greater than 0", policy)
+		}
+		return RedirectPolicy{MaxRedirects: limit}, nil
+	}
+	return RedirectPolicy{}, fmt.Errorf("invalid redirect policy %s, expected one of follow, never-follow or follow-with-limit=N", policy)
+}
+
+// checkRedirect is called by the HTTP client before following a redirect. It stops following redirects once the limit is exceeded.
+func (p RedirectPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if p.NeverFollow {
+		return http.ErrUseLastResponse
+	}
+	maxRedirects := p.MaxRedirects
+	if maxRedirects == 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+	if len(via) > maxRedirects {
+		return fmt.Errorf("%w: stopped after %d redirects", errTooManyRedirects, maxRedirects)
+	}
+	return nil
+}
+
+// redirects returns the number of redirects followed to get the response.
+func redirects(resp *http.Response) int {
+	var count int
+	for req := resp.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		count++
+	}
+	return count
+}
+
+// checkResponse returns an error if the response is a redirect that is rejected, naming where it redirects to.
+func (p RedirectPolicy) checkResponse(resp *http.Response) error {
+	if !p.Reject || resp.StatusCode < 300 || resp.StatusCode > 399 {
+		return nil
+	}
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return fmt.Errorf("redirect with status code %d not followed, no Location header", resp.StatusCode)
+	}
+	return fmt.Errorf("redirect with status code %d to %s not followed", resp.StatusCode, location)
+}
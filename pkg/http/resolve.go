@@ -0,0 +1,57 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Resolve maps host:port addresses to the IP address that connections are made to instead of resolving the host, like the --resolve option of curl.
+// The URL, the Host header and the TLS server name keep the original host.
+type Resolve map[string]string
+
+// Add parses a mapping in host:port:address format e.g. example.com:443:127.0.0.1 and adds it.
+// The address must be an IP address. IPv6 addresses can be wrapped in brackets e.g. example.com:443:[::1].
+func (r Resolve) Add(mapping string) error {
+	parts := strings.SplitN(mapping, ":", 3)
+	if len(parts) != 3 || parts[0] == "" {
+		return fmt.Errorf("invalid resolve %s, expected host:port:address", mapping)
+	}
+	host, port, address := parts[0], parts[1], strings.TrimSuffix(strings.TrimPrefix(parts[2], "["), "]")
+	if portNumber, err := strconv.Atoi(port); err != nil || portNumber < 1 || portNumber > 65535 {
+		return fmt.Errorf("invalid port %s in resolve %s", port, mapping)
+	}
+	if net.ParseIP(address) == nil {
+		return fmt.Errorf("invalid address %s in resolve %s, expected an IP address", address, mapping)
+	}
+	r[net.JoinHostPort(strings.ToLower(host), port)] = address
+	return nil
+}
+
+// dialContext returns a dial function that connects to the mapped address of the host and port, if any, and otherwise to the host and port.
+func (r Resolve) dialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if address, ok := r[strings.ToLower(addr)]; ok {
+			// the address has already been parsed by the transport
+			_, port, _ := net.SplitHostPort(addr)
+			addr = net.JoinHostPort(address, port)
+		}
+		return dial(ctx, network, addr)
+	}
+}
@@ -0,0 +1,57 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveAdd(t *testing.T) {
+	resolve := Resolve{}
+	require.NoError(t, resolve.Add("My-Service:443:127.0.0.1"))
+	require.NoError(t, resolve.Add("other:8080:[::1]"))
+	assert.Equal(t, Resolve{"my-service:443": "127.0.0.1", "other:8080": "::1"}, resolve)
+
+	for _, mapping := range []string{"my-service:443", ":443:127.0.0.1", "my-service:http:127.0.0.1", "my-service:0:127.0.0.1", "my-service:443:localhost"} {
+		assert.Error(t, resolve.Add(mapping), mapping)
+	}
+}
+
+func TestResolveKeepsHost(t *testing.T) {
+	var host string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		host = r.Host
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	_, port, _ := net.SplitHostPort(serverURL.Host)
+	resolve := Resolve{}
+	require.NoError(t, resolve.Add("my-service.invalid:"+port+":127.0.0.1"))
+
+	c := NewClient("http://my-service.invalid:"+port, false, TransportConfig{Resolve: resolve})
+	resp := c.SendRequest(context.Background(), Request{Method: "GET", Path: "/"})
+	require.NoError(t, resp.Err)
+	assert.Equal(t, "my-service.invalid:"+port, host)
+}
@@ -0,0 +1,61 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StatusCodes is a set of expected status codes. An empty set expects any 2xx or 3xx status code.
+type StatusCodes []statusCodeRange
+
+type statusCodeRange struct {
+	min int
+	max int
+}
+
+// ParseStatusCodes parses a comma-separated list of status codes or classes of status codes e.g. 200,201 or 2xx,404.
+func ParseStatusCodes(codes string) (StatusCodes, error) {
+	var statusCodes StatusCodes
+	for _, code := range strings.Split(codes, ",") {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if len(code) == 3 && strings.HasSuffix(code, "xx") && code[0] >= '1' && code[0] <= '5' {
+			class := int(code[0]-'0') * 100
+			statusCodes = append(statusCodes, statusCodeRange{min: class, max: class + 99})
+			continue
+		}
+		n, err := strconv.Atoi(code)
+		if err != nil || n < 100 || n > 599 {
+			return nil, fmt.Errorf("invalid status code %s, expected a code e.g. 200 or a class e.g. 2xx", code)
+		}
+		statusCodes = append(statusCodes, statusCodeRange{min: n, max: n})
+	}
+	return statusCodes, nil
+}
+
+// Contains returns true if the status code is expected.
+func (s StatusCodes) Contains(code int) bool {
+	if len(s) == 0 {
+		return code >= 200 && code <= 399
+	}
+	for _, r := range s {
+		if code >= r.min && code <= r.max {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttp_DefaultExpectedStatusCodes(t *testing.T) {
+	var expected StatusCodes
+	assert.True(t, expected.Contains(200))
+	assert.True(t, expected.Contains(302))
+	assert.False(t, expected.Contains(404))
+	assert.False(t, expected.Contains(500))
+}
+
+func TestHttp_ParseStatusCodes(t *testing.T) {
+	expected, err := ParseStatusCodes("201, 4XX")
+	require.NoError(t, err)
+	assert.True(t, expected.Contains(201))
+	assert.True(t, expected.Contains(404))
+	assert.False(t, expected.Contains(200))
+	assert.False(t, expected.Contains(500))
+}
+
+func TestHttp_ParseInvalidStatusCodes(t *testing.T) {
+	for _, codes := range []string{"", "abc", "99", "600", "6xx", "2x"} {
+		_, err := ParseStatusCodes(codes)
+		assert.Error(t, err, codes)
+	}
+}
+
+func TestHttp_ObjectFlagWithExpectedStatusCodes(t *testing.T) {
+	request, err := ToHTTPRequest(`{method: get, path: /missing, expect: 404}`)
+	require.NoError(t, err)
+	assert.True(t, request.ExpectedStatusCodes.Contains(404))
+	assert.False(t, request.ExpectedStatusCodes.Contains(200))
+
+	_, err = ToHTTPRequest(`{method: get, path: /missing, expect: 4}`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,103 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	mathrand "math/rand"
+	"mittens/pkg/placeholders"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// templateGo is the value of the template field of requests whose body is a Go text/template.
+const templateGo = "go"
+
+// LoadTemplateValues reads the JSON or YAML file with the data that Go template bodies are executed with, to be set as the TemplateValues of a Parser.
+// An empty file name returns no values.
+func LoadTemplateValues(file string) (interface{}, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("template values: %v", err)
+	}
+	var values interface{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return nil, fmt.Errorf("template values %s: %v", file, err)
+	}
+	return values, nil
+}
+
+// templateFuncs exposes the placeholders as template functions e.g. {{ rangeInt 1 10 }} or {{ currentDate "days+1" }}.
+// The range placeholder is named rangeInt as range is a keyword in templates.
+var templateFuncs = template.FuncMap{
+	"uuid":     placeholders.UUID,
+	"hostname": placeholders.Hostname,
+	"rangeInt": func(min, max int) (int, error) {
+		if min > max {
+			return 0, fmt.Errorf("invalid range %d-%d, min > max", min, max)
+		}
+		return mathrand.Intn(max-min+1) + min, nil
+	},
+	"currentDate": func(modifiers ...string) (string, error) {
+		return placeholders.Interpolate(placeholder("currentDate", modifiers))
+	},
+	"currentTimestamp": func(modifiers ...string) (string, error) {
+		return placeholders.Interpolate(placeholder("currentTimestamp", modifiers))
+	},
+	"randomFrom": func(elements ...string) (string, error) {
+		if len(elements) == 0 {
+			return "", fmt.Errorf("randomFrom requires at least one element")
+		}
+		return elements[mathrand.Intn(len(elements))], nil
+	},
+	"bool":   placeholders.Bool,
+	"ip":     placeholders.IP,
+	"ipv6":   placeholders.IPv6,
+	"base64": placeholders.Base64,
+	"k8s": func(name string) (string, error) {
+		return placeholders.Interpolate(placeholder("k8s", []string{name}))
+	},
+}
+
+// placeholder returns the placeholder with the given name and modifiers e.g. {$currentDate|days+1,format=2006}.
+func placeholder(name string, modifiers []string) string {
+	if len(modifiers) == 0 {
+		return "{$" + name + "}"
+	}
+	return "{$" + name + "|" + strings.Join(modifiers, ",") + "}"
+}
+
+// executeTemplate executes the body as a Go text/template with the given values as data.
+// Errors include the line number of the template.
+func executeTemplate(name, body string, values interface{}) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, values); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
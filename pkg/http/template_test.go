@@ -0,0 +1,85 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHttp_TemplateBody(t *testing.T) {
+	values := writeTempFile(t, `
+items:
+  - id: 1
+  - id: 2
+`)
+	defer os.Remove(values)
+	templateValues, err := LoadTemplateValues(values)
+	require.NoError(t, err)
+
+	file := writeTempFile(t, `
+- method: post
+  path: /items
+  template: go
+  body: |
+    [{{ range $i, $item := .items }}{{ if $i }},{{ end }}{"id": {{ $item.id }}, "n": {{ rangeInt 5 5 }}, "s": "{{ randomFrom "foo" }}"}{{ end }}]
+- method: post
+  path: /raw
+  body: "{{ .items }} {$range|min=1,max=1}"
+`)
+	defer os.Remove(file)
+
+	requests, err := Parser{TemplateValues: templateValues}.ToHTTPRequestsFromFile(file)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(requests))
+	assert.Equal(t, `[{"id": 1, "n": 5, "s": "foo"},{"id": 2, "n": 5, "s": "foo"}]`+"\n", *requests[0].Body)
+	// requests without template: go are not executed as templates
+	assert.Equal(t, "{{ .items }} 1", *requests[1].Body)
+}
+
+func TestHttp_TemplateFunctions(t *testing.T) {
+	request, err := ToHTTPRequest(`{method: post, path: /, template: go, body: '{{ uuid }} {{ currentDate "format=2006" }} {{ currentTimestamp }} {{ bool }}'}`)
+	require.NoError(t, err)
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12} \d{4} \d{13} (true|false)$`, *request.Body)
+}
+
+func TestHttp_InvalidTemplateBody(t *testing.T) {
+	file := writeTempFile(t, `
+- method: post
+  path: /items
+  template: go
+  body: |
+    {
+      "id": {{ .missing }}
+    }
+`)
+	defer os.Remove(file)
+
+	_, err := ToHTTPRequestsFromFile(file)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ":2:")
+
+	for _, invalid := range []string{
+		`{method: post, path: /, template: jinja, body: test}`,
+		`{method: post, path: /, template: go, body: {key: value}}`,
+		`{method: post, path: /, template: go, body: '{{ rangeInt 2 1 }}'}`,
+	} {
+		_, err := ToHTTPRequest(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
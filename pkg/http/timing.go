@@ -0,0 +1,105 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"mittens/pkg/response"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// timingTrace captures the duration of the phases of a request using httptrace.
+// The callbacks can be called concurrently, e.g. by a dial that finishes after the request used another connection, hence the mutex.
+// If the request is redirected, the phases are those of the last request.
+type timingTrace struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wroteRequest time.Time
+	timing       response.Timing
+}
+
+// withTimingTrace returns a context that captures the phases of the request sent with it into the returned trace.
+func withTimingTrace(ctx context.Context) (context.Context, *timingTrace) {
+	t := &timingTrace{start: time.Now()}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.set(func() { t.dnsStart = time.Now() }) },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.set(func() { t.timing.DNS = sinceIfSet(t.dnsStart) })
+		},
+		ConnectStart: func(string, string) {
+			t.set(func() {
+				if t.connectStart.IsZero() {
+					t.connectStart = time.Now()
+				}
+			})
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.set(func() { t.timing.Connect = sinceIfSet(t.connectStart) })
+			}
+		},
+		TLSHandshakeStart: func() { t.set(func() { t.tlsStart = time.Now() }) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.set(func() { t.timing.TLSHandshake = sinceIfSet(t.tlsStart) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			// reused connections did not connect, even if a dial started for this request finished in the meantime
+			if info.Reused {
+				t.set(func() {
+					t.timing.DNS, t.timing.Connect, t.timing.TLSHandshake = 0, 0, 0
+					t.connectStart = time.Time{}
+				})
+			}
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) { t.set(func() { t.wroteRequest = time.Now() }) },
+		GotFirstResponseByte: func() {
+			t.set(func() {
+				if t.wroteRequest.IsZero() {
+					t.timing.TimeToFirstByte = time.Since(t.start)
+				} else {
+					t.timing.TimeToFirstByte = time.Since(t.wroteRequest)
+				}
+			})
+		},
+	}), t
+}
+
+func (t *timingTrace) set(f func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f()
+}
+
+// result returns the captured phases together with the time it took to read the body.
+func (t *timingTrace) result(bodyRead time.Duration) *response.Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timing := t.timing
+	timing.BodyRead = bodyRead
+	return &timing
+}
+
+func sinceIfSet(start time.Time) time.Duration {
+	if start.IsZero() {
+		return 0
+	}
+	return time.Since(start)
+}
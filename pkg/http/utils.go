@@ -16,19 +16,74 @@ package http
 
 import (
 	"fmt"
-	"log"
-	"math/rand"
+	"io/ioutil"
+	"mittens/pkg/placeholders"
+	"net/url"
 	"regexp"
-	"strconv"
 	"strings"
-	"time"
 )
 
 // Request represents an HTTP request.
+// Headers are sent in addition to the global ones and take precedence over them.
+// ContentType is sent as the Content-Type header of requests with a body, unless set in the headers. It defaults to application/json.
+// Weight controls how often the request is sent relative to the other requests.
+// Responses with a status code not in ExpectedStatusCodes are counted as failures.
+// Name identifies the request in the stats and defaults to the method and path.
+// If Multipart is set, it is sent as a multipart/form-data body instead of Body.
+// If Compress is gzip, the body is gzip-compressed and sent with a Content-Encoding header.
+// Host overrides the host and port of the target e.g. localhost:8081, keeping the scheme of the target unless it includes one.
+// Responses without all the RequiredResponseHeaders are counted as failures. An empty value only requires the header to be present.
+// Responses whose body does not pass the BodyAssertions are counted as failures.
+// If DigestAuth is set, the request is authenticated with HTTP digest authentication when the target challenges it.
 type Request struct {
-	Method string
-	Path   string
-	Body   *string
+	Name                    string
+	Method                  string
+	Host                    string
+	Path                    string
+	Body                    *string
+	Multipart               []MultipartPart
+	ContentType             string
+	Compress                string
+	Headers                 map[string]string
+	Weight                  int
+	ExpectedStatusCodes     StatusCodes
+	RequiredResponseHeaders map[string]string
+	BodyAssertions          BodyAssertions
+	DigestAuth              *DigestAuth
+}
+
+// GetName returns the name of the request, or its method and path if it does not have one.
+// The path is prefixed with the host for requests that set a Host header, so that requests to each virtual host can be told apart,
+// or that override the host of the target.
+func (r Request) GetName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	for k, v := range r.Headers {
+		if strings.EqualFold(k, "Host") {
+			return r.Method + " " + v + r.Path
+		}
+	}
+	if r.Host != "" {
+		return r.Method + " " + r.Host + r.Path
+	}
+	return r.Method + " " + r.Path
+}
+
+// validateHost returns an error if the host is not in the form host:port, optionally preceded by a scheme e.g. https://localhost:8443.
+func validateHost(host string) error {
+	address := host
+	if !strings.Contains(host, "://") {
+		address = "//" + host
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return fmt.Errorf("invalid host %s: %v", host, err)
+	}
+	if u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return fmt.Errorf("invalid host %s, expected format [scheme://]host[:port]", host)
+	}
+	return nil
 }
 
 var allowedHTTPMethods = map[string]interface{}{
@@ -43,121 +98,174 @@ var allowedHTTPMethods = map[string]interface{}{
 	"TRACE":   nil,
 }
 
-// anything that starts with {$, followed by any word character, and optionally followed by a modifier identifier | and the modifiers that can contain word chars + - = and ,
-var templatePlaceholderRegex = regexp.MustCompile("{\\$(\\w+(?:[\\|(?:[\\w+-=,]+)]*)}")
-var templateRangeRegex = regexp.MustCompile("{\\$range\\|min=(?P<Min>\\d+),max=(?P<Max>\\d+)}")
-var templateElementsRegex = regexp.MustCompile("{\\$random\\|(?P<Elements>[,\\w-]+)}")
-var templateDatesRegex = regexp.MustCompile("{\\$currentDate(?:\\|(?:days(?P<Days>[+-]\\d+))*(?:[,]*months(?P<Months>[+-]\\d+))*(?:[,]*years(?P<Years>[+-]\\d+))*)*}")
+// a method token as defined in RFC 7230 e.g. PROPFIND
+var methodTokenRegex = regexp.MustCompile("^[!#$%&'*+\\-.^_`|~0-9A-Za-z]+$")
 
-// ToHTTPRequest parses an HTTP request which is in a string format and stores it in a struct.
-func ToHTTPRequest(requestString string) (Request, error) {
-	parts := strings.SplitN(requestString, ":", 3)
-	if len(parts) < 2 {
-		return Request{}, fmt.Errorf("invalid request flag: %s, expected format <http-method>:<path>[:body]", requestString)
-	}
+// Parser parses HTTP requests from flags, requests files, curl commands, HAR files, access logs and OpenAPI specs.
+// If AllowCustomMethods is set, requests can use non-standard methods such as PROPFIND or PURGE, as long as they are valid tokens.
+// TemplateValues is the data that Go template bodies are executed with. See LoadTemplateValues.
+// The zero value only allows the standard methods and executes templates without data.
+type Parser struct {
+	AllowCustomMethods bool
+	TemplateValues     interface{}
+}
 
-	method := strings.ToUpper(parts[0])
-	_, ok := allowedHTTPMethods[method]
-	if !ok {
-		return Request{}, fmt.Errorf("invalid request flag: %s, method %s is not supported", requestString, method)
+// validateMethod returns an error if the method is not allowed.
+func (p Parser) validateMethod(method string) error {
+	if _, ok := allowedHTTPMethods[method]; ok {
+		return nil
 	}
-
-	// <method>:<path>
-	if len(parts) == 2 {
-		path := interpolatePlaceholders(parts[1])
-
-		return Request{
-			Method: method,
-			Path:   path,
-			Body:   nil,
-		}, nil
+	if p.AllowCustomMethods && methodTokenRegex.MatchString(method) {
+		return nil
 	}
+	return fmt.Errorf("method %s is not supported", method)
+}
 
-	path := interpolatePlaceholders(parts[1])
-	var body = interpolatePlaceholders(parts[2])
-
-	return Request{
-		Method: method,
-		Path:   path,
-		Body:   &body,
-	}, nil
+// methodsWithoutBody are the methods for which a request flag never includes a body.
+var methodsWithoutBody = map[string]interface{}{
+	"GET":     nil,
+	"HEAD":    nil,
+	"OPTIONS": nil,
+	"TRACE":   nil,
 }
 
-// dateElements replaces date placeholders with the actual dates. It supports offsets for days, months, and years.
-func dateElements(source string) string {
-	r := templateDatesRegex.FindStringSubmatch(source)
+// a media type such as application/x-www-form-urlencoded or text/plain; charset=utf-8 at the end of a request flag
+var contentTypeRegex = regexp.MustCompile(`^(application|text|multipart)/[\w.+-]+(;\s*[\w-]+=[\w-]+)*$`)
 
-	if r == nil {
-		return source
+// ToHTTPRequest parses an HTTP request which is in a string format using the zero Parser.
+func ToHTTPRequest(requestString string) (Request, error) {
+	return Parser{}.ToHTTPRequest(requestString)
+}
+
+// ToHTTPRequest parses an HTTP request which is in a string format and stores it in a struct.
+// Requests starting with { are parsed as an inline JSON or YAML object in the same format used in requests files, which allows setting per-request headers.
+func (p Parser) ToHTTPRequest(requestString string) (Request, error) {
+	if strings.HasPrefix(strings.TrimSpace(requestString), "{") {
+		request, err := p.toHTTPRequestFromObject(requestString)
+		if err != nil {
+			return Request{}, fmt.Errorf("invalid request flag: %s, %v", requestString, err)
+		}
+		return request, nil
 	}
-	days := r[1]
-	months := r[2]
-	years := r[3]
 
-	offsetDays, _ := strconv.Atoi(days)
-	offsetMonths, _ := strconv.Atoi(months)
-	offsetYears, _ := strconv.Atoi(years)
+	parts := strings.SplitN(requestString, ":", 2)
+	if len(parts) < 2 {
+		return Request{}, fmt.Errorf("invalid request flag: %s, expected format <http-method>:<path>[:body]. "+
+			"Paths containing ':' can be set using the inline object format e.g. {\"method\": \"post\", \"path\": \"/path:with:colons\", \"body\": \"...\"}", requestString)
+	}
 
-	// the date below is how the golang date formatter works. it's used for the formatting. it's not what is actually going to be displayed
-	return time.Now().AddDate(offsetYears, offsetMonths, offsetDays).Format("2006-01-02")
-}
+	// methods that don't carry a body take the rest of the string as the path, so it can contain ':'
+	// <method>:<path>[:body[:content-type]] or <method>:<path>:<field>=<value>[,<field>=<value>...]:multipart
+	var body *string
+	var contentType string
+	if _, ok := methodsWithoutBody[strings.ToUpper(parts[0])]; !ok {
+		if pathAndBody := strings.SplitN(parts[1], ":", 2); len(pathAndBody) == 2 {
+			parts[1] = pathAndBody[0]
+			body = &pathAndBody[1]
+			if i := strings.LastIndex(*body, ":"); i >= 0 && contentTypeRegex.MatchString((*body)[i+1:]) {
+				contentType = (*body)[i+1:]
+				b := (*body)[:i]
+				body = &b
+			}
+		}
+	}
 
-// timestampElements returns the current time from Unix epoch in milliseconds.
-func timestampElements() string {
-	epoch := time.Now().UnixNano() / 1000000
+	if body != nil && strings.HasSuffix(*body, multipartSuffix) {
+		request, err := p.newMultipartFieldsRequest(parts[0], parts[1], strings.TrimSuffix(*body, multipartSuffix))
+		if err != nil {
+			return Request{}, fmt.Errorf("invalid request flag: %s, %v", requestString, err)
+		}
+		return request, nil
+	}
 
-	return strconv.FormatInt(epoch, 10)
+	request, err := p.newRequest(parts[0], parts[1], body, nil)
+	if err != nil {
+		return Request{}, fmt.Errorf("invalid request flag: %s, %v", requestString, err)
+	}
+	request.ContentType = contentType
+	return request, nil
 }
 
-// randomElements replaces random element placeholders with elements which are randomly selected from the provided list.
-func randomElements(source string) string {
-	r := templateElementsRegex.FindStringSubmatch(source)
+// the suffix of request flags whose body is a list of multipart form fields
+const multipartSuffix = ":multipart"
 
-	if r == nil {
-		return source
+// newMultipartFieldsRequest creates a Request with a multipart/form-data body with the fields in the form field1=value1,field2=value2.
+// Placeholders are replaced before splitting the fields, so that their modifiers can contain commas.
+func (p Parser) newMultipartFieldsRequest(method, path, fields string) (Request, error) {
+	request, err := p.newRequest(method, path, nil, nil)
+	if err != nil {
+		return Request{}, err
 	}
-
-	s := strings.Split(r[1], ",")
-	number := rand.Intn(len(s))
-
-	return s[number]
+	interpolatedFields, err := placeholders.Interpolate(fields)
+	if err != nil {
+		return Request{}, err
+	}
+	for _, field := range strings.Split(interpolatedFields, ",") {
+		nameAndValue := strings.SplitN(field, "=", 2)
+		if len(nameAndValue) != 2 || nameAndValue[0] == "" {
+			return Request{}, fmt.Errorf("invalid multipart field %q, expected format <name>=<value>", field)
+		}
+		request.Multipart = append(request.Multipart, MultipartPart{Name: nameAndValue[0], Value: nameAndValue[1]})
+	}
+	return request, nil
 }
 
-// rangeElements replaces range element placeholders with random integers within the specified range.
-func rangeElements(source string) string {
-	r := templateRangeRegex.FindStringSubmatch(source)
-	if r == nil {
-		return source
+// newRequest validates the method and creates a Request replacing any placeholders in the path, body and header values.
+// Bodies starting with @ are read from the file that follows before replacing the placeholders.
+func (p Parser) newRequest(method, path string, body *string, headers map[string]string) (Request, error) {
+	method = strings.ToUpper(method)
+	if err := p.validateMethod(method); err != nil {
+		return Request{}, err
 	}
 
-	min, _ := strconv.Atoi(r[1])
-	max, _ := strconv.Atoi(r[2])
-
-	if min > max {
-		log.Printf("Invalid range. min > max")
-		return source
+	interpolatedPath, err := placeholders.Interpolate(path)
+	if err != nil {
+		return Request{}, err
 	}
 
-	number := rand.Intn(max-min+1) + min
-
-	return strconv.Itoa(number)
+	request := Request{
+		Method: method,
+		Path:   interpolatedPath,
+		Body:   nil,
+		Weight: 1,
+	}
+	if body != nil {
+		content, err := loadBody(*body)
+		if err != nil {
+			return Request{}, err
+		}
+		interpolatedBody, err := placeholders.Interpolate(content)
+		if err != nil {
+			return Request{}, err
+		}
+		request.Body = &interpolatedBody
+	}
+	if len(headers) > 0 {
+		request.Headers = make(map[string]string, len(headers))
+		for k, v := range headers {
+			interpolatedValue, err := placeholders.Interpolate(v)
+			if err != nil {
+				return Request{}, err
+			}
+			request.Headers[k] = interpolatedValue
+		}
+	}
+	return request, nil
 }
 
-// interpolatePlaceholders scans a string and replaces placeholders with actual values.
-// At the moment this supports; dates, timestamps, random values from a list, and random integers.
-func interpolatePlaceholders(source string) string {
-	return templatePlaceholderRegex.ReplaceAllStringFunc(source, func(templateString string) string {
-
-		if strings.Contains(templateString, "currentDate") {
-			return dateElements(templateString)
-		} else if strings.Contains(templateString, "currentTimestamp") {
-			return timestampElements()
-		} else if strings.Contains(templateString, "random") {
-			return randomElements(templateString)
-		} else if strings.Contains(templateString, "range") {
-			return rangeElements(templateString)
-		} else {
-			return source
-		}
-	})
+// loadBody returns the body as is unless it starts with @, in which case the body is read from the file that follows e.g. @body.json.
+// A body that really starts with @ can be escaped as @@ e.g. @@handle is sent as @handle.
+func loadBody(body string) (string, error) {
+	if strings.HasPrefix(body, "@@") {
+		return body[1:], nil
+	}
+	if !strings.HasPrefix(body, "@") {
+		return body, nil
+	}
+
+	content, err := ioutil.ReadFile(strings.TrimPrefix(body, "@"))
+	if err != nil {
+		return "", fmt.Errorf("cannot read body: %v", err)
+	}
+	return string(content), nil
 }
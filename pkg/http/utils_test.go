@@ -15,9 +15,13 @@
 package http
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
 	"regexp"
+	"strconv"
 	"testing"
 	"time"
 
@@ -45,6 +49,117 @@ func TestHttp_FlagWithoutBodyToHttpRequest(t *testing.T) {
 	assert.Nil(t, request.Body)
 }
 
+func TestHttp_FlagWithColonsInPathToHttpRequest(t *testing.T) {
+	requestFlag := `get:/events?from=12:30&to=13:30`
+	request, err := ToHTTPRequest(requestFlag)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodGet, request.Method)
+	assert.Equal(t, "/events?from=12:30&to=13:30", request.Path)
+	assert.Nil(t, request.Body)
+
+	requestFlag = `post:/events:{"from": "12:30"}`
+	request, err = ToHTTPRequest(requestFlag)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/events", request.Path)
+	assert.Equal(t, `{"from": "12:30"}`, *request.Body)
+
+	// DELETE can have a body, so the path ends at the first colon as for POST
+	requestFlag = `delete:/events/12:30`
+	request, err = ToHTTPRequest(requestFlag)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodDelete, request.Method)
+	assert.Equal(t, "/events/12", request.Path)
+	assert.Equal(t, "30", *request.Body)
+
+	requestFlag = `delete:/events/12%3A30`
+	request, err = ToHTTPRequest(requestFlag)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/events/12%3A30", request.Path)
+	assert.Nil(t, request.Body)
+}
+
+func TestHttp_FlagWithContentTypeToHttpRequest(t *testing.T) {
+	request, err := ToHTTPRequest("post:/login:user=foo&pass=bar:application/x-www-form-urlencoded")
+	require.NoError(t, err)
+	assert.Equal(t, "/login", request.Path)
+	assert.Equal(t, "user=foo&pass=bar", *request.Body)
+	assert.Equal(t, "application/x-www-form-urlencoded", request.ContentType)
+
+	request, err = ToHTTPRequest(`post:/ping:{"key":"value"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"key":"value"}`, *request.Body)
+	assert.Equal(t, "", request.ContentType)
+}
+
+func TestHttp_FlagWithMergePatchToHttpRequest(t *testing.T) {
+	request, err := ToHTTPRequest(`patch:/users/1:{"name": "{$random|foo}", "address": null}:application/merge-patch+json`)
+	require.NoError(t, err)
+	assert.Equal(t, "PATCH", request.Method)
+	assert.Equal(t, "/users/1", request.Path)
+	assert.Equal(t, `{"name": "foo", "address": null}`, *request.Body)
+	assert.Equal(t, "application/merge-patch+json", request.ContentType)
+}
+
+func TestHttp_FlagWithMultipartFieldsToHttpRequest(t *testing.T) {
+	request, err := ToHTTPRequest("post:/upload:name=foo,id={$range|min=1,max=1}:multipart")
+	require.NoError(t, err)
+	assert.Equal(t, "/upload", request.Path)
+	assert.Nil(t, request.Body)
+	assert.Equal(t, []MultipartPart{{Name: "name", Value: "foo"}, {Name: "id", Value: "1"}}, request.Multipart)
+
+	_, err = ToHTTPRequest("post:/upload:name:multipart")
+	assert.Error(t, err)
+}
+
+func TestHttp_InvalidFlagToHttpRequest(t *testing.T) {
+	_, err := ToHTTPRequest(`get`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "inline object format")
+}
+
+func TestHttp_ObjectFlagToHttpRequest(t *testing.T) {
+	requestFlag := `{"method": "post", "path": "/db", "body": {"db": "true"}, "headers": {"Content-Type": "application/json", "X-Tenant": "{$random|foo}"}}`
+	request, err := ToHTTPRequest(requestFlag)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodPost, request.Method)
+	assert.Equal(t, "/db", request.Path)
+	assert.Equal(t, `{"db":"true"}`, *request.Body)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json", "X-Tenant": "foo"}, request.Headers)
+}
+
+func TestHttp_YamlObjectFlagToHttpRequest(t *testing.T) {
+	requestFlag := `{method: get, path: "/events?from=12:30", headers: {X-Tenant: foo}}`
+	request, err := ToHTTPRequest(requestFlag)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodGet, request.Method)
+	assert.Equal(t, "/events?from=12:30", request.Path)
+	assert.Nil(t, request.Body)
+	assert.Equal(t, map[string]string{"X-Tenant": "foo"}, request.Headers)
+}
+
+func TestHttp_FlagWithBodyFromFileToHttpRequest(t *testing.T) {
+	file := writeTempFile(t, `{"id": "{$range|min=1,max=1}"}`)
+	defer os.Remove(file)
+
+	request, err := ToHTTPRequest("post:/db:@" + file)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id": "1"}`, *request.Body)
+
+	_, err = ToHTTPRequest("post:/db:@/non/existent.json")
+	require.Error(t, err)
+
+	// a body that really starts with @ is escaped as @@
+	request, err = ToHTTPRequest("post:/users:@@handle")
+	require.NoError(t, err)
+	assert.Equal(t, "@handle", *request.Body)
+}
+
 func TestHttp_DateInterpolation(t *testing.T) {
 	requestFlag := `post:/db_{$currentDate}:{"date": "{$currentDate|days+5,months+2,years-1}"}`
 	request, err := ToHTTPRequest(requestFlag)
@@ -57,12 +172,39 @@ func TestHttp_DateInterpolation(t *testing.T) {
 	assert.Equal(t, fmt.Sprintf(`{"date": "%s"}`, dateWithOffset), *request.Body)
 }
 
+func TestHttp_DateWithFormatInterpolation(t *testing.T) {
+	requestFlag := `post:/db_{$currentDate|format=01/02/2006}:{"date": "{$currentDate|days+5,format=02-Jan-2006}"}`
+	request, err := ToHTTPRequest(requestFlag)
+	require.NoError(t, err)
+
+	dateToday := time.Now().Format("01/02/2006")
+	dateWithOffset := time.Now().AddDate(0, 0, 5).Format("02-Jan-2006")
+	assert.Equal(t, "/db_"+dateToday, request.Path)
+	assert.Equal(t, fmt.Sprintf(`{"date": "%s"}`, dateWithOffset), *request.Body)
+}
+
 func TestHttp_FlagWithInvalidMethodToHttpRequest(t *testing.T) {
 	requestFlag := `hmm:/ping:all=true`
 	_, err := ToHTTPRequest(requestFlag)
 	require.Error(t, err)
 }
 
+func TestHttp_FlagWithCustomMethodToHttpRequest(t *testing.T) {
+	_, err := ToHTTPRequest("propfind:/files")
+	require.Error(t, err)
+
+	parser := Parser{AllowCustomMethods: true}
+	request, err := parser.ToHTTPRequest("propfind:/files")
+	require.NoError(t, err)
+	assert.Equal(t, "PROPFIND", request.Method)
+	assert.Equal(t, "/files", request.Path)
+
+	_, err = parser.ToHTTPRequest(`{method: "PUR GE", path: /cache}`)
+	require.Error(t, err)
+	_, err = parser.ToHTTPRequest("{method: \"PURGE\\u0000\", path: /cache}")
+	require.Error(t, err)
+}
+
 func TestHttp_TimestampInterpolation(t *testing.T) {
 	requestFlag := `post:/path_{$currentTimestamp}:{"body": "{$currentTimestamp}"}`
 	request, err := ToHTTPRequest(requestFlag)
@@ -80,6 +222,70 @@ func TestHttp_TimestampInterpolation(t *testing.T) {
 	assert.Equal(t, len(*request.Body), 25) // { "body": 13 numbers for timestamp
 }
 
+func TestHttp_TimestampOffsetInterpolation(t *testing.T) {
+	requestFlag := `get:/path_{$currentTimestamp|hours+2,minutes-30,days+1}`
+	request, err := ToHTTPRequest(requestFlag)
+	require.NoError(t, err)
+
+	timestamp, err := strconv.ParseInt(request.Path[len("/path_"):], 10, 64)
+	require.NoError(t, err)
+
+	expected := time.Now().AddDate(0, 0, 1).Add(90*time.Minute).UnixNano() / 1000000
+	assert.InDelta(t, expected, timestamp, 5000)
+}
+
+func TestHttp_IPInterpolation(t *testing.T) {
+	request, err := ToHTTPRequest(`post:/geo?ip={$ip}:{"ip": "{$ipv6}"}`)
+	require.NoError(t, err)
+
+	ip := net.ParseIP(request.Path[len("/geo?ip="):])
+	require.NotNil(t, ip)
+	assert.NotNil(t, ip.To4())
+
+	var body struct{ IP string }
+	require.NoError(t, json.Unmarshal([]byte(*request.Body), &body))
+	ipv6 := net.ParseIP(body.IP)
+	require.NotNil(t, ipv6, body.IP)
+	assert.Contains(t, body.IP, ":")
+}
+
+func TestHttp_K8sInterpolation(t *testing.T) {
+	os.Setenv("MY_POD_NAME", "my-pod-7d4b9")
+	os.Setenv("MY_POD_NAMESPACE", "default")
+	defer os.Unsetenv("MY_POD_NAME")
+	defer os.Unsetenv("MY_POD_NAMESPACE")
+
+	request, err := ToHTTPRequest(`post:/pods/{$k8s|namespace}:{"pod": "{$k8s|pod_name}"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "/pods/default", request.Path)
+	assert.Equal(t, `{"pod": "my-pod-7d4b9"}`, *request.Body)
+}
+
+func TestHttp_InvalidK8sInterpolation(t *testing.T) {
+	_, err := ToHTTPRequest(`get:/pods/{$k8s|container}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"container"`)
+
+	os.Unsetenv("MY_NODE_NAME")
+	_, err = ToHTTPRequest(`get:/nodes/{$k8s|node_name}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MY_NODE_NAME")
+}
+
+func TestHttp_InvalidTimestampOffsetInterpolation(t *testing.T) {
+	_, err := ToHTTPRequest(`get:/path_{$currentTimestamp|weeks+1}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"weeks+1"`)
+
+	_, err = ToHTTPRequest(`get:/path_{$currentTimestamp|hours+x}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"hours+x"`)
+
+	_, err = ToHTTPRequest(`get:/path_{$currentTimestamp|hours+1,hours-1}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"hours-1"`)
+}
+
 func TestHttp_MultipleInterpolation(t *testing.T) {
 	requestFlag := `post:/path_{$range|min=1,max=2}_{$random|foo,bar}:{"body": "{$random|foo,bar} {$range|min=1,max=2}"}`
 	request, err := ToHTTPRequest(requestFlag)
@@ -97,6 +303,21 @@ func TestHttp_MultipleInterpolation(t *testing.T) {
 	assert.True(t, matchBody)
 }
 
+func TestHttp_UnknownPlaceholderInterpolation(t *testing.T) {
+	request, err := ToHTTPRequest(`post:/path_{$unknown}_{$range|min=1,max=1}:{"a": "{$unknown|foo}", "b": {$range|min=2,max=2}, "c": "{$random|foo}"}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/path_{$unknown}_1", request.Path)
+	assert.Equal(t, `{"a": "{$unknown|foo}", "b": 2, "c": "foo"}`, *request.Body)
+}
+
+func TestHttp_RepeatedPlaceholderInterpolation(t *testing.T) {
+	request, err := ToHTTPRequest(`post:/path:{$range|min=3,max=3},{$range|min=3,max=3},{$unknown},{$random|foo},{$unknown},{$random|bar}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, "3,3,{$unknown},foo,{$unknown},bar", *request.Body)
+}
+
 func TestHttp_RangeInterpolation(t *testing.T) {
 	requestFlag := `post:/path_{$range|min=1,max=2}:{"body": "{$range|min=1,max=2}"}`
 	request, err := ToHTTPRequest(requestFlag)
@@ -126,6 +347,13 @@ func TestHttp_InvalidRangeInterpolation(t *testing.T) {
 	assert.Equal(t, *request.Body, "{\"body\": \"{$range|min=2,max=1}\"}")
 }
 
+func TestHttp_BoolInterpolation(t *testing.T) {
+	request, err := ToHTTPRequest(`post:/path:{"a": {$bool}, "b": {$random|type=bool}}`)
+	require.NoError(t, err)
+
+	assert.Regexp(t, `^{"a": (true|false), "b": (true|false)}$`, *request.Body)
+}
+
 func TestHttp_RandomElementInterpolation(t *testing.T) {
 	requestFlag := `post:/path_{$random|fo-o,b_ar}:{"body": "{$random|fo-o,b_ar}"}`
 	request, err := ToHTTPRequest(requestFlag)
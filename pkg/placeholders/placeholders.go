@@ -0,0 +1,365 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package placeholders
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	mathrand "math/rand"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// anything that starts with {$, followed by any word character, and optionally followed by a modifier identifier | and the modifiers that can contain word chars + - = and ,
+// currentDate has its own branch as its format modifier is a Go time layout, which can contain any character but } e.g. spaces.
+var templatePlaceholderRegex = regexp.MustCompile("{\\$(currentDate\\|[^}]*|\\w+(?:[\\|(?:[\\w+-=,]+)]*)}")
+var templateRangeRegex = regexp.MustCompile("{\\$range\\|min=(?P<Min>\\d+),max=(?P<Max>\\d+)}")
+var templateElementsRegex = regexp.MustCompile("{\\$random\\|(?P<Elements>[,\\w-]+)}")
+var templateDatesRegex = regexp.MustCompile("{\\$currentDate(?:\\|(?:days(?P<Days>[+-]\\d+))*(?:[,]*months(?P<Months>[+-]\\d+))*(?:[,]*years(?P<Years>[+-]\\d+))*(?:[,]*format=(?P<Format>[^}]+))*)*}")
+var templateTimestampRegex = regexp.MustCompile("{\\$currentTimestamp(?:\\|(?P<Modifiers>[^}]*))?}")
+var templateBoolRegex = regexp.MustCompile("^{\\$(?:bool|random\\|type=bool)}$")
+var templateEmailRegex = regexp.MustCompile("^{\\$(?:email|random\\|type=email)}$")
+var templateK8sRegex = regexp.MustCompile("^{\\$k8s\\|(?P<Name>\\w+)}$")
+var timestampOffsetRegex = regexp.MustCompile("^(?P<Unit>seconds|minutes|hours|days|months|years)(?P<Offset>[+-]\\d+)$")
+var timestampUnitRegex = regexp.MustCompile("^unit=(?P<Unit>seconds|milliseconds)$")
+
+// emailDomains are the domains of the random email addresses. They are reserved for documentation and testing, so emails are never delivered.
+var emailDomains = []string{"example.com", "example.net", "example.org", "mittens.test"}
+
+const emailLocalPartChars = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// hostname is looked up once at startup as it does not change while mittens runs.
+var hostname = lookupHostname()
+
+// dateElements replaces date placeholders with the actual dates. It supports offsets for days, months, and years.
+// The date is formatted as ISO-8601 (2006-01-02) unless a custom Go time layout is set with the format modifier.
+func dateElements(source string) string {
+	r := templateDatesRegex.FindStringSubmatch(source)
+
+	if r == nil {
+		return source
+	}
+	days := r[1]
+	months := r[2]
+	years := r[3]
+	format := r[4]
+
+	offsetDays, _ := strconv.Atoi(days)
+	offsetMonths, _ := strconv.Atoi(months)
+	offsetYears, _ := strconv.Atoi(years)
+
+	// the date below is how the golang date formatter works. it's used for the formatting. it's not what is actually going to be displayed
+	if format == "" {
+		format = "2006-01-02"
+	}
+	return time.Now().AddDate(offsetYears, offsetMonths, offsetDays).Format(format)
+}
+
+// timestampElements returns the current time from Unix epoch in milliseconds, or in seconds with the unit modifier e.g. {$currentTimestamp|unit=seconds}.
+// It supports offsets in seconds, minutes, hours, days, months, and years e.g. {$currentTimestamp|hours+2,minutes-30}.
+func timestampElements(source string) (string, error) {
+	r := templateTimestampRegex.FindStringSubmatch(source)
+	if r == nil {
+		return source, nil
+	}
+
+	offsets := make(map[string]int)
+	var unit string
+	if r[1] != "" {
+		for _, modifier := range strings.Split(r[1], ",") {
+			if strings.HasPrefix(modifier, "unit=") {
+				u := timestampUnitRegex.FindStringSubmatch(modifier)
+				if u == nil {
+					return source, fmt.Errorf("invalid modifier %q in %s, expected unit=seconds or unit=milliseconds", modifier, source)
+				}
+				if unit != "" {
+					return source, fmt.Errorf("conflicting modifier %q in %s, unit is already set", modifier, source)
+				}
+				unit = u[1]
+				continue
+			}
+			m := timestampOffsetRegex.FindStringSubmatch(modifier)
+			if m == nil {
+				return source, fmt.Errorf("invalid modifier %q in %s", modifier, source)
+			}
+			if _, ok := offsets[m[1]]; ok {
+				return source, fmt.Errorf("conflicting modifier %q in %s, %s offset is already set", modifier, source, m[1])
+			}
+			offsets[m[1]], _ = strconv.Atoi(m[2])
+		}
+	}
+
+	timestamp := time.Now().
+		AddDate(offsets["years"], offsets["months"], offsets["days"]).
+		Add(time.Duration(offsets["hours"])*time.Hour + time.Duration(offsets["minutes"])*time.Minute + time.Duration(offsets["seconds"])*time.Second)
+	if unit == "seconds" {
+		return strconv.FormatInt(timestamp.Unix(), 10), nil
+	}
+	epoch := timestamp.UnixNano() / 1000000
+
+	return strconv.FormatInt(epoch, 10), nil
+}
+
+// k8sEnvVars maps the names supported by the k8s placeholder to the environment variables that, by convention,
+// are set from the Kubernetes downward API.
+var k8sEnvVars = map[string]string{
+	"pod_name":  "MY_POD_NAME",
+	"namespace": "MY_POD_NAMESPACE",
+	"node_name": "MY_NODE_NAME",
+	"pod_ip":    "MY_POD_IP",
+}
+
+// k8sElement returns the value of the Kubernetes downward API environment variable for the placeholder e.g. {$k8s|pod_name}.
+// It returns an error if the name is not supported or the environment variable is not set.
+func k8sElement(source string) (string, error) {
+	r := templateK8sRegex.FindStringSubmatch(source)
+	if r == nil {
+		return source, fmt.Errorf("invalid placeholder %s", source)
+	}
+
+	envVar, ok := k8sEnvVars[r[1]]
+	if !ok {
+		return source, fmt.Errorf("unknown name %q in %s", r[1], source)
+	}
+	value, ok := os.LookupEnv(envVar)
+	if !ok {
+		return source, fmt.Errorf("environment variable %s for %s is not set", envVar, source)
+	}
+	return value, nil
+}
+
+// randomElements replaces random element placeholders with elements which are randomly selected from the provided list.
+func randomElements(source string) string {
+	r := templateElementsRegex.FindStringSubmatch(source)
+
+	if r == nil {
+		return source
+	}
+
+	s := strings.Split(r[1], ",")
+	number := mathrand.Intn(len(s))
+
+	return s[number]
+}
+
+// Bool returns either true or false at random.
+func Bool() string {
+	return strconv.FormatBool(mathrand.Intn(2) == 1)
+}
+
+// Email returns a random email address with an 8-character alphanumeric local part e.g. k3x9q2ab@example.com.
+func Email() string {
+	localPart := make([]byte, 8)
+	for i := range localPart {
+		localPart[i] = emailLocalPartChars[mathrand.Intn(len(emailLocalPartChars))]
+	}
+	return string(localPart) + "@" + emailDomains[mathrand.Intn(len(emailDomains))]
+}
+
+// IP returns a random IPv4 address in dotted-quad notation e.g. 192.0.2.1.
+func IP() string {
+	return fmt.Sprintf("%d.%d.%d.%d", mathrand.Intn(256), mathrand.Intn(256), mathrand.Intn(256), mathrand.Intn(256))
+}
+
+// IPv6 returns a random IPv6 address in full notation e.g. 2001:db8:0:0:0:ff00:42:8329.
+func IPv6() string {
+	groups := make([]string, 8)
+	for i := range groups {
+		groups[i] = strconv.FormatInt(int64(mathrand.Intn(65536)), 16)
+	}
+	return strings.Join(groups, ":")
+}
+
+// Hostname returns the hostname of the machine e.g. the name of the pod, or unknown if it cannot be looked up.
+func Hostname() string {
+	return hostname
+}
+
+func lookupHostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		log.Printf("Hostname placeholder: %v, using unknown", err)
+		return "unknown"
+	}
+	return name
+}
+
+// the start of base64 placeholders, whose value can contain any character, including other placeholders e.g. {$base64|user:{$uuid}}
+const base64Prefix = "{$base64|"
+
+// base64Elements replaces base64 placeholders with the base64 standard encoding of their values.
+// Placeholders in the values are replaced first, so that their results are encoded. An error is returned if a placeholder is not closed.
+func base64Elements(source string) (string, error) {
+	var result strings.Builder
+	for {
+		start := strings.Index(source, base64Prefix)
+		if start == -1 {
+			result.WriteString(source)
+			return result.String(), nil
+		}
+		end := closingBrace(source, start+len(base64Prefix))
+		if end == -1 {
+			return source, fmt.Errorf("placeholder %s is not closed", source[start:])
+		}
+		value, err := Interpolate(source[start+len(base64Prefix) : end])
+		if err != nil {
+			return source, err
+		}
+		result.WriteString(source[:start])
+		result.WriteString(Base64(value))
+		source = source[end+1:]
+	}
+}
+
+// closingBrace returns the index of the } that closes a placeholder whose value starts at i, skipping the placeholders nested in it.
+// It returns -1 if there is none.
+func closingBrace(source string, i int) int {
+	depth := 0
+	for ; i < len(source); i++ {
+		switch source[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// Base64 returns the base64 standard encoding of the value.
+func Base64(value string) string {
+	return base64.StdEncoding.EncodeToString([]byte(value))
+}
+
+// rangeElements replaces range element placeholders with random integers within the specified range.
+func rangeElements(source string) string {
+	r := templateRangeRegex.FindStringSubmatch(source)
+	if r == nil {
+		return source
+	}
+
+	min, _ := strconv.Atoi(r[1])
+	max, _ := strconv.Atoi(r[2])
+
+	if min > max {
+		log.Printf("Invalid range. min > max")
+		return source
+	}
+
+	number := mathrand.Intn(max-min+1) + min
+
+	return strconv.Itoa(number)
+}
+
+// Interpolate scans a string and replaces placeholders with actual values.
+// At the moment this supports; dates, timestamps, random values from a list, random booleans, random integers, random email addresses, random IP addresses, UUIDs, the hostname and Kubernetes downward API values.
+// Values can also be base64-encoded, including the results of other placeholders.
+// Unknown placeholders are left unchanged. An error is returned if a placeholder has invalid modifiers.
+func Interpolate(source string) (string, error) {
+	return interpolate(source, nil)
+}
+
+// InterpolateJSON replaces placeholders in the same way as Interpolate, escaping their values so that the source remains valid JSON
+// e.g. if a value contains a double quote.
+func InterpolateJSON(source string) (string, error) {
+	return interpolate(source, escapeJSON)
+}
+
+// interpolate replaces the placeholders in the source, escaping their values with escape, if set.
+func interpolate(source string, escape func(string) string) (string, error) {
+	source, err := base64Elements(source)
+	if err != nil {
+		return source, err
+	}
+	element := func(templateString string) string {
+
+		if strings.Contains(templateString, "currentDate") {
+			return dateElements(templateString)
+		} else if strings.Contains(templateString, "currentTimestamp") {
+			value, timestampErr := timestampElements(templateString)
+			if timestampErr != nil && err == nil {
+				err = timestampErr
+			}
+			return value
+		} else if strings.HasPrefix(templateString, "{$k8s|") {
+			value, k8sErr := k8sElement(templateString)
+			if k8sErr != nil && err == nil {
+				err = k8sErr
+			}
+			return value
+		} else if templateString == "{$uuid}" {
+			value, uuidErr := UUID()
+			if uuidErr != nil && err == nil {
+				err = uuidErr
+			}
+			return value
+		} else if templateString == "{$ip}" {
+			return IP()
+		} else if templateString == "{$ipv6}" {
+			return IPv6()
+		} else if templateString == "{$hostname}" {
+			return Hostname()
+		} else if templateBoolRegex.MatchString(templateString) {
+			return Bool()
+		} else if templateEmailRegex.MatchString(templateString) {
+			return Email()
+		} else if strings.Contains(templateString, "random") {
+			return randomElements(templateString)
+		} else if strings.Contains(templateString, "range") {
+			return rangeElements(templateString)
+		} else {
+			return templateString
+		}
+	}
+	result := templatePlaceholderRegex.ReplaceAllStringFunc(source, func(templateString string) string {
+		value := element(templateString)
+		if escape != nil && value != templateString {
+			return escape(value)
+		}
+		return value
+	})
+	return result, err
+}
+
+// escapeJSON escapes the value as the content of a JSON string. HTML characters such as < are not escaped.
+func escapeJSON(value string) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	// strings are always encoded
+	_ = enc.Encode(value)
+	encoded := strings.TrimSuffix(b.String(), "\n")
+	return encoded[1 : len(encoded)-1]
+}
+
+// UUID returns a random (version 4) UUID.
+func UUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
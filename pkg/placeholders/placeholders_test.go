@@ -0,0 +1,132 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package placeholders
+
+import (
+	"encoding/base64"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolate(t *testing.T) {
+	result, err := Interpolate("id={$range|min=1,max=1}&q={$random|foo}&unknown={$unknown}")
+	require.NoError(t, err)
+	assert.Equal(t, "id=1&q=foo&unknown={$unknown}", result)
+}
+
+func TestInterpolateDateFormat(t *testing.T) {
+	for _, layout := range []string{"01/02/2006", "02 Jan 2006", "Mon, 02 Jan 2006 15:04"} {
+		result, err := Interpolate("date={$currentDate|format=" + layout + "}&q={$random|foo}")
+		require.NoError(t, err)
+		assert.Equal(t, "date="+time.Now().Format(layout)+"&q=foo", result, layout)
+	}
+
+	result, err := Interpolate("{$currentDate|days+1,format=02 Jan 2006}")
+	require.NoError(t, err)
+	assert.Equal(t, time.Now().AddDate(0, 0, 1).Format("02 Jan 2006"), result)
+}
+
+func TestInterpolateUUID(t *testing.T) {
+	first, err := Interpolate("{$uuid}")
+	require.NoError(t, err)
+	second, err := Interpolate("{$uuid}")
+	require.NoError(t, err)
+
+	assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", first)
+	assert.NotEqual(t, first, second)
+}
+
+func TestInterpolateHostname(t *testing.T) {
+	expected, err := os.Hostname()
+	require.NoError(t, err)
+	result, err := Interpolate("host={$hostname}")
+	require.NoError(t, err)
+	assert.Equal(t, "host="+expected, result)
+}
+
+func TestInterpolateInvalidModifiers(t *testing.T) {
+	_, err := Interpolate("{$currentTimestamp|weeks+1}")
+	assert.Error(t, err)
+}
+
+func TestInterpolateTimestampUnit(t *testing.T) {
+	result, err := Interpolate("{$currentTimestamp|unit=seconds}")
+	require.NoError(t, err)
+	seconds, err := strconv.ParseInt(result, 10, 64)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Now().Unix(), seconds, 5)
+
+	result, err = Interpolate("{$currentTimestamp|hours+1,unit=seconds}")
+	require.NoError(t, err)
+	seconds, err = strconv.ParseInt(result, 10, 64)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), seconds, 5)
+
+	result, err = Interpolate("{$currentTimestamp|unit=milliseconds}")
+	require.NoError(t, err)
+	milliseconds, err := strconv.ParseInt(result, 10, 64)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Now().UnixNano()/1000000, milliseconds, 5000)
+
+	_, err = Interpolate("{$currentTimestamp|unit=minutes}")
+	assert.Error(t, err)
+	_, err = Interpolate("{$currentTimestamp|unit=seconds,unit=milliseconds}")
+	assert.Error(t, err)
+}
+
+func TestInterpolateEmail(t *testing.T) {
+	for _, placeholder := range []string{"{$email}", "{$random|type=email}"} {
+		result, err := Interpolate(`{"email": "` + placeholder + `"}`)
+		require.NoError(t, err)
+		assert.Regexp(t, `^{"email": "[a-z0-9]{8}@(example\.com|example\.net|example\.org|mittens\.test)"}$`, result)
+	}
+}
+
+func TestInterpolateBase64(t *testing.T) {
+	result, err := Interpolate(`{"id": "{$base64|hello world}"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id": "aGVsbG8gd29ybGQ="}`, result)
+
+	// inner placeholders are replaced before encoding
+	result, err = Interpolate("{$base64|user:{$random|foo}}")
+	require.NoError(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("user:foo")), result)
+
+	result, err = Interpolate("{$base64|{$base64|a}}-{$range|min=2,max=2}")
+	require.NoError(t, err)
+	assert.Equal(t, "WVE9PQ==-2", result)
+
+	_, err = Interpolate("{$base64|user:{$uuid}")
+	assert.Error(t, err)
+	_, err = Interpolate("{$base64|{$currentTimestamp|weeks+1}}")
+	assert.Error(t, err)
+}
+
+func TestInterpolateJSON(t *testing.T) {
+	result, err := InterpolateJSON(`{"name": "{$random|foo}", "id": {$range|min=3,max=3}, "unknown": "{$unknown}"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"name": "foo", "id": 3, "unknown": "{$unknown}"}`, result)
+
+	// values with characters that are not valid in JSON strings are escaped
+	assert.Equal(t, `a\"b\\c\nd<e>`, escapeJSON("a\"b\\c\nd<e>"))
+
+	_, err = InterpolateJSON(`{"at": {$currentTimestamp|weeks+1}}`)
+	assert.Error(t, err)
+}
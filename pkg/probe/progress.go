@@ -0,0 +1,76 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package probe
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Progress represents how far the warm up has got.
+// TotalRequests is nil if the number of requests is not known in advance, e.g. because requests are sent for a fixed duration.
+type Progress struct {
+	Done          bool `json:"done"`
+	RequestsSent  int  `json:"requestsSent"`
+	TotalRequests *int `json:"totalRequests"`
+}
+
+// ProgressServer represents an HTTP server that exposes the warm up progress.
+type ProgressServer struct {
+	httpServer *http.Server
+}
+
+// NewProgressServer creates a ProgressServer instance which reports the progress returned by the given function on /healthz.
+func NewProgressServer(port int, progress func() Progress) *ProgressServer {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", progressHandler(progress))
+
+	log.Printf("Progress server on %d port", port)
+
+	return &ProgressServer{httpServer: newServer(port, mux)}
+}
+
+// ListenAndServe starts the progress server.
+func (s *ProgressServer) ListenAndServe() error {
+	log.Print("Starting progress server")
+	return s.httpServer.ListenAndServe()
+}
+
+// Shutdown gracefully shuts down the progress server.
+func (s *ProgressServer) Shutdown() {
+	log.Print("Shutting down progress server")
+	if err := s.httpServer.Shutdown(context.Background()); err != nil {
+		log.Printf("Progress server shutdown: %v", err)
+	}
+}
+
+// progressHandler returns 200 once the warm up is done and 503 until then. The body includes the current progress.
+func progressHandler(progress func() Progress) func(http.ResponseWriter, *http.Request) {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := progress()
+		w.Header().Set("Content-Type", "application/json")
+		if p.Done {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(p); err != nil {
+			log.Printf("Progress server: %v", err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package probe
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ProgressIsReported(t *testing.T) {
+
+	totalRequests := 100
+	progress := Progress{Done: false, RequestsSent: 42, TotalRequests: &totalRequests}
+	server := httptest.NewServer(http.HandlerFunc(progressHandler(func() Progress { return progress })))
+	defer server.Close()
+
+	// not done yet
+	resp, err := http.DefaultClient.Get(server.URL)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, 503, resp.StatusCode)
+	assert.JSONEq(t, `{"done":false,"requestsSent":42,"totalRequests":100}`, string(body))
+
+	// done
+	progress = Progress{Done: true, RequestsSent: 100, TotalRequests: &totalRequests}
+	resp, err = http.DefaultClient.Get(server.URL)
+	require.NoError(t, err)
+	body, err = ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.JSONEq(t, `{"done":true,"requestsSent":100,"totalRequests":100}`, string(body))
+}
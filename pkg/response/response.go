@@ -18,8 +18,44 @@ import "time"
 
 // Response represents an HTTP or gRPC response.
 type Response struct {
-	Duration   time.Duration
-	Err        error
-	Type       string
+	Duration time.Duration
+	Err      error
+	// ErrCause describes why a request failed to get a response e.g. dial timeout or connection refused, if known
+	ErrCause string
+	Type     string
+	// StatusCode is the HTTP status code or, for gRPC responses, the gRPC status code where 0 means OK
 	StatusCode int
+	// Name identifies the request the response belongs to so that responses can be aggregated per request
+	Name string
+	// Body holds the first bytes of the HTTP response body so that it can be logged or, if they are captured, the gRPC responses formatted as JSON
+	Body string
+	// BytesSent is the size of the request body as sent, i.e. after compression
+	BytesSent int64
+	// BytesRead is the size of the HTTP response body as read from the connection, i.e. before decompression
+	BytesRead int64
+	// BytesDecompressed is the size of the HTTP response body after decompression, the same as BytesRead if it was not compressed
+	BytesDecompressed int64
+	// Redirects is the number of redirects followed to get the HTTP response, whose status code is that of the last one
+	Redirects int
+	// MessagesSent is the number of messages sent in the gRPC call, more than one only for client-streaming methods
+	MessagesSent int
+	// MessagesReceived is the number of messages received in the gRPC call, more than one only for server-streaming methods
+	MessagesReceived int
+	// TimeToFirstMessage is the time until the first message of the gRPC call was received, Duration being the time until the call completed
+	TimeToFirstMessage time.Duration
+	// Attempts is the number of times the request was sent, including retries
+	Attempts int
+	// Timing holds the duration of the phases of the HTTP request, if enabled
+	Timing *Timing
+}
+
+// Timing holds the duration of the phases of an HTTP request.
+// Phases that did not happen are zero e.g. DNS, Connect and TLSHandshake for requests sent over a reused connection.
+// TimeToFirstByte is the time from the request being written until the first byte of the response is received, i.e. roughly the server processing time.
+type Timing struct {
+	DNS             time.Duration
+	Connect         time.Duration
+	TLSHandshake    time.Duration
+	TimeToFirstByte time.Duration
+	BodyRead        time.Duration
 }
@@ -0,0 +1,282 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// number of ended spans after which they are exported
+const batchSize = 256
+
+// span kind and status codes as defined by OTLP
+const (
+	spanKindClient  = 3
+	statusCodeOK    = 1
+	statusCodeError = 2
+)
+
+// TraceParentHeader is the W3C trace context header that propagates the trace of a request to the target.
+const TraceParentHeader = "traceparent"
+
+// Tracer records a client span for every request and exports the spans in batches to an OpenTelemetry collector,
+// using OTLP over HTTP with JSON encoding so that no OpenTelemetry SDK is needed.
+// Only client spans are recorded, whose encoding is small enough to write by hand, whereas the SDK and the OTLP protobuf types
+// require newer gRPC and protobuf modules than the ones the gRPC client is built with. The encoding is tested against the OTLP schema.
+// A nil Tracer is valid and records nothing, so that tracing has no cost when it is not enabled.
+type Tracer struct {
+	url         string
+	serviceName string
+	client      *http.Client
+
+	mu      sync.Mutex
+	spans   []*Span
+	exports sync.WaitGroup
+}
+
+// Span is a request sent to the target. Spans are started with Tracer.Start and exported once they end.
+// A nil Span is valid and records nothing.
+type Span struct {
+	tracer     *Tracer
+	traceID    [16]byte
+	spanID     [8]byte
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	err        bool
+	message    string
+}
+
+// NewTracer returns a tracer that exports the spans to the OTLP/HTTP endpoint e.g. http://otel-collector:4318.
+// Spans are sent to the /v1/traces path of the endpoint, as the OpenTelemetry exporters do.
+func NewTracer(endpoint, serviceName string) *Tracer {
+	log.Printf("Tracing: exporting spans to %s as %s", endpoint, serviceName)
+	return &Tracer{
+		url:         strings.TrimRight(endpoint, "/") + "/v1/traces",
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Start starts a client span in a new trace.
+func (t *Tracer) Start(name string) *Span {
+	if t == nil {
+		return nil
+	}
+	span := &Span{tracer: t, name: name, start: time.Now(), attributes: make(map[string]interface{})}
+	// the IDs are random so that they are unique across mittens instances
+	rand.Read(span.traceID[:])
+	rand.Read(span.spanID[:])
+	return span
+}
+
+// TraceParent returns the value of the traceparent header that makes the target continue the trace of the span.
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:]))
+}
+
+// SetAttribute sets an attribute of the span. Values can be strings, ints or bools.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.attributes[key] = value
+}
+
+// SetError marks the span as failed with the given message.
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.err = true
+	s.message = message
+}
+
+// End ends the span. Once enough spans have ended, they are exported in the background.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	t := s.tracer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, s)
+	if len(t.spans) >= batchSize {
+		t.exportAsync(t.spans)
+		t.spans = nil
+	}
+}
+
+// Close exports the spans that have not been exported yet and waits for the exports in progress.
+func (t *Tracer) Close() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if len(t.spans) > 0 {
+		t.exportAsync(t.spans)
+		t.spans = nil
+	}
+	t.mu.Unlock()
+	t.exports.Wait()
+}
+
+func (t *Tracer) exportAsync(spans []*Span) {
+	t.exports.Add(1)
+	go func() {
+		defer t.exports.Done()
+		if err := t.export(spans); err != nil {
+			log.Printf("Tracing: %v", err)
+		}
+	}()
+}
+
+// export sends the spans to the collector. Spans that fail to be exported are dropped.
+func (t *Tracer) export(spans []*Span) error {
+	body, err := json.Marshal(t.toRequest(spans))
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Post(t.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("exporting %d spans: %v", len(spans), err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("exporting %d spans: unexpected status code %d", len(spans), resp.StatusCode)
+	}
+	return nil
+}
+
+// exportRequest and the types below are the JSON encoding of an OTLP ExportTraceServiceRequest.
+// IDs are hex-encoded and 64-bit integers are encoded as strings.
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []attribute `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []spanJSON `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type spanJSON struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []attribute `json:"attributes,omitempty"`
+	Status            status      `json:"status"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type attribute struct {
+	Key   string         `json:"key"`
+	Value attributeValue `json:"value"`
+}
+
+type attributeValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+func (t *Tracer) toRequest(spans []*Span) exportRequest {
+	encoded := make([]spanJSON, 0, len(spans))
+	for _, s := range spans {
+		spanStatus := status{Code: statusCodeOK}
+		if s.err {
+			spanStatus = status{Code: statusCodeError, Message: s.message}
+		}
+		encoded = append(encoded, spanJSON{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              spanKindClient,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        toAttributes(s.attributes),
+			Status:            spanStatus,
+		})
+	}
+	return exportRequest{ResourceSpans: []resourceSpans{{
+		Resource:   resource{Attributes: toAttributes(map[string]interface{}{"service.name": t.serviceName})},
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: "mittens"}, Spans: encoded}},
+	}}}
+}
+
+// toAttributes converts the attributes to their OTLP encoding, sorted by key. Values other than strings, ints and bools are encoded as strings.
+func toAttributes(attributes map[string]interface{}) []attribute {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var encoded []attribute
+	for _, key := range keys {
+		var v attributeValue
+		switch typed := attributes[key].(type) {
+		case int:
+			s := strconv.Itoa(typed)
+			v.IntValue = &s
+		case bool:
+			v.BoolValue = &typed
+		default:
+			s := fmt.Sprint(typed)
+			v.StringValue = &s
+		}
+		encoded = append(encoded, attribute{Key: key, Value: v})
+	}
+	return encoded
+}
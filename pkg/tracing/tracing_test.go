@@ -0,0 +1,212 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracerExport(t *testing.T) {
+	requests := make(chan exportRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var request exportRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		requests <- request
+	}))
+	defer collector.Close()
+
+	tracer := NewTracer(collector.URL+"/", "my-warmup")
+	span := tracer.Start("HTTP GET")
+	assert.Regexp(t, regexp.MustCompile("^00-[0-9a-f]{32}-[0-9a-f]{16}-01$"), span.TraceParent())
+	span.SetAttribute("http.method", "GET")
+	span.SetAttribute("http.status_code", 503)
+	span.SetError("HTTP status code 503")
+	span.End()
+	tracer.Close()
+
+	request := <-requests
+	require.Len(t, request.ResourceSpans, 1)
+	assert.Equal(t, "service.name", request.ResourceSpans[0].Resource.Attributes[0].Key)
+	assert.Equal(t, "my-warmup", *request.ResourceSpans[0].Resource.Attributes[0].Value.StringValue)
+
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 1)
+	assert.Equal(t, "HTTP GET", spans[0].Name)
+	assert.Equal(t, spanKindClient, spans[0].Kind)
+	assert.Equal(t, status{Code: statusCodeError, Message: "HTTP status code 503"}, spans[0].Status)
+	assert.Equal(t, span.TraceParent()[3:35], spans[0].TraceID)
+	require.Len(t, spans[0].Attributes, 2)
+	assert.Equal(t, "GET", *spans[0].Attributes[0].Value.StringValue)
+	assert.Equal(t, "503", *spans[0].Attributes[1].Value.IntValue)
+}
+
+func TestNilTracer(t *testing.T) {
+	var tracer *Tracer
+	span := tracer.Start("HTTP GET")
+	assert.Nil(t, span)
+	assert.Equal(t, "", span.TraceParent())
+	span.SetAttribute("http.method", "GET")
+	span.SetError("failed")
+	span.End()
+	tracer.Close()
+}
+
+func TestTracerExportMatchesOTLPSchema(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		bodies <- body
+	}))
+	defer collector.Close()
+
+	tracer := NewTracer(collector.URL, "my-warmup")
+	span := tracer.Start("HTTP GET")
+	span.SetAttribute("http.method", "GET")
+	span.SetAttribute("http.status_code", 200)
+	span.SetAttribute("mittens.warmup", true)
+	span.End()
+	failed := tracer.Start("grpc.health.v1.Health/Check")
+	failed.SetError("grpc status Unavailable")
+	failed.End()
+	tracer.Close()
+
+	var request interface{}
+	require.NoError(t, json.Unmarshal(<-bodies, &request))
+	assert.Empty(t, validateOTLP(t, request))
+}
+
+func TestOTLPSchema(t *testing.T) {
+	// the example request of opentelemetry-proto is valid, so the schema is not stricter than OTLP
+	example, err := ioutil.ReadFile("testdata/otlp-trace-example.json")
+	require.NoError(t, err)
+	var request map[string]interface{}
+	require.NoError(t, json.Unmarshal(example, &request))
+	assert.Empty(t, validateOTLP(t, request))
+
+	// while the mistakes a hand-written encoding can make are not
+	span := request["resourceSpans"].([]interface{})[0].(map[string]interface{})["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})[0].(map[string]interface{})
+	span["startTimeUnixNano"] = 1544712660000000000
+	span["kind"] = "SPAN_KIND_CLIENT"
+	span["trace_id"] = span["traceId"]
+	delete(span, "traceId")
+	span["attributes"].([]interface{})[0].(map[string]interface{})["value"] = map[string]interface{}{"intValue": 1}
+	encoded, err := json.Marshal(request)
+	require.NoError(t, err)
+	var invalid interface{}
+	require.NoError(t, json.Unmarshal(encoded, &invalid))
+	assert.Len(t, validateOTLP(t, invalid), 5)
+}
+
+// validateOTLP returns the errors of validating the request against the JSON schema of the OTLP ExportTraceServiceRequest in testdata.
+func validateOTLP(t *testing.T, request interface{}) []string {
+	content, err := ioutil.ReadFile("testdata/otlp-trace.schema.json")
+	require.NoError(t, err)
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(content, &schema))
+	return validateSchema(schema["definitions"].(map[string]interface{}), schema, request, "$")
+}
+
+// validateSchema validates the value against the subset of JSON schema used in testdata: $ref, type, enum, pattern,
+// properties, required, additionalProperties, maxProperties and items.
+func validateSchema(definitions, schema map[string]interface{}, value interface{}, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		return validateSchema(definitions, definitions[strings.TrimPrefix(ref, "#/definitions/")].(map[string]interface{}), value, path)
+	}
+
+	var errs []string
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object, got %v", path, value)}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			property, ok := properties[key].(map[string]interface{})
+			if !ok {
+				if schema["additionalProperties"] == false {
+					errs = append(errs, fmt.Sprintf("%s: unknown field %s", path, key))
+				}
+				continue
+			}
+			errs = append(errs, validateSchema(definitions, property, object[key], path+"."+key)...)
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, key := range required {
+			if _, ok := object[key.(string)]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing field %s", path, key))
+			}
+		}
+		if max, ok := schema["maxProperties"].(float64); ok && len(object) > int(max) {
+			errs = append(errs, fmt.Sprintf("%s: more than %v fields", path, max))
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an array, got %v", path, value)}
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range array {
+				errs = append(errs, validateSchema(definitions, items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected a string, got %v", path, value)}
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(s) {
+			errs = append(errs, fmt.Sprintf("%s: %q does not match %s", path, s, pattern))
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok || (schema["type"] == "integer" && n != float64(int64(n))) {
+			return []string{fmt.Sprintf("%s: expected an %s, got %v", path, schema["type"], value)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: expected a boolean, got %v", path, value)}
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		for _, allowed := range enum {
+			if allowed == value {
+				return errs
+			}
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+	}
+	return errs
+}
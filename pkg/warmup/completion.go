@@ -0,0 +1,56 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package warmup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+// completionPayload is the body sent to the completion URL once the warm up finishes.
+type completionPayload struct {
+	Status     string `json:"status"`
+	DurationMs int64  `json:"durationMs"`
+	Errors     int    `json:"errors"`
+}
+
+// NotifyCompletion sends a POST request to the given URL to signal that the warm up has finished.
+// The body includes the duration of the warm up and the number of requests that failed.
+func NotifyCompletion(url string, duration time.Duration, errors int) error {
+	body, err := json.Marshal(completionPayload{Status: "done", DurationMs: int64(duration / time.Millisecond), Errors: errors})
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	log.Printf("Notifying completion to %s", url)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("completion callback: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("completion callback: unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package warmup
+
+import (
+	"context"
+	"log"
+	"mittens/pkg/http"
+	"sync"
+	"time"
+)
+
+// ConnectionWarmup opens count connections to the HTTP target, using as many goroutines as the concurrency, without sending any requests
+// unless path is set, in which case a HEAD request to that path is sent on each connection.
+// Each connection is recorded in the stats as a response of type connection, whose latency is the time to connect including the TLS handshake.
+// It stops once all the connections have been opened, the context is done or Shutdown is closed.
+func (w Warmup) ConnectionWarmup(ctx context.Context, count int, path string, stats *Stats) {
+	stopCtx, stop := w.shutdownContext(ctx)
+	defer stop()
+	connections := make(chan int)
+	go func() {
+		defer close(connections)
+		for i := 0; i < count; i++ {
+			select {
+			case <-stopCtx.Done():
+				return
+			case connections <- i:
+			}
+		}
+	}()
+
+	concurrency := w.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range connections {
+				resp := w.Target.httpClient.Connect(ctx, path)
+				resp.Name = http.ConnectionType
+				w.record(resp, stats)
+				if resp.Err != nil {
+					log.Printf("🔴 Error in connection: %v", resp.Err)
+				} else {
+					log.Printf("Connection opened in %d ms", resp.Duration/time.Millisecond)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,41 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package warmup
+
+import (
+	"context"
+	"mittens/pkg/grpc"
+	"mittens/pkg/http"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionWarmup(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	defer server.Close()
+
+	target := NewTarget(http.Client{}, grpc.Client{}, http.NewClient(server.URL, false, http.TransportConfig{}), grpc.Client{}, TargetOptions{})
+	stats := &Stats{}
+	Warmup{Target: target, Concurrency: 2}.ConnectionWarmup(context.Background(), 5, "", stats)
+
+	assert.Equal(t, 5, stats.RequestsSent())
+	perName := stats.PerName()
+	assert.Len(t, perName, 1)
+	assert.Equal(t, http.ConnectionType, perName[0].Name)
+	assert.Empty(t, stats.StatusCodes())
+}
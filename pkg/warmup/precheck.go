@@ -0,0 +1,87 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package warmup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+// time to wait between attempts to connect to a port that is not open yet
+const precheckInterval = 500 * time.Millisecond
+
+// Address is a network address that the target listens on e.g. tcp localhost:8080 or unix /var/run/app.sock.
+type Address struct {
+	Network string
+	Address string
+}
+
+// WaitForPorts tries to connect to each of the addresses until all of them accept connections.
+// It returns an error if any of them is still not open after the timeout or once the context is done.
+func WaitForPorts(ctx context.Context, addresses []Address, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for _, address := range addresses {
+		log.Printf("Waiting for %s %s to be open for a max of %s", address.Network, address.Address, timeout)
+		if err := waitForPort(ctx, address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForPort tries to connect to the address until it accepts a connection or the context is done.
+func waitForPort(ctx context.Context, address Address) error {
+	dialer := net.Dialer{Timeout: time.Second}
+	for {
+		conn, err := dialer.DialContext(ctx, address.Network, address.Address)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if !sleep(ctx, precheckInterval) {
+			return fmt.Errorf("%s %s is not open: %v", address.Network, address.Address, err)
+		}
+	}
+}
+
+// WaitForSocket waits for the Unix domain socket at the path to be created.
+// It returns an error if the path is not a socket, or if it still does not exist after the timeout or once the context is done.
+func WaitForSocket(ctx context.Context, path string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	log.Printf("Waiting for socket %s to be created for a max of %s", path, timeout)
+	for {
+		info, err := os.Stat(path)
+		if err == nil {
+			if info.Mode()&os.ModeSocket == 0 {
+				return fmt.Errorf("%s is not a socket", path)
+			}
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if !sleep(ctx, precheckInterval) {
+			return fmt.Errorf("socket %s does not exist", path)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package warmup
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForPorts(t *testing.T) {
+	// reserve a port and release it so that it is closed until the listener below starts
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	go func() {
+		time.Sleep(time.Second)
+		if l, err := net.Listen("tcp", address); err == nil {
+			defer l.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	err = WaitForPorts(context.Background(), []Address{{Network: "tcp", Address: address}}, 5*time.Second)
+	assert.NoError(t, err)
+}
+
+func TestWaitForPortsTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	start := time.Now()
+	err = WaitForPorts(context.Background(), []Address{{Network: "tcp", Address: address}}, time.Second)
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 3*time.Second)
+}
+
+func TestWaitForSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mittens")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "app.sock")
+
+	go func() {
+		time.Sleep(time.Second)
+		if l, err := net.Listen("unix", socket); err == nil {
+			defer l.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	err = WaitForSocket(context.Background(), socket, 5*time.Second)
+	assert.NoError(t, err)
+}
+
+func TestWaitForSocketNotSocket(t *testing.T) {
+	file, err := ioutil.TempFile("", "mittens")
+	require.NoError(t, err)
+	file.Close()
+	defer os.Remove(file.Name())
+
+	err = WaitForSocket(context.Background(), file.Name(), 5*time.Second)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not a socket")
+}
+
+func TestWaitForSocketTimeout(t *testing.T) {
+	err := WaitForSocket(context.Background(), "/non-existent/app.sock", time.Second)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not exist")
+}
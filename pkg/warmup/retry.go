@@ -0,0 +1,82 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package warmup
+
+import (
+	"context"
+	"log"
+	"mittens/pkg/http"
+	"mittens/pkg/response"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Retry controls how failed requests are retried.
+// Requests that fail without a response, e.g. because the connection was refused, are sent up to MaxAttempts times in total,
+// waiting Backoff before the first retry and doubling the wait before each of the next ones.
+// HTTP responses with any of the HTTPStatusCodes, and gRPC responses with the UNAVAILABLE status code, are retried too.
+// A MaxAttempts of 1 or less disables retries.
+type Retry struct {
+	MaxAttempts     int
+	Backoff         time.Duration
+	HTTPStatusCodes http.StatusCodes
+}
+
+// backoff returns the time to wait before retrying after the given attempt.
+func (r Retry) backoff(attempt int) time.Duration {
+	return r.Backoff << uint(attempt-1)
+}
+
+// isRetryable returns true if the request should be sent again after the response.
+func (r Retry) isRetryable(resp response.Response) bool {
+	if resp.ErrCause == "cancelled" {
+		return false
+	}
+	if resp.Type == "grpc" {
+		return resp.Err != nil && (resp.StatusCode == 0 || resp.StatusCode == int(codes.Unavailable))
+	}
+	if resp.Err != nil {
+		return resp.StatusCode == 0
+	}
+	// an empty set contains any 2xx and 3xx status code, which must not be retried
+	return len(r.HTTPStatusCodes) > 0 && r.HTTPStatusCodes.Contains(resp.StatusCode)
+}
+
+// send calls sendRequest until the response is not retryable or the max attempts are reached, and returns the last response with the number of attempts.
+// No more retries are made once retryCtx is done, so that retries do not extend the warm up past its max duration.
+func (r Retry) send(retryCtx context.Context, request string, sendRequest func() response.Response) response.Response {
+	for attempt := 1; ; attempt++ {
+		resp := sendRequest()
+		resp.Attempts = attempt
+		if attempt >= r.MaxAttempts || !r.isRetryable(resp) {
+			return resp
+		}
+
+		backoff := r.backoff(attempt)
+		log.Printf("🟡 Retrying request for %s in %d ms (attempt %d of %d)", request, backoff/time.Millisecond, attempt+1, r.MaxAttempts)
+		if !sleep(retryCtx, backoff) {
+			return resp
+		}
+	}
+}
+
+// retryContext returns a context that is done once the max duration of the warm up is exceeded, if set, or when ctx is done.
+func (w Warmup) retryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if w.MaxDurationSeconds <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(w.MaxDurationSeconds)*time.Second)
+}
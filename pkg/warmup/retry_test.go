@@ -0,0 +1,110 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package warmup
+
+import (
+	"context"
+	"errors"
+	"mittens/pkg/grpc"
+	"mittens/pkg/http"
+	"mittens/pkg/response"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	retry := Retry{MaxAttempts: 4, Backoff: 100 * time.Millisecond}
+	assert.Equal(t, 100*time.Millisecond, retry.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, retry.backoff(2))
+	assert.Equal(t, 400*time.Millisecond, retry.backoff(3))
+}
+
+func TestRetryConnectionErrors(t *testing.T) {
+	var attempts int
+	retry := Retry{MaxAttempts: 3, Backoff: time.Millisecond}
+	resp := retry.send(context.Background(), "/ping", func() response.Response {
+		attempts++
+		return response.Response{Type: "http", Err: errors.New("connection refused"), ErrCause: "connection refused"}
+	})
+
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 3, resp.Attempts)
+	assert.Error(t, resp.Err)
+}
+
+func TestRetrySuccessfulResponse(t *testing.T) {
+	var attempts int
+	retry := Retry{MaxAttempts: 3, Backoff: time.Millisecond}
+	resp := retry.send(context.Background(), "/ping", func() response.Response {
+		attempts++
+		return response.Response{Type: "http", StatusCode: 200}
+	})
+
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, 1, resp.Attempts)
+}
+
+func TestRetryStopsAfterMaxDuration(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var attempts int
+	retry := Retry{MaxAttempts: 10, Backoff: time.Second}
+	start := time.Now()
+	resp := retry.send(ctx, "/ping", func() response.Response {
+		attempts++
+		return response.Response{Type: "http", Err: errors.New("connection refused")}
+	})
+
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, 1, resp.Attempts)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestHTTPWarmupWorkerRetryOnStatus(t *testing.T) {
+	var mu sync.Mutex
+	var requests int
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests < 3 {
+			w.WriteHeader(nethttp.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	retryOnStatus, _ := http.ParseStatusCodes("503")
+	target := NewTarget(http.Client{}, grpc.Client{}, http.NewClient(server.URL, false, http.TransportConfig{}), grpc.Client{}, TargetOptions{})
+	warmupRequests := make(chan http.Request, 1)
+	warmupRequests <- http.Request{Method: "GET", Path: "/ping"}
+	close(warmupRequests)
+
+	stats := &Stats{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	retry := Retry{MaxAttempts: 3, Backoff: time.Millisecond, HTTPStatusCodes: retryOnStatus}
+	Warmup{Target: target, Retry: retry}.HTTPWarmupWorker(context.Background(), &wg, warmupRequests, nil, Delay{}, stats)
+
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, 1, stats.RequestsSent())
+	assert.Equal(t, 0, stats.RequestsFailed())
+	assert.Equal(t, 2, stats.Retries())
+}
@@ -0,0 +1,209 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package warmup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mittens/pkg/grpc"
+	"mittens/pkg/http"
+	"sync"
+	"time"
+)
+
+// ErrMaxWarmupDurationExceeded is returned by Run if the warm up is aborted because it exceeded MaxWarmupDuration.
+var ErrMaxWarmupDurationExceeded = errors.New("max warm up duration exceeded")
+
+// Config holds everything that Run needs to warm up a target.
+// HTTPRequests and GrpcRequests are called once the target is ready and return the channels from which the workers read the requests to send,
+// with the number of requests sent through them, or -1 if it is not known in advance, e.g. because requests are sent until MaxDurationSeconds.
+// The warm up finishes once both channels are closed, MaxDurationSeconds of the Warmup elapses or ctx is done. If either is nil, no requests of that type are sent.
+// If Precheck is set, it is called before waiting for the target, e.g. to wait for its ports to be open.
+// If SkipReadinessProbe is set, requests are sent without waiting for the target to be ready, e.g. to warm it up again.
+// If ConnectionsOnly is set, that many connections are opened to the HTTP target instead of sending requests. See ConnectionWarmup.
+// If MaxWarmupDuration is set, the warm up, including waiting for the target, is aborted once it elapses.
+// If Stats is set, the requests are recorded in it, e.g. to report the progress while the warm up runs.
+type Config struct {
+	Warmup       Warmup
+	HTTPRequests func(ctx context.Context) (<-chan http.Request, int, error)
+	HTTPHeaders  map[string]string
+	GrpcRequests func(ctx context.Context) (<-chan grpc.Request, int, error)
+	GrpcHeaders  []string
+	Delay        Delay
+
+	Precheck           func(ctx context.Context) error
+	SkipReadinessProbe bool
+
+	ConnectionsOnly     int
+	ConnectionsOnlyPath string
+
+	MaxWarmupDuration time.Duration
+	Stats             *Stats
+}
+
+// WarmupResult is the outcome of a warm up run by Run.
+type WarmupResult struct {
+	// Ready is true if the target became ready and the warm up requests were sent.
+	Ready          bool
+	Duration       time.Duration
+	RequestsSent   int
+	RequestsFailed int
+	SuccessRate    float64
+	Stats          *Stats
+}
+
+// Run waits for the target to be ready and warms it up with the requests of the config.
+// It returns an error if the precheck fails, if the target does not become ready or if the warm up exceeds MaxWarmupDuration,
+// in which case the result reports the requests completed until then.
+// If the Shutdown channel of the Warmup is closed, waiting for the target is given up and the requests in flight complete before Run returns.
+func Run(ctx context.Context, cfg Config) (WarmupResult, error) {
+	stats := cfg.Stats
+	if stats == nil {
+		stats = &Stats{}
+	}
+	startTime := time.Now()
+
+	cancel := func() {}
+	if cfg.MaxWarmupDuration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, cfg.MaxWarmupDuration)
+	}
+	defer cancel()
+
+	err := cfg.waitForTarget(ctx)
+	if err == nil {
+		cfg.send(ctx, stats)
+	}
+	stats.Finish()
+
+	result := WarmupResult{
+		Ready:          err == nil,
+		Duration:       time.Since(startTime),
+		RequestsSent:   stats.RequestsSent(),
+		RequestsFailed: stats.RequestsFailed(),
+		SuccessRate:    stats.SuccessRate(),
+		Stats:          stats,
+	}
+	if ctx.Err() == context.DeadlineExceeded && cfg.MaxWarmupDuration > 0 {
+		return result, ErrMaxWarmupDurationExceeded
+	}
+	return result, err
+}
+
+// waitForTarget runs the precheck, if any, and waits for the target to be ready unless SkipReadinessProbe is set.
+// There are no requests in flight while waiting for the target, so waiting is given up on shutdown.
+func (cfg Config) waitForTarget(ctx context.Context) error {
+	waitCtx, cancelWait := cfg.Warmup.shutdownContext(ctx)
+	defer cancelWait()
+
+	if cfg.Precheck != nil {
+		if err := cfg.Precheck(waitCtx); err != nil {
+			log.Printf("Precheck: %v. Giving up!", err)
+			return fmt.Errorf("precheck: %v", err)
+		}
+	}
+	if cfg.SkipReadinessProbe {
+		return nil
+	}
+	if err := cfg.Warmup.Target.WaitForReadinessProbe(waitCtx); err != nil {
+		log.Print("Target still not ready. Giving up!")
+		return err
+	}
+	return nil
+}
+
+// send runs the warm up, either sending the requests or only opening connections if ConnectionsOnly is set.
+// Once it finishes, the idle HTTP connections are closed and the pending spans are exported.
+func (cfg Config) send(ctx context.Context, stats *Stats) {
+	if cfg.ConnectionsOnly > 0 {
+		cfg.Warmup.ConnectionWarmup(ctx, cfg.ConnectionsOnly, cfg.ConnectionsOnlyPath, stats)
+	} else {
+		cfg.sendRequests(ctx, stats)
+	}
+	cfg.Warmup.Target.CloseHTTPConnections()
+	cfg.Warmup.Tracer.Close()
+}
+
+// sendRequests sends requests to the target using goroutines until there are no more requests or the context is done.
+// The total number of requests is recorded in the stats if it is known for both HTTP and gRPC.
+// HTTP and gRPC requests are sent in parallel: all the workers are released at the same time once they have been spawned,
+// and sendRequests returns once both the HTTP and gRPC workers are done.
+func (cfg Config) sendRequests(ctx context.Context, stats *Stats) {
+	httpRequests, httpTotal, err := httpRequestsChannel(ctx, cfg.HTTPRequests)
+	if err != nil {
+		log.Printf("HTTP options: %v", err)
+	}
+	grpcRequests, grpcTotal, err := grpcRequestsChannel(ctx, cfg.GrpcRequests)
+	if err != nil {
+		log.Printf("Grpc options: %v", err)
+	}
+	if httpTotal >= 0 && grpcTotal >= 0 {
+		stats.setTotalRequests(httpTotal + grpcTotal)
+	}
+
+	// closed once all the workers have been spawned so that HTTP and gRPC requests start at the same time
+	start := make(chan struct{})
+
+	var wg sync.WaitGroup
+	if httpRequests != nil {
+		for i := 1; i <= cfg.Warmup.Concurrency; i++ {
+			log.Printf("Spawning new go routine for HTTP requests")
+			wg.Add(1)
+			go func() {
+				<-start
+				cfg.Warmup.HTTPWarmupWorker(ctx, &wg, httpRequests, cfg.HTTPHeaders, cfg.Delay, stats)
+			}()
+		}
+	}
+
+	if grpcRequests != nil {
+		for i := 1; i <= cfg.Warmup.Concurrency; i++ {
+			log.Printf("Spawning new go routine for gRPC requests")
+			wg.Add(1)
+			go func() {
+				<-start
+				cfg.Warmup.GrpcWarmupWorker(ctx, &wg, grpcRequests, cfg.GrpcHeaders, cfg.Delay, stats)
+			}()
+		}
+	}
+
+	close(start)
+	wg.Wait()
+}
+
+// httpRequestsChannel returns the channel of HTTP requests and their number, or nil if there are none.
+func httpRequestsChannel(ctx context.Context, requests func(ctx context.Context) (<-chan http.Request, int, error)) (<-chan http.Request, int, error) {
+	if requests == nil {
+		return nil, 0, nil
+	}
+	channel, total, err := requests(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return channel, total, nil
+}
+
+// grpcRequestsChannel returns the channel of gRPC requests and their number, or nil if there are none.
+func grpcRequestsChannel(ctx context.Context, requests func(ctx context.Context) (<-chan grpc.Request, int, error)) (<-chan grpc.Request, int, error) {
+	if requests == nil {
+		return nil, 0, nil
+	}
+	channel, total, err := requests(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return channel, total, nil
+}
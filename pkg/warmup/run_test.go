@@ -0,0 +1,117 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package warmup
+
+import (
+	"context"
+	"errors"
+	"mittens/pkg/grpc"
+	"mittens/pkg/http"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(nethttp.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := http.NewClient(server.URL, false, http.TransportConfig{})
+	target := NewTarget(client, grpc.Client{}, client, grpc.Client{}, TargetOptions{ReadinessProtocol: "http", ReadinessHTTPPath: "/ready", ReadinessTimeoutInSeconds: 5})
+	cfg := Config{
+		Warmup: Warmup{Target: target, Concurrency: 2, MaxDurationSeconds: 5},
+		HTTPRequests: func(ctx context.Context) (<-chan http.Request, int, error) {
+			return requestsOf(http.Request{Method: "GET", Path: "/ok"}, http.Request{Method: "GET", Path: "/ok"}, http.Request{Method: "GET", Path: "/missing"}), 3, nil
+		},
+	}
+
+	result, err := Run(context.Background(), cfg)
+	require.NoError(t, err)
+	assert.True(t, result.Ready)
+	assert.Equal(t, 2, result.RequestsSent)
+	assert.Equal(t, 1, result.RequestsFailed)
+	assert.InDelta(t, 2.0/3, result.SuccessRate, 0.001)
+	assert.True(t, result.Duration > 0)
+	assert.True(t, result.Stats.Done())
+	total, ok := result.Stats.TotalRequests()
+	assert.True(t, ok)
+	assert.Equal(t, 3, total)
+}
+
+func TestRunPrecheckFails(t *testing.T) {
+	cfg := Config{
+		Precheck: func(ctx context.Context) error { return errors.New("port 8080 not open") },
+		HTTPRequests: func(ctx context.Context) (<-chan http.Request, int, error) {
+			t.Fatal("requests are not read if the target is not ready")
+			return nil, 0, nil
+		},
+	}
+
+	result, err := Run(context.Background(), cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "port 8080 not open")
+	assert.False(t, result.Ready)
+}
+
+func TestRunMaxWarmupDuration(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}))
+	defer server.Close()
+
+	target := NewTarget(http.Client{}, grpc.Client{}, http.NewClient(server.URL, false, http.TransportConfig{}), grpc.Client{}, TargetOptions{})
+	cfg := Config{
+		Warmup:             Warmup{Target: target, Concurrency: 1, MaxDurationSeconds: 5},
+		SkipReadinessProbe: true,
+		HTTPRequests: func(ctx context.Context) (<-chan http.Request, int, error) {
+			// the channel is never closed, so the warm up only finishes once the max warm up duration elapses
+			requests := make(chan http.Request)
+			go func() {
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case requests <- http.Request{Method: "GET", Path: "/ok"}:
+					}
+				}
+			}()
+			return requests, -1, nil
+		},
+		Delay:             Delay{Min: 10 * time.Millisecond, Max: 10 * time.Millisecond},
+		MaxWarmupDuration: 200 * time.Millisecond,
+	}
+
+	result, err := Run(context.Background(), cfg)
+	assert.Equal(t, ErrMaxWarmupDurationExceeded, err)
+	assert.True(t, result.RequestsSent > 0)
+	_, ok := result.Stats.TotalRequests()
+	assert.False(t, ok)
+}
+
+// requestsOf returns a closed channel with the requests.
+func requestsOf(requests ...http.Request) <-chan http.Request {
+	requestsChan := make(chan http.Request, len(requests))
+	for _, request := range requests {
+		requestsChan <- request
+	}
+	close(requestsChan)
+	return requestsChan
+}
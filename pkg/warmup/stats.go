@@ -0,0 +1,386 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package warmup
+
+import (
+	"mittens/pkg/response"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats keeps track of the outcome of the requests sent during the warm up.
+// It is safe for concurrent use by the workers.
+type Stats struct {
+	mu             sync.Mutex
+	requestsSent   int
+	requestsFailed int
+	failedShutdown int
+	slowRequests   int
+	retries        int
+	totalRequests  int
+	totalKnown     bool
+	done           bool
+	perName        map[string]*nameStats
+	statusCodes    map[StatusCodeStats]int
+	errorCauses    map[string]int
+	latencies      []time.Duration
+	timings        []response.Timing
+}
+
+// RequestStats holds the outcome and latency of the requests sent with the same name.
+// Requests without a name are named after their method and path e.g. GET /ping.
+// BytesSent is the total size of the request bodies as sent, i.e. after compression, and BytesRead the total size of the response bodies as read.
+// BytesDecompressed is the total size of the response bodies after decompression.
+// Redirects is the total number of redirects followed, which helps spotting redirect loops.
+type RequestStats struct {
+	Name              string
+	Sent              int
+	Failed            int
+	BytesSent         int64
+	BytesRead         int64
+	BytesDecompressed int64
+	Redirects         int
+	MinLatency        time.Duration
+	MeanLatency       time.Duration
+	MaxLatency        time.Duration
+}
+
+// StatusCodeStats holds the number of responses with the same status code and type (http or grpc).
+type StatusCodeStats struct {
+	Type       string
+	StatusCode int
+	Count      int
+}
+
+// ErrorCauseStats holds the number of requests that failed without a response for the same cause e.g. dial timeout.
+type ErrorCauseStats struct {
+	Cause string
+	Count int
+}
+
+// PhaseStats holds the latency percentiles of a phase of the HTTP requests e.g. DNS or TLS handshake.
+type PhaseStats struct {
+	Phase        string  `json:"phase"`
+	P50LatencyMs float64 `json:"p50LatencyMs"`
+	P95LatencyMs float64 `json:"p95LatencyMs"`
+	P99LatencyMs float64 `json:"p99LatencyMs"`
+}
+
+// Summary holds the outcome of the whole warm up, with the latency percentiles of all the requests, in a format suitable for JSON output.
+// Phases is only set if the timing breakdown of the HTTP requests is enabled.
+type Summary struct {
+	TotalRequests      int          `json:"totalRequests"`
+	SuccessfulRequests int          `json:"successfulRequests"`
+	FailedRequests     int          `json:"failedRequests"`
+	AverageLatencyMs   float64      `json:"averageLatencyMs"`
+	P50LatencyMs       float64      `json:"p50LatencyMs"`
+	P95LatencyMs       float64      `json:"p95LatencyMs"`
+	P99LatencyMs       float64      `json:"p99LatencyMs"`
+	TotalDurationMs    int64        `json:"totalDurationMs"`
+	Phases             []PhaseStats `json:"phases,omitempty"`
+}
+
+type nameStats struct {
+	RequestStats
+	totalLatency time.Duration
+	latencies    int
+}
+
+// setTotalRequests records the number of requests the warm up sends, once it is known.
+func (s *Stats) setTotalRequests(total int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.totalRequests = total
+	s.totalKnown = true
+}
+
+// Finish marks the warm up as done.
+func (s *Stats) Finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+}
+
+// Done returns true once the warm up has finished.
+func (s *Stats) Done() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done
+}
+
+// TotalRequests returns the number of requests the warm up sends.
+// It returns false if it is not known, e.g. because requests are sent until the max duration elapses.
+func (s *Stats) TotalRequests() (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.totalRequests, s.totalKnown
+}
+
+// record updates the counters with the outcome of a single request. Responses are grouped by the name of the request.
+func (s *Stats) record(resp response.Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.perName == nil {
+		s.perName = make(map[string]*nameStats)
+	}
+	stats, ok := s.perName[resp.Name]
+	if !ok {
+		stats = &nameStats{RequestStats: RequestStats{Name: resp.Name}}
+		s.perName[resp.Name] = stats
+	}
+
+	if resp.Attempts > 1 {
+		s.retries += resp.Attempts - 1
+	}
+	stats.BytesSent += resp.BytesSent
+	stats.BytesRead += resp.BytesRead
+	stats.BytesDecompressed += resp.BytesDecompressed
+	stats.Redirects += resp.Redirects
+
+	// gRPC responses with a status code of 0 are OK, unless they failed before receiving a status in which case they have an error
+	if resp.StatusCode > 0 || (resp.Type == "grpc" && resp.Err == nil) {
+		if s.statusCodes == nil {
+			s.statusCodes = make(map[StatusCodeStats]int)
+		}
+		s.statusCodes[StatusCodeStats{Type: resp.Type, StatusCode: resp.StatusCode}]++
+	}
+
+	if resp.Duration > 0 {
+		if stats.latencies == 0 || resp.Duration < stats.MinLatency {
+			stats.MinLatency = resp.Duration
+		}
+		if resp.Duration > stats.MaxLatency {
+			stats.MaxLatency = resp.Duration
+		}
+		stats.totalLatency += resp.Duration
+		stats.latencies++
+		s.latencies = append(s.latencies, resp.Duration)
+	}
+	if resp.Timing != nil {
+		s.timings = append(s.timings, *resp.Timing)
+	}
+
+	if resp.Err != nil {
+		s.requestsFailed++
+		stats.Failed++
+		if resp.ErrCause != "" {
+			if s.errorCauses == nil {
+				s.errorCauses = make(map[string]int)
+			}
+			s.errorCauses[resp.ErrCause]++
+		}
+		return
+	}
+	s.requestsSent++
+	stats.Sent++
+}
+
+// recordSlow counts a response that took longer than the latency threshold.
+func (s *Stats) recordSlow() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slowRequests++
+}
+
+// PerName returns the outcome of the requests grouped by request name and sorted by name.
+func (s *Stats) PerName() []RequestStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	perName := make([]RequestStats, 0, len(s.perName))
+	for _, stats := range s.perName {
+		requestStats := stats.RequestStats
+		if stats.latencies > 0 {
+			requestStats.MeanLatency = stats.totalLatency / time.Duration(stats.latencies)
+		}
+		perName = append(perName, requestStats)
+	}
+	sort.Slice(perName, func(i, j int) bool { return perName[i].Name < perName[j].Name })
+	return perName
+}
+
+// StatusCodes returns the number of responses per status code sorted by type and status code.
+// Requests that failed without a response, e.g. because of a connection error, are not included.
+func (s *Stats) StatusCodes() []StatusCodeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statusCodes := make([]StatusCodeStats, 0, len(s.statusCodes))
+	for key, count := range s.statusCodes {
+		key.Count = count
+		statusCodes = append(statusCodes, key)
+	}
+	sort.Slice(statusCodes, func(i, j int) bool {
+		if statusCodes[i].Type != statusCodes[j].Type {
+			return statusCodes[i].Type < statusCodes[j].Type
+		}
+		return statusCodes[i].StatusCode < statusCodes[j].StatusCode
+	})
+	return statusCodes
+}
+
+// ErrorCauses returns the number of failed requests per known cause, sorted by cause.
+func (s *Stats) ErrorCauses() []ErrorCauseStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errorCauses := make([]ErrorCauseStats, 0, len(s.errorCauses))
+	for cause, count := range s.errorCauses {
+		errorCauses = append(errorCauses, ErrorCauseStats{Cause: cause, Count: count})
+	}
+	sort.Slice(errorCauses, func(i, j int) bool { return errorCauses[i].Cause < errorCauses[j].Cause })
+	return errorCauses
+}
+
+// Summary returns the outcome of the warm up, which took totalDuration, including the average and percentile latencies of all the requests.
+func (s *Stats) Summary(totalDuration time.Duration) Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary := Summary{
+		TotalRequests:      s.requestsSent + s.requestsFailed,
+		SuccessfulRequests: s.requestsSent,
+		FailedRequests:     s.requestsFailed,
+		TotalDurationMs:    int64(totalDuration / time.Millisecond),
+		Phases:             s.phases(),
+	}
+	if len(s.latencies) == 0 {
+		return summary
+	}
+
+	latencies := make([]time.Duration, len(s.latencies))
+	copy(latencies, s.latencies)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	summary.AverageLatencyMs = toMilliseconds(total / time.Duration(len(latencies)))
+	summary.P50LatencyMs = toMilliseconds(percentile(latencies, 50))
+	summary.P95LatencyMs = toMilliseconds(percentile(latencies, 95))
+	summary.P99LatencyMs = toMilliseconds(percentile(latencies, 99))
+	return summary
+}
+
+// Phases returns the latency percentiles of each phase of the HTTP requests, in the order they happen,
+// or nil if the timing breakdown is not enabled. Requests sent over a reused connection count as zero for DNS, connect and TLS handshake.
+func (s *Stats) Phases() []PhaseStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.phases()
+}
+
+func (s *Stats) phases() []PhaseStats {
+	if len(s.timings) == 0 {
+		return nil
+	}
+	phases := []struct {
+		name     string
+		duration func(response.Timing) time.Duration
+	}{
+		{"dns", func(t response.Timing) time.Duration { return t.DNS }},
+		{"connect", func(t response.Timing) time.Duration { return t.Connect }},
+		{"tls", func(t response.Timing) time.Duration { return t.TLSHandshake }},
+		{"ttfb", func(t response.Timing) time.Duration { return t.TimeToFirstByte }},
+		{"body", func(t response.Timing) time.Duration { return t.BodyRead }},
+	}
+	stats := make([]PhaseStats, 0, len(phases))
+	for _, phase := range phases {
+		durations := make([]time.Duration, len(s.timings))
+		for i, timing := range s.timings {
+			durations[i] = phase.duration(timing)
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		stats = append(stats, PhaseStats{
+			Phase:        phase.name,
+			P50LatencyMs: toMilliseconds(percentile(durations, 50)),
+			P95LatencyMs: toMilliseconds(percentile(durations, 95)),
+			P99LatencyMs: toMilliseconds(percentile(durations, 99)),
+		})
+	}
+	return stats
+}
+
+// percentile returns the p-th percentile of the sorted latencies using the nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// toMilliseconds returns the duration in milliseconds with microsecond precision.
+func toMilliseconds(d time.Duration) float64 {
+	return float64(d/time.Microsecond) / 1000
+}
+
+// RequestsSent returns the number of requests for which a response was received.
+func (s *Stats) RequestsSent() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requestsSent
+}
+
+// SuccessRate returns the ratio of requests that succeeded out of all the requests sent, or 0 if no requests were sent.
+func (s *Stats) SuccessRate() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := s.requestsSent + s.requestsFailed
+	if total == 0 {
+		return 0
+	}
+	return float64(s.requestsSent) / float64(total)
+}
+
+// SlowRequests returns the number of responses that took longer than the latency threshold, if set.
+func (s *Stats) SlowRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.slowRequests
+}
+
+// Retries returns the number of times requests were sent again after failing.
+func (s *Stats) Retries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.retries
+}
+
+// RequestsFailed returns the number of requests that failed with an error.
+func (s *Stats) RequestsFailed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requestsFailed
+}
+
+// recordFailedAtShutdown counts a request in flight that failed once Shutdown was closed.
+func (s *Stats) recordFailedAtShutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failedShutdown++
+}
+
+// RequestsFailedAtShutdown returns the number of requests in flight that failed once Shutdown was closed.
+// It is only final once the workers have stopped, e.g. once Run returns.
+func (s *Stats) RequestsFailedAtShutdown() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failedShutdown
+}
@@ -0,0 +1,118 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package warmup
+
+import (
+	"errors"
+	"mittens/pkg/response"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsPerName(t *testing.T) {
+	stats := &Stats{}
+	stats.record(response.Response{Name: "search", StatusCode: 200, Duration: 10 * time.Millisecond, BytesRead: 100})
+	stats.record(response.Response{Name: "search", StatusCode: 200, Duration: 30 * time.Millisecond, BytesSent: 42, BytesRead: 100, BytesDecompressed: 400})
+	stats.record(response.Response{Name: "GET /admin", Err: errors.New("timeout")})
+
+	assert.Equal(t, 2, stats.RequestsSent())
+	assert.Equal(t, 1, stats.RequestsFailed())
+	assert.InDelta(t, 2.0/3, stats.SuccessRate(), 0.001)
+	assert.Equal(t, []RequestStats{
+		{Name: "GET /admin", Sent: 0, Failed: 1},
+		{Name: "search", Sent: 2, Failed: 0, BytesSent: 42, BytesRead: 200, BytesDecompressed: 400, MinLatency: 10 * time.Millisecond, MeanLatency: 20 * time.Millisecond, MaxLatency: 30 * time.Millisecond},
+	}, stats.PerName())
+}
+
+func TestStatsSuccessRateWithoutRequests(t *testing.T) {
+	stats := &Stats{}
+	assert.Equal(t, 0.0, stats.SuccessRate())
+}
+
+func TestStatsStatusCodes(t *testing.T) {
+	stats := &Stats{}
+	stats.record(response.Response{Type: "http", StatusCode: 404, Err: errors.New("unexpected status code 404")})
+	stats.record(response.Response{Type: "http", StatusCode: 200})
+	stats.record(response.Response{Type: "http", StatusCode: 200})
+	stats.record(response.Response{Type: "http", Err: errors.New("connection refused")})
+	stats.record(response.Response{Type: "grpc", StatusCode: 0})
+	stats.record(response.Response{Type: "grpc", StatusCode: 14})
+
+	assert.Equal(t, []StatusCodeStats{
+		{Type: "grpc", StatusCode: 0, Count: 1},
+		{Type: "grpc", StatusCode: 14, Count: 1},
+		{Type: "http", StatusCode: 200, Count: 2},
+		{Type: "http", StatusCode: 404, Count: 1},
+	}, stats.StatusCodes())
+}
+
+func TestStatsErrorCauses(t *testing.T) {
+	stats := &Stats{}
+	stats.record(response.Response{Err: errors.New("refused"), ErrCause: "connection refused"})
+	stats.record(response.Response{Err: errors.New("timeout"), ErrCause: "dial timeout"})
+	stats.record(response.Response{Err: errors.New("refused"), ErrCause: "connection refused"})
+	stats.record(response.Response{Err: errors.New("unknown")})
+
+	assert.Equal(t, []ErrorCauseStats{{Cause: "connection refused", Count: 2}, {Cause: "dial timeout", Count: 1}}, stats.ErrorCauses())
+}
+
+func TestStatsSummary(t *testing.T) {
+	stats := &Stats{}
+	for i := 1; i <= 100; i++ {
+		stats.record(response.Response{Name: "search", StatusCode: 200, Duration: time.Duration(i) * time.Millisecond})
+	}
+	stats.record(response.Response{Name: "search", Err: errors.New("connection refused")})
+
+	assert.Equal(t, Summary{
+		TotalRequests:      101,
+		SuccessfulRequests: 100,
+		FailedRequests:     1,
+		AverageLatencyMs:   50.5,
+		P50LatencyMs:       50,
+		P95LatencyMs:       95,
+		P99LatencyMs:       99,
+		TotalDurationMs:    2000,
+	}, stats.Summary(2*time.Second))
+}
+
+func TestStatsSummaryWithoutRequests(t *testing.T) {
+	stats := &Stats{}
+	assert.Equal(t, Summary{TotalDurationMs: 1000}, stats.Summary(time.Second))
+}
+
+func TestStatsPhases(t *testing.T) {
+	stats := &Stats{}
+	assert.Nil(t, stats.Phases())
+
+	for i := 1; i <= 100; i++ {
+		timing := &response.Timing{TimeToFirstByte: time.Duration(i) * time.Millisecond, BodyRead: time.Millisecond}
+		// only the first request opens a connection
+		if i == 1 {
+			timing.DNS, timing.Connect, timing.TLSHandshake = time.Millisecond, 2*time.Millisecond, 3*time.Millisecond
+		}
+		stats.record(response.Response{Name: "search", StatusCode: 200, Duration: time.Duration(i) * time.Millisecond, Timing: timing})
+	}
+
+	assert.Equal(t, []PhaseStats{
+		{Phase: "dns", P50LatencyMs: 0, P95LatencyMs: 0, P99LatencyMs: 0},
+		{Phase: "connect", P50LatencyMs: 0, P95LatencyMs: 0, P99LatencyMs: 0},
+		{Phase: "tls", P50LatencyMs: 0, P95LatencyMs: 0, P99LatencyMs: 0},
+		{Phase: "ttfb", P50LatencyMs: 50, P95LatencyMs: 95, P99LatencyMs: 99},
+		{Phase: "body", P50LatencyMs: 1, P95LatencyMs: 1, P99LatencyMs: 1},
+	}, stats.Phases())
+	assert.Equal(t, stats.Phases(), stats.Summary(time.Second).Phases)
+}
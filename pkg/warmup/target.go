@@ -15,6 +15,7 @@
 package warmup
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"mittens/pkg/grpc"
@@ -30,6 +31,9 @@ type TargetOptions struct {
 	ReadinessGrpcMethod       string
 	ReadinessPort             int
 	ReadinessTimeoutInSeconds int
+	// GrpcWaitForHealthy makes the target ready only once the gRPC health check of the warm up target is SERVING.
+	GrpcWaitForHealthy bool
+	GrpcHeaders        []string
 }
 
 // Target includes information needed to send requests to the target. It includes configured http and gRPC clients and options set by the user.
@@ -53,10 +57,19 @@ func NewTarget(readinessHTTPClient whttp.Client, readinessGrpcClient grpc.Client
 	return t
 }
 
+// CloseHTTPConnections closes the idle connections of the HTTP clients, so that they are not kept open
+// once the warm up finishes, e.g. while mittens keeps running to serve its probes.
+func (t Target) CloseHTTPConnections() {
+	t.readinessHTTPClient.Close()
+	t.httpClient.Close()
+}
+
 // WaitForReadinessProbe sends health-check requests to the target and waits until it becomes ready.
 // It returns an error if the timeout is exceeded.
 // It supports both HTTP and gRPC health-checks.
-func (t Target) WaitForReadinessProbe() error {
+// If GrpcWaitForHealthy is set, it then also waits until the gRPC health check of the warm up target is SERVING.
+// It also returns an error if the context is done before the target is ready.
+func (t Target) WaitForReadinessProbe(ctx context.Context) error {
 	log.Printf("Waiting for target to be ready for a max of %ds", t.options.ReadinessTimeoutInSeconds)
 
 	timeout := time.After(time.Duration(t.options.ReadinessTimeoutInSeconds) * time.Second)
@@ -64,26 +77,37 @@ func (t Target) WaitForReadinessProbe() error {
 		select {
 		case <-timeout:
 			return fmt.Errorf("Giving up! Target not ready after %d seconds 🙁", t.options.ReadinessTimeoutInSeconds)
+		case <-ctx.Done():
+			return fmt.Errorf("Giving up! Target not ready: %v", ctx.Err())
 		default:
 			// Wait one second between attempts. This is not configurable
-			time.Sleep(time.Second * 1)
+			if !sleep(ctx, time.Second*1) {
+				continue
+			}
 
 			if t.options.ReadinessProtocol == "http" {
 				// error if error in the response or status code not in the 200 range
-				if resp := t.readinessHTTPClient.SendRequest(http.MethodGet, t.options.ReadinessHTTPPath, nil, nil); resp.Err != nil || resp.StatusCode/100 != 2 {
+				if resp := t.readinessHTTPClient.SendRequest(ctx, whttp.Request{Method: http.MethodGet, Path: t.options.ReadinessHTTPPath}); resp.Err != nil || resp.StatusCode/100 != 2 {
 					log.Printf("HTTP target not ready yet...")
 					continue
 				}
 			} else {
 				request, err := grpc.ToGrpcRequest(t.options.ReadinessGrpcMethod)
 				if err == nil {
-					err1 := t.readinessGrpcClient.SendRequest(request.ServiceMethod, "", nil)
+					err1 := t.readinessGrpcClient.SendRequest(ctx, request.ServiceMethod, "", nil)
 					if err1.Err != nil {
 						log.Printf("gRPC target not ready yet...")
 						continue
 					}
 				}
 			}
+
+			if t.options.GrpcWaitForHealthy {
+				if err := t.grpcClient.CheckHealth(ctx, t.options.GrpcHeaders); err != nil {
+					log.Printf("gRPC target not healthy yet: %v", err)
+					continue
+				}
+			}
 			return nil
 		}
 	}
@@ -0,0 +1,78 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package warmup
+
+import (
+	"context"
+	"fmt"
+	"mittens/pkg/grpc"
+	"mittens/pkg/http"
+	"mittens/pkg/response"
+	"mittens/pkg/tracing"
+	"strings"
+)
+
+// sendHTTPRequest sends the request within a span, if tracing is enabled, setting the HTTP semantic attributes of the span.
+func (w Warmup) sendHTTPRequest(ctx context.Context, client http.Client, request http.Request) response.Response {
+	span := w.Tracer.Start("HTTP " + request.Method)
+	if span == nil {
+		return client.SendRequest(ctx, request)
+	}
+
+	request.Headers = mergeHeaders(request.Headers, map[string]string{tracing.TraceParentHeader: span.TraceParent()})
+	resp := client.SendRequest(ctx, request)
+
+	span.SetAttribute("http.method", request.Method)
+	span.SetAttribute("http.target", request.Path)
+	if request.Host != "" {
+		span.SetAttribute("net.peer.name", request.Host)
+	}
+	if resp.StatusCode > 0 {
+		span.SetAttribute("http.status_code", resp.StatusCode)
+	}
+	// client spans of 4xx and 5xx responses are errors
+	if resp.Err != nil {
+		span.SetError(resp.Err.Error())
+	} else if resp.StatusCode >= 400 {
+		span.SetError(fmt.Sprintf("HTTP status code %d", resp.StatusCode))
+	}
+	span.End()
+	return resp
+}
+
+// sendGrpcRequest sends the request within a span, if tracing is enabled, setting the gRPC semantic attributes of the span.
+func (w Warmup) sendGrpcRequest(ctx context.Context, request grpc.Request, headers []string) response.Response {
+	span := w.Tracer.Start(request.ServiceMethod)
+	if span == nil {
+		return w.Target.grpcClient.SendRequest(ctx, request.ServiceMethod, request.Message, headers)
+	}
+
+	headers = append(append([]string{}, headers...), tracing.TraceParentHeader+": "+span.TraceParent())
+	resp := w.Target.grpcClient.SendRequest(ctx, request.ServiceMethod, request.Message, headers)
+
+	span.SetAttribute("rpc.system", "grpc")
+	if i := strings.LastIndex(request.ServiceMethod, "/"); i != -1 {
+		span.SetAttribute("rpc.service", request.ServiceMethod[:i])
+		span.SetAttribute("rpc.method", request.ServiceMethod[i+1:])
+	}
+	span.SetAttribute("rpc.grpc.status_code", resp.StatusCode)
+	if resp.Err != nil {
+		span.SetError(resp.Err.Error())
+	} else if resp.StatusCode != 0 {
+		span.SetError(fmt.Sprintf("gRPC status code %d", resp.StatusCode))
+	}
+	span.End()
+	return resp
+}
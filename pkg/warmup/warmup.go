@@ -15,57 +15,246 @@
 package warmup
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math/rand"
 	"mittens/pkg/grpc"
 	"mittens/pkg/http"
+	"mittens/pkg/response"
+	"mittens/pkg/tracing"
+	"net/textproto"
 	"sync"
 	"time"
 )
 
 // Warmup holds any information needed for the workers to send requests.
+// If HTTPFailOnStatus is set, only the responses with these status codes are counted as failures, unless the request sets its own expected status codes.
+// If WarnLatencyThreshold is set, responses that take longer are logged as a warning and counted as slow.
+// Failed requests are retried according to Retry, without exceeding MaxDurationSeconds.
+// If HTTPCookieJarPerWorker is set, each HTTP worker keeps its own cookies, so that each one has its own session.
+// If HTTPAuthorization is set, it overrides the Authorization header of the global headers.
+// If Tracer is set, every request is recorded as a span and its trace is propagated to the target with a traceparent header.
+// If Shutdown is set, the workers stop picking new requests once it is closed, e.g. on SIGTERM, and the requests in flight complete.
 type Warmup struct {
-	Target             Target
-	MaxDurationSeconds int
-	Concurrency        int
+	Target               Target
+	MaxDurationSeconds   int
+	Concurrency          int
+	HTTPFailOnStatus     http.StatusCodes
+	WarnLatencyThreshold time.Duration
+	Retry                Retry
+
+	HTTPCookieJarPerWorker bool
+	HTTPAuthorization      *http.Authorization
+	Tracer                 *tracing.Tracer
+
+	Shutdown <-chan struct{}
+}
+
+// Delay is the time to wait before each request.
+// If Max is greater than Min, a random duration between Min and Max (inclusive) is used to spread the load.
+type Delay struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// next returns the time to wait before the next request.
+func (d Delay) next() time.Duration {
+	if d.Max <= d.Min {
+		return d.Min
+	}
+	return d.Min + time.Duration(rand.Int63n(int64(d.Max-d.Min)+1))
+}
+
+// isUnexpectedStatus returns true if a response with the status code is a failure.
+func (w Warmup) isUnexpectedStatus(request http.Request, statusCode int) bool {
+	if len(request.ExpectedStatusCodes) == 0 && len(w.HTTPFailOnStatus) > 0 {
+		return w.HTTPFailOnStatus.Contains(statusCode)
+	}
+	return !request.ExpectedStatusCodes.Contains(statusCode)
+}
+
+// checkLatency logs a warning and counts the response as slow if it took longer than the latency threshold.
+func (w Warmup) checkLatency(request string, resp response.Response, stats *Stats) {
+	if w.WarnLatencyThreshold <= 0 || resp.Duration <= w.WarnLatencyThreshold {
+		return
+	}
+	stats.recordSlow()
+	log.Printf("⚠️ WARNING: slow %s response for %s %d ms, over the threshold of %d ms", resp.Type, request, resp.Duration/time.Millisecond, w.WarnLatencyThreshold/time.Millisecond)
 }
 
 // HTTPWarmupWorker sends HTTP requests to the target using goroutines.
-func (w Warmup) HTTPWarmupWorker(wg *sync.WaitGroup, requests <-chan http.Request, headers map[string]string, requestDelayMilliseconds int, requestsSentCounter *int) {
-	for request := range requests {
-		time.Sleep(time.Duration(requestDelayMilliseconds) * time.Millisecond)
+// It stops once there are no more requests or the context is done, in which case the request in flight is cancelled.
+// It also stops once Shutdown is closed, in which case the request in flight completes but is not retried.
+func (w Warmup) HTTPWarmupWorker(ctx context.Context, wg *sync.WaitGroup, requests <-chan http.Request, headers map[string]string, delay Delay, stats *Stats) {
+	defer wg.Done()
+	stopCtx, stop := w.shutdownContext(ctx)
+	defer stop()
+	retryCtx, cancel := w.retryContext(stopCtx)
+	defer cancel()
+	client := w.Target.httpClient
+	if w.HTTPCookieJarPerWorker {
+		client = client.WithCookieJar()
+	}
+	for {
+		request, ok := next(stopCtx, requests)
+		if !ok || !sleep(stopCtx, delay.next()) {
+			return
+		}
 
-		resp := w.Target.httpClient.SendRequest(request.Method, request.Path, headers, request.Body)
+		httpRequest := request
+		httpRequest.Headers = mergeHeaders(w.globalHTTPHeaders(headers), request.Headers)
+		resp := w.Retry.send(retryCtx, request.Path, func() response.Response {
+			return w.sendHTTPRequest(ctx, client, httpRequest)
+		})
+		unexpectedStatus := resp.Err == nil && w.isUnexpectedStatus(request, resp.StatusCode)
+		if unexpectedStatus {
+			resp.Err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		}
+		resp.Name = request.GetName()
+		w.record(resp, stats)
+		w.checkLatency(request.Method+" "+request.Path, resp, stats)
 
-		if resp.Err != nil {
+		if unexpectedStatus {
+			log.Printf("🔴 %s response for %s %d ms: %v, body: %q", resp.Type, request.Path, resp.Duration/time.Millisecond, resp.StatusCode, resp.Body)
+		} else if errors.Is(resp.Err, http.ErrBodyAssertion) {
+			log.Printf("🔴 %s response for %s %d ms: %v, body: %q", resp.Type, request.Path, resp.Duration/time.Millisecond, resp.Err, resp.Body)
+		} else if resp.Err != nil {
 			log.Printf("🔴 Error in request for %s: %v", request.Path, resp.Err)
 		} else {
-			*requestsSentCounter++
-
-			if resp.StatusCode/100 == 2 {
-				log.Printf("%s response for %s %d ms: %v", resp.Type, request.Path, resp.Duration/time.Millisecond, resp.StatusCode)
-			} else {
-				log.Printf("🔴 %s response for %s %d ms: %v", resp.Type, request.Path, resp.Duration/time.Millisecond, resp.StatusCode)
-			}
+			log.Printf("%s response for %s %d ms: %v", resp.Type, request.Path, resp.Duration/time.Millisecond, resp.StatusCode)
 		}
 	}
-	wg.Done()
+}
+
+// globalHTTPHeaders returns the global headers overridden by the Authorization header, if set.
+// The Authorization is read every time, so that refreshed credentials are picked up.
+func (w Warmup) globalHTTPHeaders(headers map[string]string) map[string]string {
+	if w.HTTPAuthorization == nil {
+		return headers
+	}
+	return mergeHeaders(headers, map[string]string{"Authorization": w.HTTPAuthorization.Value()})
 }
 
 // GrpcWarmupWorker sends gRPC requests to the target using goroutines.
-func (w Warmup) GrpcWarmupWorker(wg *sync.WaitGroup, requests <-chan grpc.Request, headers []string, requestDelayMilliseconds int, requestsSentCounter *int) {
-	for request := range requests {
-		time.Sleep(time.Duration(requestDelayMilliseconds) * time.Millisecond)
+// It stops once there are no more requests or the context is done, in which case the request in flight is cancelled.
+// It also stops once Shutdown is closed, in which case the request in flight completes but is not retried.
+func (w Warmup) GrpcWarmupWorker(ctx context.Context, wg *sync.WaitGroup, requests <-chan grpc.Request, headers []string, delay Delay, stats *Stats) {
+	defer wg.Done()
+	stopCtx, stop := w.shutdownContext(ctx)
+	defer stop()
+	retryCtx, cancel := w.retryContext(stopCtx)
+	defer cancel()
+	for {
+		request, ok := nextGrpc(stopCtx, requests)
+		if !ok || !sleep(stopCtx, delay.next()) {
+			return
+		}
 
-		resp := w.Target.grpcClient.SendRequest(request.ServiceMethod, request.Message, headers)
+		requestHeaders := append(append([]string{}, headers...), request.Headers...)
+		resp := w.Retry.send(retryCtx, request.ServiceMethod, func() response.Response {
+			return w.sendGrpcRequest(ctx, request, requestHeaders)
+		})
+		resp.Name = request.GetName()
+		w.record(resp, stats)
+		w.checkLatency(request.ServiceMethod, resp, stats)
 
 		if resp.Err != nil {
-			log.Printf("🔴 Error in request for %s: %v", request.ServiceMethod, resp.Err)
+			log.Printf("🔴 Error in request for %s after %d messages sent: %v", request.ServiceMethod, resp.MessagesSent, resp.Err)
 		} else {
-			*requestsSentCounter++
-
-			log.Printf("%s response for %s %d ms", resp.Type, request.ServiceMethod, resp.Duration/time.Millisecond)
+			log.Printf("%s response for %s %d ms, %d messages sent, %d received, first after %d ms", resp.Type, request.ServiceMethod, resp.Duration/time.Millisecond,
+				resp.MessagesSent, resp.MessagesReceived, resp.TimeToFirstMessage/time.Millisecond)
+		}
+		// only set if the responses are captured, e.g. with grpc-verbose
+		if resp.Body != "" {
+			log.Printf("%s responses for %s:\n%s", resp.Type, request.ServiceMethod, resp.Body)
 		}
+	}
+}
 
+// record records the response in the stats. Responses that failed once Shutdown was closed are also counted as failed at shutdown.
+func (w Warmup) record(resp response.Response, stats *Stats) {
+	stats.record(resp)
+	if resp.Err == nil || w.Shutdown == nil {
+		return
+	}
+	select {
+	case <-w.Shutdown:
+		stats.recordFailedAtShutdown()
+	default:
+	}
+}
+
+// shutdownContext returns a context that is done once ctx is done or Shutdown is closed.
+// Workers stop picking new requests once it is done, while the requests in flight, which use ctx, complete.
+func (w Warmup) shutdownContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	stopCtx, cancel := context.WithCancel(ctx)
+	if w.Shutdown != nil {
+		go func() {
+			select {
+			case <-w.Shutdown:
+				cancel()
+			case <-stopCtx.Done():
+			}
+		}()
+	}
+	return stopCtx, cancel
+}
+
+// next returns the next HTTP request. It returns false if there are no more requests or the context is done.
+func next(ctx context.Context, requests <-chan http.Request) (http.Request, bool) {
+	if ctx.Err() != nil {
+		return http.Request{}, false
+	}
+	select {
+	case <-ctx.Done():
+		return http.Request{}, false
+	case request, ok := <-requests:
+		return request, ok
+	}
+}
+
+// nextGrpc returns the next gRPC request. It returns false if there are no more requests or the context is done.
+func nextGrpc(ctx context.Context, requests <-chan grpc.Request) (grpc.Request, bool) {
+	if ctx.Err() != nil {
+		return grpc.Request{}, false
+	}
+	select {
+	case <-ctx.Done():
+		return grpc.Request{}, false
+	case request, ok := <-requests:
+		return request, ok
+	}
+}
+
+// sleep waits for the given duration. It returns false if the context is done before that.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// mergeHeaders returns the global headers overridden by the request headers. Header names are case-insensitive.
+func mergeHeaders(globalHeaders, requestHeaders map[string]string) map[string]string {
+	if len(requestHeaders) == 0 {
+		return globalHeaders
+	}
+
+	headers := make(map[string]string, len(globalHeaders)+len(requestHeaders))
+	for k, v := range globalHeaders {
+		headers[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+	for k, v := range requestHeaders {
+		headers[textproto.CanonicalMIMEHeaderKey(k)] = v
 	}
-	wg.Done()
+	return headers
 }
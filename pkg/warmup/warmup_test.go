@@ -0,0 +1,278 @@
+//Copyright 2019 Expedia, Inc.
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package warmup
+
+import (
+	"context"
+	"io/ioutil"
+	"mittens/pkg/grpc"
+	"mittens/pkg/http"
+	"mittens/pkg/tracing"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixedDelay(t *testing.T) {
+	delay := Delay{Min: 500 * time.Millisecond, Max: 500 * time.Millisecond}
+	assert.Equal(t, 500*time.Millisecond, delay.next())
+	assert.Equal(t, time.Duration(0), Delay{}.next())
+}
+
+func TestDelayWithJitter(t *testing.T) {
+	delay := Delay{Min: 10 * time.Millisecond, Max: 20 * time.Millisecond}
+	for i := 0; i < 100; i++ {
+		next := delay.next()
+		assert.True(t, next >= delay.Min && next <= delay.Max, "delay %v out of range", next)
+	}
+}
+
+func TestHTTPWarmupWorkerExpectedStatusCodes(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(nethttp.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	expectNotFound, _ := http.ParseStatusCodes("404")
+	target := NewTarget(http.Client{}, grpc.Client{}, http.NewClient(server.URL, false, http.TransportConfig{}), grpc.Client{}, TargetOptions{})
+	requests := make(chan http.Request, 3)
+	requests <- http.Request{Method: "GET", Path: "/ok"}
+	requests <- http.Request{Method: "GET", Path: "/missing"}
+	requests <- http.Request{Method: "GET", Path: "/missing", ExpectedStatusCodes: expectNotFound}
+	close(requests)
+
+	stats := &Stats{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	Warmup{Target: target}.HTTPWarmupWorker(context.Background(), &wg, requests, nil, Delay{}, stats)
+
+	assert.Equal(t, 2, stats.RequestsSent())
+	assert.Equal(t, 1, stats.RequestsFailed())
+}
+
+func TestHTTPWarmupWorkerFailOnStatus(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(nethttp.StatusNotFound)
+		case "/unavailable":
+			w.WriteHeader(nethttp.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	failOnStatus, _ := http.ParseStatusCodes("5xx")
+	expectOK, _ := http.ParseStatusCodes("200")
+	target := NewTarget(http.Client{}, grpc.Client{}, http.NewClient(server.URL, false, http.TransportConfig{}), grpc.Client{}, TargetOptions{})
+	requests := make(chan http.Request, 4)
+	requests <- http.Request{Method: "GET", Path: "/ok"}
+	requests <- http.Request{Method: "GET", Path: "/missing"}
+	requests <- http.Request{Method: "GET", Path: "/unavailable"}
+	requests <- http.Request{Method: "GET", Path: "/missing", ExpectedStatusCodes: expectOK}
+	close(requests)
+
+	stats := &Stats{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	Warmup{Target: target, HTTPFailOnStatus: failOnStatus}.HTTPWarmupWorker(context.Background(), &wg, requests, nil, Delay{}, stats)
+
+	assert.Equal(t, 2, stats.RequestsSent())
+	assert.Equal(t, 2, stats.RequestsFailed())
+}
+
+func TestHTTPWarmupWorkerSlowRequests(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	target := NewTarget(http.Client{}, grpc.Client{}, http.NewClient(server.URL, false, http.TransportConfig{}), grpc.Client{}, TargetOptions{})
+	requests := make(chan http.Request, 3)
+	requests <- http.Request{Method: "GET", Path: "/fast"}
+	requests <- http.Request{Method: "GET", Path: "/slow"}
+	requests <- http.Request{Method: "GET", Path: "/slow"}
+	close(requests)
+
+	stats := &Stats{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	Warmup{Target: target, WarnLatencyThreshold: 50 * time.Millisecond}.HTTPWarmupWorker(context.Background(), &wg, requests, nil, Delay{}, stats)
+
+	assert.Equal(t, 3, stats.RequestsSent())
+	assert.Equal(t, 2, stats.SlowRequests())
+}
+
+func TestHTTPWarmupWorkerCancelled(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	target := NewTarget(http.Client{}, grpc.Client{}, http.NewClient(server.URL, false, http.TransportConfig{}), grpc.Client{}, TargetOptions{})
+	requests := make(chan http.Request, 2)
+	requests <- http.Request{Method: "GET", Path: "/slow"}
+	requests <- http.Request{Method: "GET", Path: "/slow"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	stats := &Stats{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	start := time.Now()
+	Warmup{Target: target}.HTTPWarmupWorker(ctx, &wg, requests, nil, Delay{}, stats)
+
+	assert.True(t, time.Since(start) < 5*time.Second)
+	assert.Equal(t, 0, stats.RequestsSent())
+	assert.Equal(t, 1, stats.RequestsFailed())
+}
+
+func TestHTTPWarmupWorkerShutdown(t *testing.T) {
+	shutdown := make(chan struct{})
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		// shut down while the first request is in flight
+		close(shutdown)
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	target := NewTarget(http.Client{}, grpc.Client{}, http.NewClient(server.URL, false, http.TransportConfig{}), grpc.Client{}, TargetOptions{})
+	requests := make(chan http.Request, 2)
+	requests <- http.Request{Method: "GET", Path: "/slow"}
+	requests <- http.Request{Method: "GET", Path: "/slow"}
+
+	stats := &Stats{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	Warmup{Target: target, Shutdown: shutdown}.HTTPWarmupWorker(context.Background(), &wg, requests, nil, Delay{}, stats)
+
+	// the request in flight completes and the next one is not sent
+	assert.Equal(t, 1, stats.RequestsSent())
+	assert.Equal(t, 0, stats.RequestsFailed())
+	assert.Len(t, requests, 1)
+}
+
+func TestHTTPWarmupWorkersFailedAtShutdown(t *testing.T) {
+	const workers = 3
+	shutdown := make(chan struct{})
+	var inFlight int32
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		// shut down once every worker has a request in flight, and fail all of them afterwards
+		if r.URL.Path == "/in-flight" {
+			if atomic.AddInt32(&inFlight, 1) == workers {
+				close(shutdown)
+			}
+			<-shutdown
+		}
+		panic(nethttp.ErrAbortHandler)
+	}))
+	defer server.Close()
+
+	target := NewTarget(http.Client{}, grpc.Client{}, http.NewClient(server.URL, false, http.TransportConfig{}), grpc.Client{}, TargetOptions{})
+	warmup := Warmup{Target: target, Shutdown: shutdown}
+	stats := &Stats{}
+
+	// a request that fails before the shutdown is not counted
+	before := make(chan http.Request, 1)
+	before <- http.Request{Method: "GET", Path: "/before"}
+	close(before)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	warmup.HTTPWarmupWorker(context.Background(), &wg, before, nil, Delay{}, stats)
+	assert.Equal(t, 1, stats.RequestsFailed())
+	assert.Equal(t, 0, stats.RequestsFailedAtShutdown())
+
+	requests := make(chan http.Request, workers+2)
+	for i := 0; i < workers+2; i++ {
+		requests <- http.Request{Method: "GET", Path: "/in-flight"}
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go warmup.HTTPWarmupWorker(context.Background(), &wg, requests, nil, Delay{}, stats)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1+workers, stats.RequestsFailed())
+	assert.Equal(t, workers, stats.RequestsFailedAtShutdown())
+	assert.Len(t, requests, 2)
+}
+
+func TestHTTPWarmupWorkerAuthorization(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		received = append(received, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "auth")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("token"), 0600))
+	authorization, err := http.NewBearerAuthorization(tokenFile)
+	require.NoError(t, err)
+
+	target := NewTarget(http.Client{}, grpc.Client{}, http.NewClient(server.URL, false, http.TransportConfig{}), grpc.Client{}, TargetOptions{})
+	requests := make(chan http.Request, 2)
+	requests <- http.Request{Method: "GET", Path: "/"}
+	requests <- http.Request{Method: "GET", Path: "/", Headers: map[string]string{"authorization": "Basic request"}}
+	close(requests)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	headers := map[string]string{"Authorization": "Bearer global"}
+	Warmup{Target: target, HTTPAuthorization: authorization}.HTTPWarmupWorker(context.Background(), &wg, requests, headers, Delay{}, &Stats{})
+
+	assert.Equal(t, []string{"Bearer token", "Basic request"}, received)
+}
+
+func TestHTTPWarmupWorkerTracing(t *testing.T) {
+	traceParents := make(chan string, 1)
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		traceParents <- r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+	spans := make(chan struct{}, 1)
+	collector := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		spans <- struct{}{}
+	}))
+	defer collector.Close()
+
+	target := NewTarget(http.Client{}, grpc.Client{}, http.NewClient(server.URL, false, http.TransportConfig{}), grpc.Client{}, TargetOptions{})
+	requests := make(chan http.Request, 1)
+	requests <- http.Request{Method: "GET", Path: "/"}
+	close(requests)
+
+	tracer := tracing.NewTracer(collector.URL, "mittens")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	Warmup{Target: target, Tracer: tracer}.HTTPWarmupWorker(context.Background(), &wg, requests, nil, Delay{}, &Stats{})
+	tracer.Close()
+
+	assert.Regexp(t, "^00-[0-9a-f]{32}-[0-9a-f]{16}-01$", <-traceParents)
+	assert.Len(t, spans, 1)
+}